@@ -0,0 +1,390 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditRecord records a command run through an audited Executor.
+// Records are chained: Hash covers the record and the Hash of the
+// previous one, so altering, removing or reordering records breaks
+// the chain checked by VerifyAuditLog.
+type AuditRecord struct {
+	Seq uint64 `json:"seq"`
+	// Time is when the command started, in UTC.
+	Time time.Time `json:"time"`
+	// Operator is the local user running the automation.
+	Operator string `json:"operator"`
+	// User and Host are the remote user and address.
+	User     string        `json:"user"`
+	Host     string        `json:"host"`
+	Command  string        `json:"command"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	// Stdout and Stderr are only recorded with AuditOptions.Output.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// Events is the timed output of the command, recorded with
+	// AuditOptions.Output for replay. It is not part of the chain.
+	Events []AuditEvent `json:"-"`
+	// Terminal is the pseudo terminal of the command, if any.
+	Terminal *PTY   `json:"-"`
+	Prev     string `json:"prev"`
+	Hash     string `json:"hash"`
+}
+
+// AuditEvent is a chunk of output written Offset after the command started.
+type AuditEvent struct {
+	Offset time.Duration
+	Stream Stream
+	Data   []byte
+}
+
+// AuditSink stores audit records. Write is never called concurrently.
+type AuditSink interface {
+	Write(rec *AuditRecord) error
+	Close() error
+}
+
+// AuditOptions configures an audited Executor.
+type AuditOptions struct {
+	// Operator defaults to the current local user.
+	Operator string
+	// User and Host default to the remote user and address of the
+	// executor when it is built by NewExecutor, possibly wrapped by
+	// Become, in which case User is the user commands run as.
+	User string
+	Host string
+	// Output records the output of the commands.
+	Output bool
+	// Prev is the hash of the last record of an existing log,
+	// to continue its chain.
+	Prev string
+	// Seq is the sequence number of that record.
+	Seq uint64
+}
+
+type auditExecutor struct {
+	Executor
+	sink AuditSink
+	opts AuditOptions
+
+	mu   sync.Mutex
+	prev string
+	seq  uint64
+}
+
+// Audit returns an Executor recording every command run by e to sink.
+// Wrap it around Become so that the recorded command is the one asked
+// for. A record failing to be written fails the command's Run, after
+// the command has run.
+func Audit(e Executor, sink AuditSink, opts AuditOptions) Executor {
+	if opts.Operator == "" {
+		if u, err := user.Current(); err == nil {
+			opts.Operator = u.Username
+		} else {
+			opts.Operator = os.Getenv("USER")
+		}
+	}
+	if t, ok := e.(interface{ target() (string, string) }); ok {
+		u, host := t.target()
+		if opts.User == "" {
+			opts.User = u
+		}
+		if opts.Host == "" {
+			opts.Host = host
+		}
+	}
+	return &auditExecutor{Executor: e, sink: sink, opts: opts, prev: opts.Prev, seq: opts.Seq}
+}
+
+func (e *sshExecutor) target() (string, string) {
+	return e.ssh.User, e.ssh.Addr
+}
+
+func (b *becomeExecutor) target() (string, string) {
+	host := ""
+	if t, ok := b.Executor.(interface{ target() (string, string) }); ok {
+		_, host = t.target()
+	}
+	return b.opts.User, host
+}
+
+// Run runs cmd and records it.
+func (a *auditExecutor) Run(ctx context.Context, cmd string, opts ExecOptions) (*ExecResult, error) {
+	start := time.Now()
+	rec := &AuditRecord{
+		Time:     start.UTC(),
+		Operator: a.opts.Operator,
+		User:     a.opts.User,
+		Host:     a.opts.Host,
+		Command:  cmd,
+		Terminal: opts.PTY,
+	}
+	var mu sync.Mutex
+	if a.opts.Output {
+		record := func(stream Stream, w io.Writer) io.Writer {
+			return writerFunc(func(b []byte) (int, error) {
+				mu.Lock()
+				rec.Events = append(rec.Events, AuditEvent{
+					Offset: time.Since(start),
+					Stream: stream,
+					Data:   append([]byte(nil), b...),
+				})
+				mu.Unlock()
+				if w != nil {
+					return w.Write(b)
+				}
+				return len(b), nil
+			})
+		}
+		opts.RawStdout, opts.RawStderr = record(Stdout, opts.RawStdout), record(Stderr, opts.RawStderr)
+	}
+
+	res, err := a.Executor.Run(ctx, cmd, opts)
+	if res != nil {
+		rec.ExitCode, rec.Duration = res.ExitCode, res.Duration
+		if a.opts.Output {
+			if opts.Discard {
+				var stdout, stderr strings.Builder
+				for _, ev := range rec.Events {
+					if ev.Stream == Stderr {
+						stderr.Write(ev.Data)
+					} else {
+						stdout.Write(ev.Data)
+					}
+				}
+				rec.Stdout, rec.Stderr = stdout.String(), stderr.String()
+			} else {
+				rec.Stdout, rec.Stderr = string(res.Stdout), string(res.Stderr)
+			}
+		}
+	} else {
+		rec.ExitCode, rec.Duration = -1, time.Since(start)
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if werr := a.write(rec); werr != nil && err == nil {
+		err = fmt.Errorf("audit: %w", werr)
+	}
+	return res, err
+}
+
+// write chains rec to the previous record and writes it to the sink.
+func (a *auditExecutor) write(rec *AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seq++
+	rec.Seq, rec.Prev = a.seq, a.prev
+	hash, err := rec.chainHash()
+	if err != nil {
+		return err
+	}
+	rec.Hash = hash
+	if err := a.sink.Write(rec); err != nil {
+		return err
+	}
+	a.prev = hash
+	return nil
+}
+
+// Close closes the executor and the sink.
+func (a *auditExecutor) Close() error {
+	err := a.Executor.Close()
+	if serr := a.sink.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// chainHash returns the hex SHA-256 of the JSON encoding of the record
+// without its Hash, which includes the hash of the previous record.
+func (r *AuditRecord) chainHash() (string, error) {
+	c := *r
+	c.Hash = ""
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ErrAuditChain is returned by VerifyAuditLog for a broken chain.
+var ErrAuditChain = errors.New("audit log chain broken")
+
+// VerifyAuditLog checks the hash chain of a JSONL audit log and returns
+// its last record. It fails with ErrAuditChain at the first record that
+// was altered, removed or reordered. Records cut from either end of the
+// log go unnoticed, so the first sequence number and the last hash should
+// be kept elsewhere and compared with the log.
+func VerifyAuditLog(r io.Reader) (*AuditRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	var last *AuditRecord
+	for n := 1; scanner.Scan(); n++ {
+		rec := &AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return last, fmt.Errorf("line %d: %w", n, err)
+		}
+		hash, err := rec.chainHash()
+		if err != nil {
+			return last, fmt.Errorf("line %d: %w", n, err)
+		}
+		if hash != rec.Hash {
+			return last, fmt.Errorf("line %d: %w: record %d altered", n, ErrAuditChain, rec.Seq)
+		}
+		if last != nil && (rec.Prev != last.Hash || rec.Seq != last.Seq+1) {
+			return last, fmt.Errorf("line %d: %w: record %d does not follow record %d", n, ErrAuditChain, rec.Seq, last.Seq)
+		}
+		last = rec
+	}
+	return last, scanner.Err()
+}
+
+type jsonlSink struct {
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLSink returns an AuditSink writing records to w as JSON lines,
+// the format read by VerifyAuditLog. It closes w if it is an io.Closer.
+func NewJSONLSink(w io.Writer) AuditSink {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonlSink{w: w, enc: enc}
+}
+
+func (s *jsonlSink) Write(rec *AuditRecord) error {
+	return s.enc.Encode(rec)
+}
+
+func (s *jsonlSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type asciicastSink struct {
+	dir string
+}
+
+// NewAsciicastSink returns an AuditSink writing each record to its own
+// asciicast v2 file in dir, named after its sequence number and hash,
+// to be replayed with asciinema play. The header carries the record
+// without its output.
+func NewAsciicastSink(dir string) AuditSink {
+	return &asciicastSink{dir: dir}
+}
+
+func (s *asciicastSink) Write(rec *AuditRecord) error {
+	name := fmt.Sprintf("%06d-%.12s.cast", rec.Seq, rec.Hash)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := WriteAsciicast(f, rec); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *asciicastSink) Close() error {
+	return nil
+}
+
+// WriteAsciicast writes rec as an asciicast v2 recording.
+func WriteAsciicast(w io.Writer, rec *AuditRecord) error {
+	width, height, term := 80, 24, "xterm"
+	if p := rec.Terminal; p != nil {
+		if p.Width > 0 && p.Height > 0 {
+			width, height = p.Width, p.Height
+		}
+		if p.Term != "" {
+			term = p.Term
+		}
+	}
+	meta := *rec
+	meta.Stdout, meta.Stderr = "", ""
+	header := struct {
+		Version   int               `json:"version"`
+		Width     int               `json:"width"`
+		Height    int               `json:"height"`
+		Timestamp int64             `json:"timestamp"`
+		Duration  float64           `json:"duration"`
+		Command   string            `json:"command"`
+		Title     string            `json:"title"`
+		Env       map[string]string `json:"env"`
+		Audit     *AuditRecord      `json:"audit"`
+	}{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: rec.Time.Unix(),
+		Duration:  rec.Duration.Seconds(),
+		Command:   rec.Command,
+		Title:     fmt.Sprintf("%s@%s: %s", rec.User, rec.Host, rec.Command),
+		Env:       map[string]string{"TERM": term},
+		Audit:     &meta,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, ev := range rec.Events {
+		data := string(ev.Data)
+		if rec.Terminal == nil {
+			// without a terminal the output has bare line feeds
+			data = strings.ReplaceAll(data, "\n", "\r\n")
+		}
+		if err := enc.Encode([]interface{}{ev.Offset.Seconds(), "o", data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type multiSink []AuditSink
+
+// MultiSink returns an AuditSink writing records to every sink in order,
+// stopping at the first error.
+func MultiSink(sinks ...AuditSink) AuditSink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) Write(rec *AuditRecord) error {
+	for _, s := range m {
+		if err := s.Write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiSink) Close() error {
+	var err error
+	for _, s := range m {
+		if cerr := s.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/mo-silent/go-devops/common/sshtest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	s := sshtest.NewServer(sshtest.Config{})
+	defer s.Close()
+	s.Handle("sudo -n -u root -- /bin/sh -c uptime", sshtest.Reply("up 3 days\n", 0))
+	s.Handle("sudo -n -u root -- /bin/sh -c false", sshtest.Reply("", 1))
+	s.Handle("sudo -n -u root -- /bin/sh -c reboot", sshtest.Reply("", 0))
+
+	var log bytes.Buffer
+	dir := t.TempDir()
+	e := Audit(
+		Become(NewExecutor(&SSH{Addr: s.Addr, User: "ops", HostKeyCallback: s.HostKeyCallback()}), BecomeOptions{}),
+		MultiSink(NewJSONLSink(&log), NewAsciicastSink(dir)),
+		AuditOptions{Operator: "alice", Output: true},
+	)
+	ctx := context.Background()
+	for _, cmd := range []string{"uptime", "false", "reboot"} {
+		if _, err := e.Run(ctx, cmd, ExecOptions{Discard: cmd == "uptime"}); err != nil {
+			t.Fatalf("Run(%s) error = %v", cmd, err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(bytes.NewReader(log.Bytes()))
+	for scanner.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	first := records[0]
+	if first.Operator != "alice" || first.User != "root" || first.Host != s.Addr || first.Command != "uptime" || first.Stdout != "up 3 days\n" || first.Prev != "" {
+		t.Errorf("record = %+v", first)
+	}
+	if records[1].ExitCode != 1 || records[1].Prev != first.Hash || records[2].Seq != 3 {
+		t.Errorf("records = %+v", records[1:])
+	}
+
+	last, err := VerifyAuditLog(bytes.NewReader(log.Bytes()))
+	if err != nil || last.Hash != records[2].Hash {
+		t.Errorf("VerifyAuditLog() = %v, %v", last, err)
+	}
+
+	cast, err := os.ReadFile(filepath.Join(dir, "000001-"+first.Hash[:12]+".cast"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(cast)), "\n")
+	var header struct {
+		Version int
+		Command string
+		Audit   AuditRecord
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil || header.Version != 2 || header.Command != "uptime" || header.Audit.Hash != first.Hash {
+		t.Errorf("asciicast header = %s, %v", lines[0], err)
+	}
+	var event []interface{}
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &event) != nil || event[1] != "o" || event[2] != "up 3 days\r\n" {
+		t.Errorf("asciicast events = %q", lines[1:])
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	var log bytes.Buffer
+	e := Audit(&recordExecutor{}, NewJSONLSink(&log), AuditOptions{Operator: "alice", Host: "web1"})
+	for _, cmd := range []string{"id", "uptime", "reboot"} {
+		e.Run(context.Background(), cmd, ExecOptions{})
+	}
+	lines := strings.SplitAfter(log.String(), "\n")[:3]
+
+	tests := []struct {
+		name    string
+		log     string
+		wantErr error
+	}{
+		{name: "intact", log: strings.Join(lines, "")},
+		{name: "altered", log: lines[0] + strings.Replace(lines[1], "uptime", "whoami", 1) + lines[2], wantErr: ErrAuditChain},
+		{name: "removed", log: lines[0] + lines[2], wantErr: ErrAuditChain},
+		{name: "reordered", log: lines[0] + lines[2] + lines[1], wantErr: ErrAuditChain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyAuditLog(strings.NewReader(tt.log)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyAuditLog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// a new executor continues the chain
+	last, _ := VerifyAuditLog(strings.NewReader(log.String()))
+	e = Audit(&recordExecutor{}, NewJSONLSink(&log), AuditOptions{Prev: last.Hash, Seq: last.Seq})
+	e.Run(context.Background(), "uptime", ExecOptions{})
+	if last, err := VerifyAuditLog(strings.NewReader(log.String())); err != nil || last.Seq != 4 {
+		t.Errorf("VerifyAuditLog() = %v, %v after continuing the chain", last, err)
+	}
+}
@@ -0,0 +1,378 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BecomeMethod is the privilege escalation method used by Become.
+type BecomeMethod int
+
+const (
+	// Sudo runs commands with sudo -u.
+	Sudo BecomeMethod = iota
+	// Su runs commands with su - in a login shell of the target user.
+	// Answering its password prompt requires a pseudo terminal, which
+	// is allocated when ExecOptions.PTY is nil.
+	Su
+)
+
+func (m BecomeMethod) String() string {
+	switch m {
+	case Sudo:
+		return "sudo"
+	case Su:
+		return "su"
+	}
+	return fmt.Sprintf("BecomeMethod(%d)", int(m))
+}
+
+// redacted replaces secrets in logs.
+const redacted = "********"
+
+// ErrBecomeAuth is returned when the escalation password is rejected.
+var ErrBecomeAuth = errors.New("become: incorrect password")
+
+// suPrompt matches the localized password prompts of su.
+var suPrompt = regexp.MustCompile(`(?i)(password|passwort|mot de passe|contraseña|senha|пароль|密码|密碼|パスワード|암호)[^:\n]*[:：]\s*$`)
+
+// BecomeOptions configures privilege escalation.
+type BecomeOptions struct {
+	Method BecomeMethod
+	// User is the target user, defaults to root.
+	User string
+	// Password answers the escalation prompt. Leave it empty for
+	// passwordless sudo, which then fails instead of prompting.
+	Password string
+}
+
+// String implements fmt.Stringer, redacting the password.
+func (o BecomeOptions) String() string {
+	passwd := ""
+	if o.Password != "" {
+		passwd = redacted
+	}
+	return fmt.Sprintf("{Method:%s User:%s Password:%s}", o.Method, o.User, passwd)
+}
+
+type becomeExecutor struct {
+	Executor
+	opts BecomeOptions
+}
+
+// Become returns an Executor running the commands of e as another user.
+// Commands are quoted for the remote shell and run by /bin/sh, with
+// ExecOptions.Env passed through env(1) since sudo and su reset the
+// environment. The password is only written to the command's input
+// once the escalation prompt is seen and never appears in the logs.
+func Become(e Executor, opts BecomeOptions) Executor {
+	if opts.User == "" {
+		opts.User = "root"
+	}
+	return &becomeExecutor{Executor: e, opts: opts}
+}
+
+// Run runs cmd as the target user.
+func (b *becomeExecutor) Run(ctx context.Context, cmd string, opts ExecOptions) (*ExecResult, error) {
+	if b.opts.Method != Sudo && b.opts.Method != Su {
+		return nil, fmt.Errorf("unsupported become method %s", b.opts.Method)
+	}
+	log.Debugf("become %s via %s", b.opts.User, b.opts.Method)
+	if b.opts.Password == "" {
+		wrapped := b.wrap(cmd, opts.Env, nil)
+		opts.Env = nil
+		return b.Executor.Run(ctx, wrapped, opts)
+	}
+
+	key := make([]byte, 8)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	w := &promptWatcher{
+		method:    b.opts.Method,
+		prompt:    fmt.Sprintf("[become %x] password:", key),
+		success:   "BECOME-SUCCESS-" + hex.EncodeToString(key),
+		password:  b.opts.Password,
+		prompted:  make(chan struct{}),
+		escalated: make(chan struct{}),
+		failed:    make(chan struct{}),
+	}
+	wrapped := b.wrap(cmd, opts.Env, w)
+	if b.opts.Method == Su && opts.PTY == nil {
+		opts.PTY = &PTY{}
+	}
+	opts.Env = nil
+
+	// the password, then the caller's input once escalated
+	stdinReader, stdinWriter := io.Pipe()
+	done := make(chan struct{})
+	defer close(done)
+	defer stdinReader.Close()
+	go func(stdin io.Reader) {
+		defer stdinWriter.Close()
+		select {
+		case <-w.prompted:
+			if _, err := io.WriteString(stdinWriter, w.password+"\n"); err != nil {
+				return
+			}
+		case <-w.escalated:
+		case <-done:
+			return
+		}
+		select {
+		case <-w.escalated:
+			if stdin != nil {
+				io.Copy(stdinWriter, stdin)
+			}
+		case <-w.failed:
+		case <-done:
+		}
+	}(opts.Stdin)
+	opts.Stdin = stdinReader
+
+	w.rawStdout = opts.RawStdout
+	opts.RawStdout = w.watch(Stdout, opts.RawStdout)
+	opts.RawStderr = w.watch(Stderr, opts.RawStderr)
+	if stdout, stderr, lines := opts.Stdout, opts.Stderr, opts.Lines; stdout != nil || stderr != nil || lines != nil {
+		emit := func(stream Stream, callback func(string)) func(string) {
+			return func(line string) {
+				line, ok := w.cleanLine(line)
+				if !ok {
+					return
+				}
+				if callback != nil {
+					callback(line)
+				}
+				if lines != nil {
+					select {
+					case lines <- Line{Stream: stream, Text: line}:
+					case <-ctx.Done():
+					}
+				}
+			}
+		}
+		opts.Stdout, opts.Stderr, opts.Lines = emit(Stdout, stdout), emit(Stderr, stderr), nil
+	}
+
+	res, err := b.Executor.Run(ctx, wrapped, opts)
+	w.flush()
+	if res != nil {
+		res.Stdout, res.Stderr = w.clean(res.Stdout), w.clean(res.Stderr)
+	}
+	if err == nil && w.authFailed() {
+		err = ErrBecomeAuth
+	}
+	return res, err
+}
+
+// wrap quotes cmd for the escalation command line. With a watcher
+// the command prints its success marker once escalated.
+func (b *becomeExecutor) wrap(cmd string, env map[string]string, w *promptWatcher) string {
+	if w != nil {
+		cmd = "echo " + w.success + "; " + cmd
+	}
+	inner := "/bin/sh -c " + ShellQuote(cmd)
+	if len(env) > 0 {
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		vars := make([]string, 0, len(keys))
+		for _, k := range keys {
+			vars = append(vars, ShellQuote(k+"="+env[k]))
+		}
+		inner = "env " + strings.Join(vars, " ") + " " + inner
+	}
+
+	user := ShellQuote(b.opts.User)
+	if b.opts.Method == Su {
+		return "su - " + user + " -c " + ShellQuote(inner)
+	}
+	if w == nil {
+		return "sudo -n -u " + user + " -- " + inner
+	}
+	return "sudo -S -p " + ShellQuote(w.prompt) + " -u " + user + " -- " + inner
+}
+
+// ShellQuote quotes s as a single word for a POSIX shell.
+func ShellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-+=./:@,%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// promptWatcher follows the raw output of an escalated command
+// to answer its password prompt and detect a successful escalation.
+type promptWatcher struct {
+	method   BecomeMethod
+	prompt   string
+	success  string
+	password string
+
+	prompted  chan struct{}
+	escalated chan struct{}
+	failed    chan struct{}
+
+	mu      sync.Mutex
+	pending [2][]byte
+	asked   bool
+	done    bool
+	denied  bool
+
+	// rawStdout receives the raw standard output from the success
+	// marker on, the output before it is held until the command ends
+	// in case the escalation fails.
+	rawStdout io.Writer
+	held      []byte
+	released  bool
+	// marked is set once the line of the success marker is emitted.
+	marked bool
+}
+
+// watch returns a writer feeding the watcher before forwarding to w
+// the output without the prompt and, on stdout, the success marker.
+func (p *promptWatcher) watch(stream Stream, w io.Writer) io.Writer {
+	return writerFunc(func(b []byte) (int, error) {
+		p.observe(stream, b)
+		if w == nil {
+			return len(b), nil
+		}
+		out := bytes.ReplaceAll(b, []byte(p.prompt), nil)
+		if stream == Stdout {
+			out = p.release(out)
+		}
+		if len(out) > 0 {
+			if _, err := w.Write(out); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	})
+}
+
+// release returns the raw standard output to forward: nothing until the
+// line of the success marker, then the output following it.
+func (p *promptWatcher) release(b []byte) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.released {
+		return b
+	}
+	p.held = append(p.held, b...)
+	i := bytes.Index(p.held, []byte(p.success))
+	if i < 0 {
+		return nil
+	}
+	j := bytes.IndexByte(p.held[i:], '\n')
+	if j < 0 {
+		return nil
+	}
+	out := p.held[i+j+1:]
+	p.held, p.released = nil, true
+	return out
+}
+
+// flush forwards the raw standard output held by a failed escalation.
+func (p *promptWatcher) flush() {
+	p.mu.Lock()
+	held := p.held
+	p.held = nil
+	p.mu.Unlock()
+	if len(held) > 0 && p.rawStdout != nil {
+		p.rawStdout.Write(held)
+	}
+}
+
+func (p *promptWatcher) observe(stream Stream, b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done || p.denied {
+		return
+	}
+	pending := append(p.pending[stream-1], b...)
+	for i := bytes.IndexByte(pending, '\n'); i >= 0; i = bytes.IndexByte(pending, '\n') {
+		if bytes.Contains(pending[:i], []byte(p.success)) {
+			p.done = true
+			close(p.escalated)
+			return
+		}
+		pending = pending[i+1:]
+	}
+	if p.isPrompt(pending) {
+		pending = nil
+		if p.asked {
+			p.denied = true
+			close(p.failed)
+			return
+		}
+		p.asked = true
+		close(p.prompted)
+	}
+	p.pending[stream-1] = append([]byte(nil), pending...)
+}
+
+// isPrompt reports whether the unterminated output line is a password prompt.
+func (p *promptWatcher) isPrompt(line []byte) bool {
+	if p.method == Su {
+		return suPrompt.Match(line)
+	}
+	return bytes.Contains(line, []byte(p.prompt))
+}
+
+// authFailed reports whether the password was rejected: prompted for
+// again, or answered without the command ever escalating, as su exits
+// after a single failed attempt.
+func (p *promptWatcher) authFailed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.denied || p.asked && !p.done
+}
+
+// cleanLine removes the escalation noise from an output line, reporting
+// false when nothing is left of it.
+func (p *promptWatcher) cleanLine(line string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if strings.Contains(line, p.success) {
+		p.marked = true
+		return "", false
+	}
+	cleaned := strings.ReplaceAll(line, p.prompt, "")
+	if p.method == Su && suPrompt.MatchString(cleaned) && !p.marked {
+		return "", false
+	}
+	return cleaned, cleaned != "" || line == ""
+}
+
+// clean removes the escalation noise from captured output.
+func (p *promptWatcher) clean(b []byte) []byte {
+	b = bytes.ReplaceAll(b, []byte(p.prompt), nil)
+	if i := bytes.Index(b, []byte(p.success)); i >= 0 {
+		end := i + len(p.success)
+		for end < len(b) && (b[end] == '\r' || b[end] == '\n') {
+			end++
+		}
+		// anything before the marker is escalation output
+		b = b[end:]
+	}
+	return b
+}
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
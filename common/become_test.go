@@ -0,0 +1,272 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "root", want: "root"},
+		{s: "/var/log/messages", want: "/var/log/messages"},
+		{s: "", want: "''"},
+		{s: "echo $HOME", want: "'echo $HOME'"},
+		{s: "it's", want: `'it'\''s'`},
+		{s: "a;rm -rf /", want: "'a;rm -rf /'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := ShellQuote(tt.s); got != tt.want {
+				t.Errorf("ShellQuote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBecomeOptions_String(t *testing.T) {
+	opts := BecomeOptions{Method: Su, User: "postgres", Password: "s3cret"}
+	if got := fmt.Sprintf("%v", opts); strings.Contains(got, "s3cret") {
+		t.Errorf("String() = %v, leaks the password", got)
+	}
+}
+
+// recordExecutor records the commands it is asked to run.
+type recordExecutor struct {
+	cmd  string
+	opts ExecOptions
+}
+
+func (r *recordExecutor) Run(_ context.Context, cmd string, opts ExecOptions) (*ExecResult, error) {
+	r.cmd, r.opts = cmd, opts
+	return &ExecResult{}, nil
+}
+
+func (r *recordExecutor) Close() error {
+	return nil
+}
+
+func TestBecome_passwordless(t *testing.T) {
+	tests := []struct {
+		name string
+		opts BecomeOptions
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "sudo",
+			opts: BecomeOptions{Method: Sudo},
+			want: `sudo -n -u root -- /bin/sh -c 'systemctl restart nginx && echo '\''done'\'''`,
+		},
+		{
+			name: "sudo env",
+			opts: BecomeOptions{Method: Sudo, User: "deploy"},
+			env:  map[string]string{"RELEASE": "v1 2", "APP": "web"},
+			want: `sudo -n -u deploy -- env APP=web 'RELEASE=v1 2' /bin/sh -c 'systemctl restart nginx && echo '\''done'\'''`,
+		},
+		{
+			name: "su",
+			opts: BecomeOptions{Method: Su, User: "postgres"},
+			want: `su - postgres -c '/bin/sh -c '\''systemctl restart nginx && echo '\''\'\'''\''done'\''\'\'''\'''\'''`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recordExecutor{}
+			if _, err := Become(r, tt.opts).Run(context.Background(), "systemctl restart nginx && echo 'done'", ExecOptions{Env: tt.env}); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if r.cmd != tt.want {
+				t.Errorf("Run() cmd = %v, want %v", r.cmd, tt.want)
+			}
+			if r.opts.Env != nil {
+				t.Errorf("Run() env = %v, want nil", r.opts.Env)
+			}
+		})
+	}
+}
+
+// sudoExecutor emulates sudo -S, prompting twice for its password.
+type sudoExecutor struct {
+	password string
+}
+
+func (s *sudoExecutor) Run(ctx context.Context, cmd string, opts ExecOptions) (*ExecResult, error) {
+	prompt := regexp.MustCompile(`-p '([^']*)'`).FindStringSubmatch(cmd)[1]
+	success := regexp.MustCompile(`BECOME-SUCCESS-[0-9a-f]+`).FindString(cmd)
+	w := &lineWriter{ctx: ctx, opts: opts}
+	res := &ExecResult{ExitCode: 1}
+	var stdout, stderr bytes.Buffer
+	stdin := bufio.NewReader(opts.Stdin)
+	for attempt := 0; attempt < 2; attempt++ {
+		w.copy(Stderr, strings.NewReader(prompt), &stderr)
+		passwd, err := stdin.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if strings.TrimSuffix(passwd, "\n") == s.password {
+			w.copy(Stdout, strings.NewReader(success+"\nroot\n"), &stdout)
+			input, _ := io.ReadAll(stdin)
+			w.copy(Stdout, bytes.NewReader(input), &stdout)
+			res.ExitCode = 0
+			break
+		}
+		w.copy(Stderr, strings.NewReader("Sorry, try again.\n"), &stderr)
+	}
+	res.Stdout, res.Stderr = stdout.Bytes(), stderr.Bytes()
+	return res, nil
+}
+
+func (s *sudoExecutor) Close() error {
+	return nil
+}
+
+func TestBecome_sudoPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		wantErr    error
+		wantLines  []string
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "accepted",
+			password:   "s3cret",
+			wantLines:  []string{"root", "input"},
+			wantStdout: "root\ninput\n",
+		},
+		{
+			name:       "rejected",
+			password:   "wrong",
+			wantErr:    ErrBecomeAuth,
+			wantLines:  []string{"Sorry, try again."},
+			wantStderr: "Sorry, try again.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			var raw bytes.Buffer
+			e := Become(&sudoExecutor{password: "s3cret"}, BecomeOptions{Password: tt.password})
+			res, err := e.Run(context.Background(), "id -un", ExecOptions{
+				Stdin:     strings.NewReader("input\n"),
+				Stdout:    func(line string) { lines = append(lines, line) },
+				Stderr:    func(line string) { lines = append(lines, line) },
+				RawStdout: &raw,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("Run() lines = %q, want %q", lines, tt.wantLines)
+			}
+			if string(res.Stdout) != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", res.Stdout, tt.wantStdout)
+			}
+			if string(res.Stderr) != tt.wantStderr {
+				t.Errorf("Run() stderr = %q, want %q", res.Stderr, tt.wantStderr)
+			}
+			if raw.String() != tt.wantStdout {
+				t.Errorf("Run() raw stdout = %q, want %q", raw.String(), tt.wantStdout)
+			}
+		})
+	}
+}
+
+// suExecutor emulates su - on a pseudo terminal, exiting
+// after a single failed attempt.
+type suExecutor struct {
+	password string
+}
+
+func (s *suExecutor) Run(ctx context.Context, cmd string, opts ExecOptions) (*ExecResult, error) {
+	success := regexp.MustCompile(`BECOME-SUCCESS-[0-9a-f]+`).FindString(cmd)
+	w := &lineWriter{ctx: ctx, opts: opts}
+	res := &ExecResult{ExitCode: 1}
+	var stdout bytes.Buffer
+	// a single terminal stream, as the prompt line ends after the answer
+	terminal, out := io.Pipe()
+	copied := make(chan struct{})
+	go func() {
+		w.copy(Stdout, terminal, &stdout)
+		close(copied)
+	}()
+	stdin := bufio.NewReader(opts.Stdin)
+	io.WriteString(out, "Password: ")
+	passwd, _ := stdin.ReadString('\n')
+	if opts.PTY != nil && strings.TrimSuffix(passwd, "\n") == s.password {
+		io.WriteString(out, "\r\n"+success+"\r\npostgres\r\n")
+		res.ExitCode = 0
+	} else {
+		io.WriteString(out, "\r\nsu: Authentication failure\r\n")
+	}
+	out.Close()
+	<-copied
+	res.Stdout = stdout.Bytes()
+	return res, nil
+}
+
+func (s *suExecutor) Close() error {
+	return nil
+}
+
+func TestBecome_suPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		wantErr    error
+		wantLines  []string
+		wantStdout string
+		wantRaw    string
+	}{
+		{
+			name:       "accepted",
+			password:   "s3cret",
+			wantLines:  []string{"postgres"},
+			wantStdout: "postgres\r\n",
+			wantRaw:    "postgres\r\n",
+		},
+		{
+			name:       "rejected",
+			password:   "wrong",
+			wantErr:    ErrBecomeAuth,
+			wantLines:  []string{"su: Authentication failure"},
+			wantStdout: "Password: \r\nsu: Authentication failure\r\n",
+			wantRaw:    "Password: \r\nsu: Authentication failure\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			var raw bytes.Buffer
+			e := Become(&suExecutor{password: "s3cret"}, BecomeOptions{Method: Su, User: "postgres", Password: tt.password})
+			res, err := e.Run(context.Background(), "id -un", ExecOptions{
+				Stdout:    func(line string) { lines = append(lines, line) },
+				RawStdout: &raw,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("Run() lines = %q, want %q", lines, tt.wantLines)
+			}
+			if string(res.Stdout) != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", res.Stdout, tt.wantStdout)
+			}
+			if raw.String() != tt.wantRaw {
+				t.Errorf("Run() raw stdout = %q, want %q", raw.String(), tt.wantRaw)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Executor is the interface that runs commands on a remote host.
+type Executor interface {
+	Run(ctx context.Context, cmd string, opts ExecOptions) (*ExecResult, error)
+	Close() error
+}
+
+// ExecOptions configures a single command execution.
+type ExecOptions struct {
+	// Stdin is piped to the standard input of the command.
+	Stdin io.Reader
+	// Env is set on the session before the command starts. Most servers
+	// only accept the variables listed in their AcceptEnv setting.
+	Env map[string]string
+	// PTY allocates a pseudo terminal for the command when not nil.
+	// The terminal merges standard error into standard output.
+	PTY *PTY
+	// Stdout is called with each line of standard output as it arrives.
+	Stdout func(line string)
+	// Stderr is called with each line of standard error as it arrives.
+	Stderr func(line string)
+	// Lines receives each line of output as it arrives. It is never
+	// closed by the executor and must be drained by the caller.
+	Lines chan<- Line
+	// RawStdout and RawStderr receive the output as it arrives,
+	// before it is split into lines. Writes to them are serialized,
+	// so the same writer may receive both.
+	RawStdout io.Writer
+	RawStderr io.Writer
+	// Discard stops the output from being kept in the ExecResult,
+	// for long-running commands that are only streamed.
+	Discard bool
+}
+
+// PTY describes the pseudo terminal requested for a command.
+type PTY struct {
+	// Term is the terminal type, defaults to xterm.
+	Term string
+	// Width and Height are the terminal size in characters,
+	// default to 80x24.
+	Width  int
+	Height int
+	// Modes are the terminal modes, defaults to echo disabled.
+	Modes ssh.TerminalModes
+}
+
+// Stream identifies the output stream a Line was read from.
+type Stream int
+
+// The output streams of a command.
+const (
+	Stdout Stream = iota + 1
+	Stderr
+)
+
+func (s Stream) String() string {
+	if s == Stderr {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// Line is a line of command output without its line terminator.
+type Line struct {
+	Stream Stream
+	Text   string
+}
+
+// ExecResult is the result of a command executed by an Executor.
+type ExecResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Duration time.Duration
+}
+
+type sshExecutor struct {
+	ssh *SSH
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// NewExecutor returns an Executor running commands over a single SSH
+// connection dialled with s on first use. A command exiting with a
+// non-zero status is not an error, its ExecResult carries the status.
+func NewExecutor(s *SSH) Executor {
+	return &sshExecutor{ssh: s}
+}
+
+// Run executes cmd in a new session and waits for it to exit.
+// Cancelling ctx kills the command.
+func (e *sshExecutor) Run(ctx context.Context, cmd string, opts ExecOptions) (*ExecResult, error) {
+	session, err := e.session()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	keys := make([]string, 0, len(opts.Env))
+	for k := range opts.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := session.Setenv(k, opts.Env[k]); err != nil {
+			return nil, fmt.Errorf("set environment variable %s: %w", k, err)
+		}
+	}
+	if pty := opts.PTY; pty != nil {
+		term, width, height, modes := pty.Term, pty.Width, pty.Height, pty.Modes
+		if term == "" {
+			term = "xterm"
+		}
+		if width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		if modes == nil {
+			modes = ssh.TerminalModes{ssh.ECHO: 0}
+		}
+		if err := session.RequestPty(term, height, width, modes); err != nil {
+			return nil, fmt.Errorf("request pty: %w", err)
+		}
+	}
+	var stdin io.WriteCloser
+	if opts.Stdin != nil {
+		if stdin, err = session.StdinPipe(); err != nil {
+			return nil, err
+		}
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("ssh %s@%s run: %s", e.ssh.User, e.ssh.Addr, cmd)
+	res := &ExecResult{}
+	start := time.Now()
+	if err := session.Start(cmd); err != nil {
+		return nil, err
+	}
+	if stdin != nil {
+		// copied here rather than by the session so
+		// that a blocked reader cannot stall Wait
+		go func() {
+			io.Copy(stdin, opts.Stdin)
+			stdin.Close()
+		}()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	out := &lineWriter{ctx: ctx, opts: opts}
+	var stdoutBuf, stderrBuf bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		out.copy(Stdout, stdout, &stdoutBuf)
+	}()
+	go func() {
+		defer wg.Done()
+		out.copy(Stderr, stderr, &stderrBuf)
+	}()
+	wg.Wait()
+	err = session.Wait()
+	res.Duration = time.Since(start)
+	res.Stdout, res.Stderr = stdoutBuf.Bytes(), stderrBuf.Bytes()
+
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitStatus()
+		return res, nil
+	}
+	if err != nil {
+		res.ExitCode = -1
+		return res, err
+	}
+	return res, nil
+}
+
+// Close closes the SSH connection.
+func (e *sshExecutor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client == nil {
+		return nil
+	}
+	err := e.client.Close()
+	e.client = nil
+	return err
+}
+
+// session opens a new session, redialling once
+// if the existing connection has dropped.
+func (e *sshExecutor) session() (*ssh.Session, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client != nil {
+		session, err := e.client.NewSession()
+		if err == nil {
+			return session, nil
+		}
+		e.client.Close()
+		e.client = nil
+	}
+	client, err := e.ssh.Dial()
+	if err != nil {
+		return nil, err
+	}
+	e.client = client
+	return client.NewSession()
+}
+
+// lineWriter delivers output lines to the callbacks and channel of opts,
+// one line at a time so the callbacks need not be safe for concurrent use.
+type lineWriter struct {
+	ctx   context.Context
+	opts  ExecOptions
+	mu    sync.Mutex
+	rawMu sync.Mutex // serializes the writes to the raw writers
+}
+
+// copy reads r until EOF and emits it line by line, keeping
+// the raw output in buf unless it is discarded.
+func (w *lineWriter) copy(stream Stream, r io.Reader, buf *bytes.Buffer) {
+	raw := w.opts.RawStdout
+	if stream == Stderr {
+		raw = w.opts.RawStderr
+	}
+	chunk := make([]byte, 32*1024)
+	var pending []byte
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			if raw != nil {
+				w.rawMu.Lock()
+				raw.Write(chunk[:n])
+				w.rawMu.Unlock()
+			}
+			if !w.opts.Discard {
+				buf.Write(chunk[:n])
+			}
+			pending = append(pending, chunk[:n]...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				w.emit(stream, string(bytes.TrimRight(pending[:i], "\r")))
+				pending = pending[i+1:]
+			}
+			pending = append([]byte(nil), pending...)
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				w.emit(stream, string(bytes.TrimRight(pending, "\r")))
+			}
+			return
+		}
+	}
+}
+
+func (w *lineWriter) emit(stream Stream, text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if stream == Stdout && w.opts.Stdout != nil {
+		w.opts.Stdout(text)
+	}
+	if stream == Stderr && w.opts.Stderr != nil {
+		w.opts.Stderr(text)
+	}
+	if w.opts.Lines != nil {
+		select {
+		case w.opts.Lines <- Line{Stream: stream, Text: text}:
+		case <-w.ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mo-silent/go-devops/common/sshtest"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter_copy(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		discard bool
+		want    []string
+		wantBuf string
+	}{
+		{
+			name:    "lines",
+			output:  "first\nsecond\n",
+			want:    []string{"first", "second"},
+			wantBuf: "first\nsecond\n",
+		},
+		{
+			name:    "pty line endings and partial last line",
+			output:  "Reading package lists...\r\n\r\nDone",
+			want:    []string{"Reading package lists...", "", "Done"},
+			wantBuf: "Reading package lists...\r\n\r\nDone",
+		},
+		{
+			name:    "discard",
+			output:  "tail\n",
+			discard: true,
+			want:    []string{"tail"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			var lines []Line
+			ch := make(chan Line, len(tt.want))
+			w := &lineWriter{ctx: context.Background(), opts: ExecOptions{
+				Stderr:  func(line string) { got = append(got, line) },
+				Lines:   ch,
+				Discard: tt.discard,
+			}}
+			var buf bytes.Buffer
+			w.copy(Stderr, strings.NewReader(tt.output), &buf)
+			close(ch)
+			for l := range ch {
+				if l.Stream != Stderr {
+					t.Errorf("copy() stream = %v, want %v", l.Stream, Stderr)
+				}
+				lines = append(lines, l)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("copy() lines = %q, want %q", got, tt.want)
+			}
+			if len(lines) != len(tt.want) {
+				t.Errorf("copy() sent %d lines, want %d", len(lines), len(tt.want))
+			}
+			if buf.String() != tt.wantBuf {
+				t.Errorf("copy() buffered = %q, want %q", buf.String(), tt.wantBuf)
+			}
+		})
+	}
+}
+
+func TestExecutor_Run(t *testing.T) {
+	server := sshtest.NewServer(sshtest.Config{})
+	defer server.Close()
+	server.Handle("yum upgrade", func(s *sshtest.Session) int {
+		fmt.Fprintln(s.Stdout, "Resolving dependencies")
+		fmt.Fprintln(s.Stderr, "warning: mirror slow")
+		fmt.Fprintln(s.Stdout, "Complete!")
+		return 0
+	})
+	server.Handle("cat", func(s *sshtest.Session) int {
+		io.Copy(s.Stdout, s.Stdin)
+		return 0
+	})
+	server.Handle("printenv APP", func(s *sshtest.Session) int {
+		fmt.Fprintln(s.Stdout, s.Env["APP"])
+		return 0
+	})
+	server.Handle("stty size", func(s *sshtest.Session) int {
+		if s.PTY == nil {
+			fmt.Fprintln(s.Stderr, "stty: 'standard input': Inappropriate ioctl for device")
+			return 1
+		}
+		fmt.Fprintf(s.Stdout, "%d %d\r\n", s.PTY.Height, s.PTY.Width)
+		return 0
+	})
+
+	tests := []struct {
+		name       string
+		cmd        string
+		opts       ExecOptions
+		wantLines  []Line
+		wantStdout string
+		wantCode   int
+	}{
+		{
+			name: "stream",
+			cmd:  "yum upgrade",
+			wantLines: []Line{
+				{Stream: Stdout, Text: "Resolving dependencies"},
+				{Stream: Stderr, Text: "warning: mirror slow"},
+				{Stream: Stdout, Text: "Complete!"},
+			},
+			wantStdout: "Resolving dependencies\nComplete!\n",
+		},
+		{
+			name:       "stdin",
+			cmd:        "cat",
+			opts:       ExecOptions{Stdin: strings.NewReader("a\nb")},
+			wantLines:  []Line{{Stream: Stdout, Text: "a"}, {Stream: Stdout, Text: "b"}},
+			wantStdout: "a\nb",
+		},
+		{
+			name:       "env",
+			cmd:        "printenv APP",
+			opts:       ExecOptions{Env: map[string]string{"APP": "web"}},
+			wantLines:  []Line{{Stream: Stdout, Text: "web"}},
+			wantStdout: "web\n",
+		},
+		{
+			name:       "pty",
+			cmd:        "stty size",
+			opts:       ExecOptions{PTY: &PTY{Width: 132, Height: 43}},
+			wantLines:  []Line{{Stream: Stdout, Text: "43 132"}},
+			wantStdout: "43 132\r\n",
+		},
+		{
+			name:      "exit code",
+			cmd:       "stty size",
+			wantLines: []Line{{Stream: Stderr, Text: "stty: 'standard input': Inappropriate ioctl for device"}},
+			wantCode:  1,
+		},
+	}
+	e := NewExecutor(&SSH{Addr: server.Addr, User: "root"})
+	defer e.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []Line
+			tt.opts.Stdout = func(line string) { lines = append(lines, Line{Stream: Stdout, Text: line}) }
+			tt.opts.Stderr = func(line string) { lines = append(lines, Line{Stream: Stderr, Text: line}) }
+			res, err := e.Run(context.Background(), tt.cmd, tt.opts)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if tt.name == "stream" {
+				// stdout and stderr interleave arbitrarily
+				sort.SliceStable(lines, func(i, j int) bool { return lines[i].Stream < lines[j].Stream })
+				sort.SliceStable(tt.wantLines, func(i, j int) bool { return tt.wantLines[i].Stream < tt.wantLines[j].Stream })
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("Run() lines = %v, want %v", lines, tt.wantLines)
+			}
+			if string(res.Stdout) != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", res.Stdout, tt.wantStdout)
+			}
+			if res.ExitCode != tt.wantCode {
+				t.Errorf("Run() exit code = %v, want %v", res.ExitCode, tt.wantCode)
+			}
+		})
+	}
+	t.Run("raw to one writer", func(t *testing.T) {
+		var raw bytes.Buffer
+		if _, err := e.Run(context.Background(), "yum upgrade", ExecOptions{RawStdout: &raw, RawStderr: &raw}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if want := len("Resolving dependencies\nwarning: mirror slow\nComplete!\n"); raw.Len() != want {
+			t.Errorf("Run() raw output = %q, want %d bytes", raw.String(), want)
+		}
+	})
+}
+
+func TestExecutor_Run_cancel(t *testing.T) {
+	server := sshtest.NewServer(sshtest.Config{})
+	defer server.Close()
+	server.Handle("tail -f /var/log/messages", func(s *sshtest.Session) int {
+		fmt.Fprintln(s.Stdout, "started")
+		<-s.Context().Done()
+		return 0
+	})
+
+	e := NewExecutor(&SSH{Addr: server.Addr, User: "root"})
+	defer e.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan Line)
+	go func() {
+		<-lines
+		cancel()
+	}()
+	_, err := e.Run(ctx, "tail -f /var/log/messages", ExecOptions{Lines: lines, Discard: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestExecutor_Run_reconnect(t *testing.T) {
+	server := sshtest.NewServer(sshtest.Config{})
+	defer server.Close()
+	server.Handle("true", sshtest.Reply("", 0))
+
+	e := NewExecutor(&SSH{Addr: server.Addr, User: "root"})
+	defer e.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := e.Run(context.Background(), "true", ExecOptions{}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		server.CloseConnections()
+	}
+}
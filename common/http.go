@@ -1,7 +1,9 @@
 package common
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,12 +14,29 @@ type DevopsHttpClient interface {
 	Post(ctx context.Context, addr string, payload io.Reader, headers map[string]string, params url.Values) ([]byte, error)
 }
 
+// StatusError is the error of a request answered
+// with a status other than 2xx.
+type StatusError struct {
+	Status     string // such as 404 Not Found
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	msg := bytes.TrimSpace(e.Body)
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	return fmt.Sprintf("server returned %s: %s", e.Status, msg)
+}
+
 type newHttp struct {
 	Client http.Client
 }
 
 // Get is an HTTP GET method that returns a byte slice
-// of the body of the GET request.
+// of the body of the GET request. A status other than
+// 2xx is a *StatusError holding the body.
 func (h *newHttp) Get(ctx context.Context, addr string, headers map[string]string) ([]byte, error) {
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
@@ -38,6 +57,9 @@ func (h *newHttp) Get(ctx context.Context, addr string, headers map[string]strin
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{Status: resp.Status, StatusCode: resp.StatusCode, Body: bodyRes}
+	}
 	//defer close(body)
 	return bodyRes, nil
 }
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Interpreters known to RunScript. Any other command line,
+// such as "/usr/bin/env perl", may be used as well.
+const (
+	Bash   = "bash"
+	Sh     = "sh"
+	Python = "python3"
+)
+
+// scriptCleanupTimeout bounds the removal of the uploaded script,
+// which still runs when the context of RunScript is cancelled.
+const scriptCleanupTimeout = 30 * time.Second
+
+// Script is a text/template rendered and run on a remote host.
+type Script struct {
+	// Name identifies the script in logs and errors.
+	Name string
+	// Template is the text/template source of the script. Executing it
+	// with a missing variable is an error. The quote function quotes a
+	// value for the shell and json encodes it, for Python literals.
+	Template string
+	// Vars is the data the template is executed with,
+	// typically the variables of the target host.
+	Vars map[string]interface{}
+	// Funcs are added to the functions of the template.
+	Funcs template.FuncMap
+	// Interpreter runs the uploaded script, defaults to Sh. It is
+	// used as is on the command line and must be quoted if needed.
+	Interpreter string
+	// Args are passed to the script, quoted for the shell.
+	Args []string
+	// TempDir is the remote directory the script is uploaded to,
+	// defaults to /tmp.
+	TempDir string
+}
+
+// ScriptResult is the result of a script run by RunScript.
+type ScriptResult struct {
+	*ExecResult
+	Name string
+	// Path is the remote path the script was uploaded to.
+	Path string
+	// Script is the rendered script.
+	Script string
+}
+
+// Success reports whether the script exited with status zero.
+func (r *ScriptResult) Success() bool {
+	return r.ExitCode == 0
+}
+
+// JSON decodes the standard output of the script into v,
+// for scripts reporting structured results.
+func (r *ScriptResult) JSON(v interface{}) error {
+	return json.Unmarshal(r.Stdout, v)
+}
+
+// Render executes the template of s with its variables.
+func (s Script) Render() (string, error) {
+	funcs := template.FuncMap{
+		"quote": func(v interface{}) string { return ShellQuote(fmt.Sprint(v)) },
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+	for name, fn := range s.Funcs {
+		funcs[name] = fn
+	}
+	tmpl, err := template.New(s.Name).Funcs(funcs).Option("missingkey=error").Parse(s.Template)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, s.Vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RunScript renders s, uploads it through e to a private temporary file
+// and runs it with its interpreter, passing opts to the run. The file is
+// removed afterwards, even when the script fails or ctx is cancelled. As
+// with Run, a script exiting with a non-zero status is not an error.
+func RunScript(ctx context.Context, e Executor, s Script, opts ExecOptions) (*ScriptResult, error) {
+	script, err := s.Render()
+	if err != nil {
+		return nil, fmt.Errorf("render script %s: %w", s.Name, err)
+	}
+	interpreter, dir := s.Interpreter, s.TempDir
+	if interpreter == "" {
+		interpreter = Sh
+	}
+	if dir == "" {
+		dir = "/tmp"
+	}
+
+	upload := "umask 077 && f=$(mktemp " + ShellQuote(strings.TrimSuffix(dir, "/")+"/go-devops-script.XXXXXXXX") + `) && { cat > "$f" || { rm -f "$f"; exit 1; }; } && echo "$f"`
+	res, err := e.Run(ctx, upload, ExecOptions{Stdin: strings.NewReader(script)})
+	if err != nil {
+		return nil, fmt.Errorf("upload script %s: %w", s.Name, err)
+	}
+	path := strings.TrimSpace(string(res.Stdout))
+	if res.ExitCode != 0 || path == "" {
+		return nil, fmt.Errorf("upload script %s: exit status %d: %s", s.Name, res.ExitCode, bytes.TrimSpace(res.Stderr))
+	}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), scriptCleanupTimeout)
+		defer cancel()
+		res, err := e.Run(cleanupCtx, "rm -f -- "+ShellQuote(path), ExecOptions{})
+		if err == nil && res.ExitCode != 0 {
+			err = fmt.Errorf("exit status %d: %s", res.ExitCode, bytes.TrimSpace(res.Stderr))
+		}
+		if err != nil {
+			log.Warnf("remove script %s at %s: %v", s.Name, path, err)
+		}
+	}()
+
+	cmd := interpreter + " " + ShellQuote(path)
+	for _, arg := range s.Args {
+		cmd += " " + ShellQuote(arg)
+	}
+	log.Debugf("run script %s with %s", s.Name, interpreter)
+	res, err = e.Run(ctx, cmd, opts)
+	if res == nil {
+		return nil, err
+	}
+	return &ScriptResult{ExecResult: res, Name: s.Name, Path: path, Script: script}, err
+}
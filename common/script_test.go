@@ -0,0 +1,168 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common/sshtest"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptServer emulates the upload, run and removal of scripts.
+func scriptServer(t *testing.T) *sshtest.Server {
+	t.Helper()
+	s := sshtest.NewServer(sshtest.Config{})
+	t.Cleanup(s.Close)
+	n := 0
+	s.HandlePattern(`^umask 077 && f=\$\(mktemp '?/`, func(sess *sshtest.Session) int {
+		dir := regexp.MustCompile(`mktemp '?(/[^/]*)/`).FindStringSubmatch(sess.Command)[1]
+		if _, err := sess.FS.Stat(dir); err != nil {
+			fmt.Fprintf(sess.Stderr, "mktemp: failed to create file via template\n")
+			return 1
+		}
+		n++
+		path := fmt.Sprintf("%s/go-devops-script.%08d", dir, n)
+		data, _ := io.ReadAll(sess.Stdin)
+		sess.FS.WriteFile(path, data, 0600)
+		fmt.Fprintln(sess.Stdout, path)
+		return 0
+	})
+	s.HandlePattern(`^(bash|python3) /tmp/`, func(sess *sshtest.Session) int {
+		fields := strings.Fields(sess.Command)
+		data, err := sess.FS.ReadFile(fields[1])
+		if err != nil {
+			return 127
+		}
+		fmt.Fprintf(sess.Stdout, "%s", data)
+		if strings.Contains(string(data), "sleep") {
+			<-sess.Context().Done()
+			return 137
+		}
+		if len(fields) > 2 {
+			fmt.Fprintf(sess.Stderr, "args: %s\n", strings.Join(fields[2:], " "))
+			return 2
+		}
+		return 0
+	})
+	s.HandlePattern(`^rm -f -- /tmp/`, func(sess *sshtest.Session) int {
+		sess.FS.Remove(strings.TrimPrefix(sess.Command, "rm -f -- "))
+		return 0
+	})
+	return s
+}
+
+func TestRunScript(t *testing.T) {
+	s := scriptServer(t)
+	e := NewExecutor(&SSH{Addr: s.Addr, User: "ops", HostKeyCallback: s.HostKeyCallback()})
+	defer e.Close()
+
+	tests := []struct {
+		name       string
+		script     Script
+		want       string
+		wantStderr string
+		wantCode   int
+		wantErr    bool
+		wantRuns   int
+	}{
+		{
+			name: "bash",
+			script: Script{
+				Name:        "motd",
+				Template:    "echo {{quote .motd}} > /etc/motd\n",
+				Vars:        map[string]interface{}{"motd": "it's prod"},
+				Interpreter: Bash,
+			},
+			want:     "echo 'it'\\''s prod' > /etc/motd\n",
+			wantRuns: 3,
+		},
+		{
+			name: "python args",
+			script: Script{
+				Name:        "hosts",
+				Template:    "hosts = {{json .hosts}}\n",
+				Vars:        map[string]interface{}{"hosts": []string{"web1", "web2"}},
+				Interpreter: Python,
+				Args:        []string{"--check", "a b"},
+			},
+			want:       "hosts = [\"web1\",\"web2\"]\n",
+			wantStderr: "args: --check 'a b'\n",
+			wantCode:   2,
+			wantRuns:   3,
+		},
+		{
+			name:    "missing variable",
+			script:  Script{Name: "motd", Template: "echo {{.motd}}"},
+			wantErr: true,
+		},
+		{
+			name:     "upload failure",
+			script:   Script{Name: "motd", Template: "true", TempDir: "/missing"},
+			wantErr:  true,
+			wantRuns: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(s.Commands())
+			res, err := RunScript(context.Background(), e, tt.script, ExecOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if runs := len(s.Commands()) - before; runs != tt.wantRuns {
+				t.Errorf("RunScript() ran %d commands, want %d: %v", runs, tt.wantRuns, s.Commands()[before:])
+			}
+			if err != nil {
+				return
+			}
+			if string(res.Stdout) != tt.want || res.Script != tt.want {
+				t.Errorf("RunScript() stdout = %q, script = %q, want %q", res.Stdout, res.Script, tt.want)
+			}
+			if string(res.Stderr) != tt.wantStderr || res.ExitCode != tt.wantCode {
+				t.Errorf("RunScript() = %d %q, want %d %q", res.ExitCode, res.Stderr, tt.wantCode, tt.wantStderr)
+			}
+			if _, err := s.FS.Stat(res.Path); !os.IsNotExist(err) {
+				t.Errorf("RunScript() left %s behind", res.Path)
+			}
+		})
+	}
+}
+
+func TestRunScript_cancel(t *testing.T) {
+	s := scriptServer(t)
+	e := NewExecutor(&SSH{Addr: s.Addr, User: "ops", HostKeyCallback: s.HostKeyCallback()})
+	defer e.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	res, err := RunScript(ctx, e, Script{Name: "wait", Template: "sleep 60\n", Interpreter: Bash}, ExecOptions{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("RunScript() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := s.FS.Stat(res.Path); !os.IsNotExist(err) {
+		t.Errorf("RunScript() left %s behind", res.Path)
+	}
+	cmds := s.Commands()
+	if got, want := cmds[len(cmds)-1], "rm -f -- "+res.Path; got != want {
+		t.Errorf("RunScript() last command = %v, want %v", got, want)
+	}
+}
+
+func TestScriptResult_JSON(t *testing.T) {
+	res := &ScriptResult{ExecResult: &ExecResult{Stdout: []byte(`{"changed":true,"files":["a"]}`)}}
+	var got struct {
+		Changed bool
+		Files   []string
+	}
+	if err := res.JSON(&got); err != nil || !got.Changed || !reflect.DeepEqual(got.Files, []string{"a"}) {
+		t.Errorf("JSON() = %+v, %v", got, err)
+	}
+	if !res.Success() {
+		t.Errorf("Success() = false, want true")
+	}
+}
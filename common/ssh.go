@@ -3,11 +3,55 @@ package common
 import (
 	"golang.org/x/crypto/ssh"
 	"os"
+	"time"
 )
 
+// SSH describes a remote host reachable over SSH.
 type SSH struct {
 	Addr string
 	User string
+	// Auth is the list of authentication methods used by Dial.
+	Auth []ssh.AuthMethod
+	// HostKeyCallback verifies the server host key. Dial accepts
+	// any host key when it is nil, like the Execute methods do.
+	HostKeyCallback ssh.HostKeyCallback
+	// Timeout bounds the TCP connect and handshake of Dial,
+	// zero means no timeout.
+	Timeout time.Duration
+}
+
+// Dial establishes a new SSH connection to s.Addr
+// authenticated with s.Auth.
+func (s *SSH) Dial() (*ssh.Client, error) {
+	hostKeyCallback := s.HostKeyCallback
+	if hostKeyCallback == nil {
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+	config := &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            s.Auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         s.Timeout,
+	}
+	return ssh.Dial("tcp", s.Addr, config)
+}
+
+// Password returns an ssh.AuthMethod using password authentication.
+func Password(passwd string) ssh.AuthMethod {
+	return ssh.Password(passwd)
+}
+
+// KeyFile returns an ssh.AuthMethod using the private key in file.
+func KeyFile(file string) (ssh.AuthMethod, error) {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ssh.ParsePrivateKey(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.PublicKeys(key), nil
 }
 
 func (s *SSH) ExecuteWithPasswd(passwd, cmd string) ([]byte, error) {
@@ -1,11 +1,29 @@
 package common
 
 import (
+	"github.com/mo-silent/go-devops/common/sshtest"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
 
 func TestSSH_ExecuteWithKeyFile(t *testing.T) {
+	signer, pemKey, err := sshtest.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "devops-ssh-key.key")
+	if err := os.WriteFile(file, pemKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	server := sshtest.NewServer(sshtest.Config{
+		AuthorizedKeys: map[string][]ssh.PublicKey{"ec2-user": {signer.PublicKey()}},
+	})
+	defer server.Close()
+	server.Handle("pwd", sshtest.Reply("/home/ec2-user\n", 0))
+
 	type fields struct {
 		Addr string
 		User string
@@ -24,15 +42,27 @@ func TestSSH_ExecuteWithKeyFile(t *testing.T) {
 		{
 			name: "test2",
 			fields: fields{
-				Addr: "10.12.41.53:22",
+				Addr: server.Addr,
 				User: "ec2-user",
 			},
 			args: args{
-				file: "E:\\ssh\\devops-ssh-key.key",
+				file: file,
 				cmd:  "pwd",
 			},
 			want: []byte{47, 104, 111, 109, 101, 47, 101, 99, 50, 45, 117, 115, 101, 114, 10},
 		},
+		{
+			name: "unknown user",
+			fields: fields{
+				Addr: server.Addr,
+				User: "root",
+			},
+			args: args{
+				file: file,
+				cmd:  "pwd",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -53,6 +83,12 @@ func TestSSH_ExecuteWithKeyFile(t *testing.T) {
 }
 
 func TestSSH_ExecuteWithPasswd(t *testing.T) {
+	server := sshtest.NewServer(sshtest.Config{
+		Passwords: map[string]string{"root": "123456"},
+	})
+	defer server.Close()
+	server.Handle("pwd", sshtest.Reply("/root\n", 0))
+
 	type fields struct {
 		Addr string
 		User string
@@ -71,7 +107,7 @@ func TestSSH_ExecuteWithPasswd(t *testing.T) {
 		{
 			name: "test",
 			fields: fields{
-				Addr: "10.65.2.57:22",
+				Addr: server.Addr,
 				User: "root",
 			},
 			args: args{
@@ -80,6 +116,18 @@ func TestSSH_ExecuteWithPasswd(t *testing.T) {
 			},
 			want: []byte{47, 114, 111, 111, 116, 10},
 		},
+		{
+			name: "wrong password",
+			fields: fields{
+				Addr: server.Addr,
+				User: "root",
+			},
+			args: args{
+				passwd: "654321",
+				cmd:    "pwd",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -98,3 +146,44 @@ func TestSSH_ExecuteWithPasswd(t *testing.T) {
 		})
 	}
 }
+
+func TestSSH_Dial(t *testing.T) {
+	server := sshtest.NewServer(sshtest.Config{
+		Passwords: map[string]string{"root": "123456"},
+	})
+	defer server.Close()
+	other := sshtest.NewServer(sshtest.Config{})
+	defer other.Close()
+
+	tests := []struct {
+		name    string
+		ssh     SSH
+		wantErr bool
+	}{
+		{
+			name: "known host key",
+			ssh:  SSH{Addr: server.Addr, User: "root", Auth: []ssh.AuthMethod{Password("123456")}, HostKeyCallback: server.HostKeyCallback()},
+		},
+		{
+			name:    "changed host key",
+			ssh:     SSH{Addr: server.Addr, User: "root", Auth: []ssh.AuthMethod{Password("123456")}, HostKeyCallback: other.HostKeyCallback()},
+			wantErr: true,
+		},
+		{
+			name:    "no auth",
+			ssh:     SSH{Addr: server.Addr, User: "root"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := tt.ssh.Dial()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if client != nil {
+				client.Close()
+			}
+		})
+	}
+}
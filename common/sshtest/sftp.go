@@ -0,0 +1,323 @@
+package sshtest
+
+import (
+	"bytes"
+	"errors"
+	"github.com/pkg/sftp"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is an in-memory file system. It is served over SFTP by the Server
+// and shared with the command handlers through Session.FS.
+type FS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+type memFile struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// NewFS returns a file system holding the / and /tmp directories.
+func NewFS() *FS {
+	now := time.Now()
+	return &FS{files: map[string]*memFile{
+		"/":    {mode: os.ModeDir | 0755, modTime: now},
+		"/tmp": {mode: os.ModeDir | os.ModeSticky | 0777, modTime: now},
+	}}
+}
+
+// WriteFile writes data to the named file, creating it and
+// its parent directories if necessary.
+func (fs *FS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	name = clean(name)
+	if err := fs.mkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	if f, ok := fs.files[name]; ok && f.mode.IsDir() {
+		return &os.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+	fs.files[name] = &memFile{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// ReadFile returns the contents of the named file.
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.files[clean(name)]
+	if !ok || f.mode.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// Stat returns the os.FileInfo of the named file.
+func (fs *FS) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	name = clean(name)
+	f, ok := fs.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return f.info(name), nil
+}
+
+// Remove removes the named file or empty directory.
+func (fs *FS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.remove(clean(name), false)
+}
+
+// Chmod changes the permission bits of the named file.
+func (fs *FS) Chmod(name string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.files[clean(name)]
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	f.mode = f.mode.Type() | perm.Perm()
+	return nil
+}
+
+func (fs *FS) mkdirAll(name string) error {
+	if f, ok := fs.files[name]; ok {
+		if !f.mode.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+	if err := fs.mkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	fs.files[name] = &memFile{mode: os.ModeDir | 0755, modTime: time.Now()}
+	return nil
+}
+
+func (fs *FS) remove(name string, dir bool) error {
+	f, ok := fs.files[name]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if dir && !f.mode.IsDir() {
+		return errors.New("not a directory")
+	}
+	if f.mode.IsDir() {
+		if len(fs.children(name)) > 0 {
+			return errors.New("directory not empty")
+		}
+		if name == "/" {
+			return os.ErrPermission
+		}
+	}
+	delete(fs.files, name)
+	return nil
+}
+
+// children returns the names of the entries of the directory name.
+func (fs *FS) children(name string) []string {
+	var names []string
+	for p := range fs.files {
+		if p != "/" && path.Dir(p) == name {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (fs *FS) handlers() sftp.Handlers {
+	h := sftpHandler{fs}
+	return sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+func clean(name string) string {
+	return path.Clean("/" + name)
+}
+
+func (f *memFile) info(name string) os.FileInfo {
+	return &fileInfo{name: path.Base(name), size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// sftpHandler implements the sftp request server handlers on an FS.
+type sftpHandler struct {
+	fs *FS
+}
+
+func (h sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	data, err := h.fs.ReadFile(r.Filepath)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (h sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	fs := h.fs
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	flags := r.Pflags()
+	f, ok := fs.files[r.Filepath]
+	switch {
+	case ok && f.mode.IsDir():
+		return nil, os.ErrInvalid
+	case ok && flags.Creat && flags.Excl:
+		return nil, os.ErrExist
+	case !ok && !flags.Creat:
+		return nil, os.ErrNotExist
+	case !ok:
+		parent, ok := fs.files[path.Dir(r.Filepath)]
+		if !ok || !parent.mode.IsDir() {
+			return nil, os.ErrNotExist
+		}
+		f = &memFile{mode: 0644}
+		fs.files[r.Filepath] = f
+	}
+	if flags.Trunc {
+		f.data = nil
+	}
+	f.modTime = time.Now()
+	return &fileWriter{fs: fs, file: f, append: flags.Append}, nil
+}
+
+func (h sftpHandler) Filecmd(r *sftp.Request) error {
+	fs := h.fs
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	switch r.Method {
+	case "Setstat":
+		f, ok := fs.files[r.Filepath]
+		if !ok {
+			return os.ErrNotExist
+		}
+		attrs := r.Attributes()
+		if r.AttrFlags().Permissions {
+			f.mode = f.mode.Type() | attrs.FileMode().Perm()
+		}
+		if r.AttrFlags().Size && !f.mode.IsDir() {
+			size := int(attrs.Size)
+			if size < len(f.data) {
+				f.data = f.data[:size]
+			} else {
+				f.data = append(f.data, make([]byte, size-len(f.data))...)
+			}
+		}
+		return nil
+	case "Rename":
+		f, ok := fs.files[r.Filepath]
+		if !ok {
+			return os.ErrNotExist
+		}
+		if f.mode.IsDir() {
+			return errors.New("renaming directories is not supported")
+		}
+		if _, ok := fs.files[path.Dir(r.Target)]; !ok {
+			return os.ErrNotExist
+		}
+		delete(fs.files, r.Filepath)
+		fs.files[r.Target] = f
+		return nil
+	case "Remove":
+		if f, ok := fs.files[r.Filepath]; ok && f.mode.IsDir() {
+			return errors.New("is a directory")
+		}
+		return fs.remove(r.Filepath, false)
+	case "Rmdir":
+		return fs.remove(r.Filepath, true)
+	case "Mkdir":
+		if _, ok := fs.files[r.Filepath]; ok {
+			return os.ErrExist
+		}
+		if parent, ok := fs.files[path.Dir(r.Filepath)]; !ok || !parent.mode.IsDir() {
+			return os.ErrNotExist
+		}
+		fs.files[r.Filepath] = &memFile{mode: os.ModeDir | 0755, modTime: time.Now()}
+		return nil
+	}
+	return errors.New("unsupported: " + strings.ToLower(r.Method))
+}
+
+func (h sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	fs := h.fs
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.files[r.Filepath]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	switch r.Method {
+	case "List":
+		if !f.mode.IsDir() {
+			return nil, errors.New("not a directory")
+		}
+		var infos listerAt
+		for _, name := range fs.children(r.Filepath) {
+			infos = append(infos, fs.files[name].info(name))
+		}
+		return infos, nil
+	case "Stat":
+		return listerAt{f.info(r.Filepath)}, nil
+	}
+	return nil, errors.New("unsupported: " + strings.ToLower(r.Method))
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+type fileWriter struct {
+	fs     *FS
+	file   *memFile
+	append bool
+}
+
+func (w *fileWriter) WriteAt(p []byte, off int64) (int, error) {
+	w.fs.mu.Lock()
+	defer w.fs.mu.Unlock()
+	f := w.file
+	if w.append {
+		off = int64(len(f.data))
+	}
+	if end := int(off) + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	copy(f.data[off:], p)
+	f.modTime = time.Now()
+	return len(p), nil
+}
@@ -0,0 +1,520 @@
+/*
+Package sshtest provides an in-process SSH server for testing.
+
+The server listens on a random loopback port and runs scripted command
+handlers instead of a shell. It supports password and public key
+authentication, environment variables, pseudo terminals, local and remote
+port forwarding, and an in-memory file system served over SFTP.
+*/
+package sshtest // import "github.com/mo-silent/go-devops/common/sshtest"
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// Handler runs a command received by the Server
+// and returns its exit status.
+type Handler func(s *Session) int
+
+// Session is a command execution on the Server.
+type Session struct {
+	User    string
+	Command string
+	Env     map[string]string
+	// PTY is the requested pseudo terminal, nil if none was requested.
+	// Standard error is merged into standard output when it is set.
+	PTY    *PTY
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// FS is the file system of the Server.
+	FS *FS
+
+	ctx context.Context
+}
+
+// Context returns a context cancelled when the client
+// signals the command or closes the session.
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+// PTY is a pseudo terminal requested by the client.
+type PTY struct {
+	Term   string
+	Width  int
+	Height int
+}
+
+// Config configures a Server.
+type Config struct {
+	// Passwords maps user names to the passwords they may log in with.
+	Passwords map[string]string
+	// AuthorizedKeys maps user names to the public keys they may log in with.
+	AuthorizedKeys map[string][]ssh.PublicKey
+	// HostKey is the server host key, generated when nil.
+	HostKey ssh.Signer
+}
+
+// Server is an SSH server listening on a random loopback port.
+// When neither passwords nor authorized keys are configured
+// clients are accepted without authentication.
+type Server struct {
+	// Addr is the address the server listens on, as host:port.
+	Addr string
+	// HostKey is the server host key.
+	HostKey ssh.Signer
+	// FS is the file system served over SFTP.
+	FS *FS
+
+	config   *ssh.ServerConfig
+	listener net.Listener
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	patterns []pattern
+	fallback Handler
+	commands []string
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+type pattern struct {
+	re      *regexp.Regexp
+	handler Handler
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer(cfg Config) *Server {
+	hostKey := cfg.HostKey
+	if hostKey == nil {
+		var err error
+		if hostKey, _, err = GenerateKey(); err != nil {
+			panic(fmt.Sprintf("sshtest: generate host key: %v", err))
+		}
+	}
+
+	config := &ssh.ServerConfig{
+		NoClientAuth: len(cfg.Passwords) == 0 && len(cfg.AuthorizedKeys) == 0,
+	}
+	if len(cfg.Passwords) > 0 {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if want, ok := cfg.Passwords[conn.User()]; ok && want == string(password) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		}
+	}
+	if len(cfg.AuthorizedKeys) > 0 {
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, k := range cfg.AuthorizedKeys[conn.User()] {
+				if bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown public key for %s", conn.User())
+		}
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("sshtest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		HostKey:  hostKey,
+		FS:       NewFS(),
+		config:   config,
+		listener: listener,
+		handlers: make(map[string]Handler),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Handle registers the handler for an exact command line.
+func (s *Server) Handle(cmd string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[cmd] = handler
+}
+
+// HandlePattern registers the handler for command lines matching expr.
+// Patterns are tried in registration order after the exact commands.
+func (s *Server) HandlePattern(expr string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patterns = append(s.patterns, pattern{re: regexp.MustCompile(expr), handler: handler})
+}
+
+// HandleDefault registers the handler for commands matching no other
+// handler. By default they fail with status 127 like an unknown command.
+func (s *Server) HandleDefault(handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = handler
+}
+
+// Commands returns the command lines executed so far.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// HostKeyCallback returns a callback accepting only the server host key.
+func (s *Server) HostKeyCallback() ssh.HostKeyCallback {
+	return ssh.FixedHostKey(s.HostKey.PublicKey())
+}
+
+// CloseConnections closes the connections of all clients,
+// simulating a network failure.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close shuts down the server and closes all client connections.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.CloseConnections()
+	s.wg.Wait()
+}
+
+// Reply returns a Handler writing stdout and exiting with code.
+func Reply(stdout string, code int) Handler {
+	return func(s *Session) int {
+		io.WriteString(s.Stdout, stdout)
+		return code
+	}
+}
+
+// GenerateKey generates an ECDSA key, returning it as a signer
+// and as the PEM encoded private key for a key file.
+func GenerateKey() (ssh.Signer, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+
+	forwards := &forwards{conn: serverConn, listeners: make(map[string]net.Listener)}
+	defer forwards.close()
+	go forwards.handle(reqs)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for newChan := range chans {
+		newChan := newChan
+		switch newChan.ChannelType() {
+		case "session":
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.session(serverConn, newChan)
+			}()
+		case "direct-tcpip":
+			go directTCPIP(newChan)
+		default:
+			newChan.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+func (s *Server) session(conn *ssh.ServerConn, newChan ssh.NewChannel) {
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sess := &Session{User: conn.User(), Env: make(map[string]string), FS: s.FS, ctx: ctx}
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var kv struct{ Name, Value string }
+			if err := ssh.Unmarshal(req.Payload, &kv); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			sess.Env[kv.Name] = kv.Value
+			req.Reply(true, nil)
+		case "pty-req":
+			var pty struct {
+				Term                  string
+				Columns, Rows, Pw, Ph uint32
+				Modes                 string
+			}
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			sess.PTY = &PTY{Term: pty.Term, Width: int(pty.Columns), Height: int(pty.Rows)}
+			req.Reply(true, nil)
+		case "exec":
+			var exec struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &exec); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			sess.Command = exec.Command
+			go s.exec(ch, sess)
+		case "subsystem":
+			var sub struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &sub); err != nil || sub.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go func() {
+				server := sftp.NewRequestServer(ch, s.FS.handlers())
+				server.Serve()
+				server.Close()
+			}()
+		case "signal":
+			cancel()
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *Server) exec(ch ssh.Channel, sess *Session) {
+	s.mu.Lock()
+	s.commands = append(s.commands, sess.Command)
+	handler, ok := s.handlers[sess.Command]
+	if !ok {
+		for _, p := range s.patterns {
+			if p.re.MatchString(sess.Command) {
+				handler, ok = p.handler, true
+				break
+			}
+		}
+	}
+	if !ok {
+		handler = s.fallback
+	}
+	s.mu.Unlock()
+	if handler == nil {
+		handler = notFound
+	}
+
+	sess.Stdin, sess.Stdout, sess.Stderr = ch, ch, ch.Stderr()
+	if sess.PTY != nil {
+		sess.Stderr = ch
+	}
+	code := handler(sess)
+	if sess.ctx.Err() != nil {
+		ch.SendRequest("exit-signal", false, ssh.Marshal(struct {
+			Signal     string
+			CoreDumped bool
+			Error      string
+			Lang       string
+		}{Signal: "KILL"}))
+	} else {
+		ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(code)}))
+	}
+	ch.Close()
+}
+
+func notFound(s *Session) int {
+	fmt.Fprintf(s.Stderr, "sh: %s: command not found\n", s.Command)
+	return 127
+}
+
+// directTCPIP serves a local port forwarding channel.
+func directTCPIP(newChan ssh.NewChannel) {
+	var req struct {
+		DestAddr string
+		DestPort uint32
+		OrigAddr string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChan.ExtraData(), &req); err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(req.DestAddr, strconv.Itoa(int(req.DestPort))))
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	pipe(ch, target)
+}
+
+// forwards serves the remote port forwardings of a connection.
+type forwards struct {
+	conn *ssh.ServerConn
+
+	mu        sync.Mutex
+	listeners map[string]net.Listener
+}
+
+type forwardRequest struct {
+	Addr string
+	Port uint32
+}
+
+func (f *forwards) handle(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		switch req.Type {
+		case "tcpip-forward":
+			var fwd forwardRequest
+			if err := ssh.Unmarshal(req.Payload, &fwd); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			listener, err := net.Listen("tcp", net.JoinHostPort(fwd.Addr, strconv.Itoa(int(fwd.Port))))
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			port := uint32(listener.Addr().(*net.TCPAddr).Port)
+			f.mu.Lock()
+			f.listeners[net.JoinHostPort(fwd.Addr, strconv.Itoa(int(port)))] = listener
+			f.mu.Unlock()
+			req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+			go f.serve(listener, fwd.Addr, port)
+		case "cancel-tcpip-forward":
+			var fwd forwardRequest
+			if err := ssh.Unmarshal(req.Payload, &fwd); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			key := net.JoinHostPort(fwd.Addr, strconv.Itoa(int(fwd.Port)))
+			f.mu.Lock()
+			listener, ok := f.listeners[key]
+			delete(f.listeners, key)
+			f.mu.Unlock()
+			if ok {
+				listener.Close()
+			}
+			req.Reply(ok, nil)
+		case "keepalive@openssh.com":
+			req.Reply(true, nil)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+func (f *forwards) serve(listener net.Listener, addr string, port uint32) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			orig := conn.RemoteAddr().(*net.TCPAddr)
+			ch, reqs, err := f.conn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+				Addr     string
+				Port     uint32
+				OrigAddr string
+				OrigPort uint32
+			}{addr, port, orig.IP.String(), uint32(orig.Port)}))
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			pipe(ch, conn)
+		}()
+	}
+}
+
+func (f *forwards) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, listener := range f.listeners {
+		listener.Close()
+	}
+}
+
+// pipe copies between a and b until either side is done, then closes both.
+func pipe(a, b io.ReadWriteCloser) {
+	defer a.Close()
+	defer b.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,178 @@
+package sshtest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func dial(t *testing.T, s *Server, auth ...ssh.AuthMethod) *ssh.Client {
+	t.Helper()
+	client, err := ssh.Dial("tcp", s.Addr, &ssh.ClientConfig{
+		User:            "ops",
+		Auth:            auth,
+		HostKeyCallback: s.HostKeyCallback(),
+	})
+	if err != nil {
+		t.Fatalf("ssh.Dial() error = %v", err)
+	}
+	return client
+}
+
+func TestServer_auth(t *testing.T) {
+	signer, _, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(Config{
+		Passwords:      map[string]string{"ops": "secret"},
+		AuthorizedKeys: map[string][]ssh.PublicKey{"ops": {signer.PublicKey()}},
+	})
+	defer s.Close()
+
+	tests := []struct {
+		name    string
+		auth    ssh.AuthMethod
+		wantErr bool
+	}{
+		{name: "password", auth: ssh.Password("secret")},
+		{name: "wrong password", auth: ssh.Password("guess"), wantErr: true},
+		{name: "key", auth: ssh.PublicKeys(signer)},
+		{name: "unknown key", auth: ssh.PublicKeys(other), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := ssh.Dial("tcp", s.Addr, &ssh.ClientConfig{
+				User:            "ops",
+				Auth:            []ssh.AuthMethod{tt.auth},
+				HostKeyCallback: s.HostKeyCallback(),
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ssh.Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if client != nil {
+				client.Close()
+			}
+		})
+	}
+}
+
+func TestServer_exec(t *testing.T) {
+	s := NewServer(Config{})
+	defer s.Close()
+	s.Handle("whoami", Reply("ops\n", 0))
+	s.HandlePattern(`^echo `, func(sess *Session) int {
+		pty := "none"
+		if sess.PTY != nil {
+			pty = fmt.Sprintf("%s %dx%d", sess.PTY.Term, sess.PTY.Width, sess.PTY.Height)
+		}
+		fmt.Fprintf(sess.Stdout, "%s %s %s\n", sess.Command[5:], sess.Env["LANG"], pty)
+		fmt.Fprintln(sess.Stderr, "warning")
+		return 3
+	})
+	client := dial(t, s)
+	defer client.Close()
+
+	tests := []struct {
+		name       string
+		cmd        string
+		env        map[string]string
+		pty        bool
+		wantStdout string
+		wantStderr string
+		wantStatus int
+	}{
+		{name: "exact", cmd: "whoami", wantStdout: "ops\n"},
+		{name: "pattern", cmd: "echo hi", env: map[string]string{"LANG": "C"}, wantStdout: "hi C none\n", wantStderr: "warning\n", wantStatus: 3},
+		{name: "pty", cmd: "echo tty", pty: true, wantStdout: "tty  vt100 120x40\nwarning\n", wantStatus: 3},
+		{name: "unknown", cmd: "reboot", wantStderr: "sh: reboot: command not found\n", wantStatus: 127},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := client.NewSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+			for k, v := range tt.env {
+				session.Setenv(k, v)
+			}
+			if tt.pty {
+				session.RequestPty("vt100", 40, 120, ssh.TerminalModes{})
+			}
+			var stdout, stderr bytes.Buffer
+			session.Stdout, session.Stderr = &stdout, &stderr
+			err = session.Run(tt.cmd)
+			status := 0
+			if exitErr, ok := err.(*ssh.ExitError); ok {
+				status = exitErr.ExitStatus()
+			} else if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if stderr.String() != tt.wantStderr {
+				t.Errorf("Run() stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Run() status = %v, want %v", status, tt.wantStatus)
+			}
+		})
+	}
+	if got, want := s.Commands(), []string{"whoami", "echo hi", "echo tty", "reboot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands() = %v, want %v", got, want)
+	}
+}
+
+func TestServer_sftp(t *testing.T) {
+	s := NewServer(Config{})
+	defer s.Close()
+	client := dial(t, s)
+	defer client.Close()
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		t.Fatalf("sftp.NewClient() error = %v", err)
+	}
+	defer sc.Close()
+
+	f, err := sc.Create("/tmp/upload.sh")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	io.WriteString(f, "#!/bin/sh\necho hi\n")
+	f.Close()
+	if err := sc.Chmod("/tmp/upload.sh", 0700); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	if err := sc.Rename("/tmp/upload.sh", "/tmp/run.sh"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+
+	data, err := s.FS.ReadFile("/tmp/run.sh")
+	if err != nil || string(data) != "#!/bin/sh\necho hi\n" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+	info, err := sc.Stat("/tmp/run.sh")
+	if err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("Stat() = %v, %v, want mode 0700", info, err)
+	}
+	if err := sc.Remove("/tmp/run.sh"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := s.FS.Stat("/tmp/run.sh"); !os.IsNotExist(err) {
+		t.Errorf("Stat() error = %v, want not exist", err)
+	}
+	if _, err := sc.Create("/missing/file"); err == nil {
+		t.Errorf("Create() in a missing directory succeeded")
+	}
+}
@@ -0,0 +1,427 @@
+package common
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TunnelType is the kind of port forwarding performed by a Tunnel.
+type TunnelType int
+
+const (
+	// LocalForward accepts connections on a local address and forwards
+	// them to an address dialled from the remote host, like ssh -L.
+	LocalForward TunnelType = iota
+	// RemoteForward accepts connections on a remote address and forwards
+	// them to an address dialled from the local host, like ssh -R.
+	RemoteForward
+	// DynamicForward runs a local SOCKS5 proxy whose connections are
+	// dialled from the remote host, like ssh -D.
+	DynamicForward
+)
+
+func (t TunnelType) String() string {
+	switch t {
+	case LocalForward:
+		return "local"
+	case RemoteForward:
+		return "remote"
+	case DynamicForward:
+		return "dynamic"
+	}
+	return "TunnelType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ErrTunnelClosed is returned by Tunnel.Err after the tunnel is closed.
+var ErrTunnelClosed = errors.New("ssh tunnel closed")
+
+// TunnelOptions configures a Tunnel.
+type TunnelOptions struct {
+	Type TunnelType
+	// ListenAddr is the address the tunnel accepts connections on.
+	// It is a remote address for RemoteForward and a local one otherwise.
+	ListenAddr string
+	// TargetAddr is the address accepted connections are forwarded to.
+	// It is ignored by DynamicForward.
+	TargetAddr string
+	// KeepAlive is the interval between health checks of the
+	// SSH connection, defaults to 30 seconds.
+	KeepAlive time.Duration
+	// MaxBackoff caps the delay between reconnection attempts,
+	// defaults to 30 seconds.
+	MaxBackoff time.Duration
+}
+
+// Tunnel is a managed SSH port forwarding. It health checks the
+// underlying SSH connection and re-establishes it when it drops.
+type Tunnel struct {
+	ssh  *SSH
+	opts TunnelOptions
+
+	mu       sync.RWMutex
+	client   *ssh.Client
+	listener net.Listener
+	err      error
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewTunnel returns a Tunnel forwarding over connections dialled with s.
+// The tunnel does nothing until Start is called.
+func (s *SSH) NewTunnel(opts TunnelOptions) *Tunnel {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 30 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	return &Tunnel{ssh: s, opts: opts}
+}
+
+// Start connects to the remote host, opens the listener and serves
+// connections in the background until ctx is done or Close is called.
+func (t *Tunnel) Start(ctx context.Context) error {
+	if t.opts.Type != DynamicForward && t.opts.TargetAddr == "" {
+		return fmt.Errorf("%s tunnel requires a target address", t.opts.Type)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done != nil {
+		return errors.New("ssh tunnel already started")
+	}
+
+	client, err := t.ssh.Dial()
+	if err != nil {
+		return err
+	}
+	var listener net.Listener
+	if t.opts.Type == RemoteForward {
+		listener, err = client.Listen("tcp", t.opts.ListenAddr)
+	} else {
+		listener, err = net.Listen("tcp", t.opts.ListenAddr)
+	}
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	ctx, t.cancel = context.WithCancel(ctx)
+	t.client, t.listener, t.done = client, listener, make(chan struct{})
+	go t.serve(listener)
+	go t.supervise(ctx, client)
+	return nil
+}
+
+// Addr returns the address the tunnel listens on, or nil before Start.
+func (t *Tunnel) Addr() net.Addr {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.listener == nil {
+		return nil
+	}
+	return t.listener.Addr()
+}
+
+// Healthy reports whether the SSH connection passed its last health check.
+func (t *Tunnel) Healthy() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.client != nil && t.err == nil
+}
+
+// Err returns the error that broke the SSH connection, nil while healthy.
+func (t *Tunnel) Err() error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.err
+}
+
+// Close stops the tunnel and closes its listener and SSH connection.
+func (t *Tunnel) Close() error {
+	t.mu.RLock()
+	cancel, done := t.cancel, t.done
+	t.mu.RUnlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	return nil
+}
+
+// supervise health checks client and replaces it when it fails.
+func (t *Tunnel) supervise(ctx context.Context, client *ssh.Client) {
+	defer close(t.done)
+	defer t.shutdown()
+
+	ticker := time.NewTicker(t.opts.KeepAlive)
+	defer ticker.Stop()
+	lost := waitClient(client)
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case err = <-lost:
+			if err == nil {
+				err = io.EOF
+			}
+		case <-ticker.C:
+			if err = keepAlive(client, t.opts.KeepAlive); err == nil {
+				continue
+			}
+		}
+
+		t.broken(client, err)
+		if client = t.reconnect(ctx); client == nil {
+			return
+		}
+		lost = waitClient(client)
+	}
+}
+
+// broken records err and releases the resources bound to client.
+func (t *Tunnel) broken(client *ssh.Client, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = fmt.Errorf("ssh connection to %s lost: %w", t.ssh.Addr, err)
+	t.client = nil
+	client.Close()
+	if t.opts.Type == RemoteForward {
+		t.listener.Close()
+	}
+}
+
+// reconnect dials until it succeeds, backing off exponentially.
+// It returns nil if ctx is done first.
+func (t *Tunnel) reconnect(ctx context.Context) *ssh.Client {
+	backoff := time.Second
+	for {
+		client, err := t.ssh.Dial()
+		if err == nil && t.opts.Type == RemoteForward {
+			var listener net.Listener
+			if listener, err = client.Listen("tcp", t.opts.ListenAddr); err == nil {
+				t.mu.Lock()
+				t.listener = listener
+				t.mu.Unlock()
+				go t.serve(listener)
+			} else {
+				client.Close()
+			}
+		}
+		if err == nil {
+			t.mu.Lock()
+			t.client, t.err = client, nil
+			t.mu.Unlock()
+			return client
+		}
+
+		t.mu.Lock()
+		t.err = fmt.Errorf("reconnect to %s: %w", t.ssh.Addr, err)
+		t.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > t.opts.MaxBackoff {
+			backoff = t.opts.MaxBackoff
+		}
+	}
+}
+
+func (t *Tunnel) shutdown() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listener.Close()
+	if t.client != nil {
+		t.client.Close()
+		t.client = nil
+	}
+	t.err = ErrTunnelClosed
+}
+
+func (t *Tunnel) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.handle(conn)
+	}
+}
+
+func (t *Tunnel) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var target net.Conn
+	var err error
+	switch t.opts.Type {
+	case LocalForward:
+		target, err = t.dialRemote(t.opts.TargetAddr)
+	case RemoteForward:
+		target, err = net.Dial("tcp", t.opts.TargetAddr)
+	case DynamicForward:
+		var addr string
+		if addr, err = socksHandshake(conn); err != nil {
+			return
+		}
+		if target, err = t.dialRemote(addr); err != nil {
+			socksReply(conn, socksGeneralFailure)
+			return
+		}
+		err = socksReply(conn, socksSucceeded)
+	}
+	if err != nil {
+		return
+	}
+	defer target.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(target, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, target)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func (t *Tunnel) dialRemote(addr string) (net.Conn, error) {
+	t.mu.RLock()
+	client := t.client
+	t.mu.RUnlock()
+	if client == nil {
+		return nil, fmt.Errorf("ssh connection to %s is down", t.ssh.Addr)
+	}
+	return client.Dial("tcp", addr)
+}
+
+// waitClient returns a channel receiving the result of client.Wait.
+func waitClient(client *ssh.Client) <-chan error {
+	lost := make(chan error, 1)
+	go func() {
+		lost <- client.Wait()
+	}()
+	return lost
+}
+
+// keepAlive sends an OpenSSH keepalive request, failing
+// if the server does not answer within timeout.
+func keepAlive(client *ssh.Client, timeout time.Duration) error {
+	res := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		res <- err
+	}()
+	select {
+	case err := <-res:
+		return err
+	case <-time.After(timeout):
+		return errors.New("keepalive timed out")
+	}
+}
+
+// SOCKS5 constants, see RFC 1928.
+const (
+	socksVersion             = 5
+	socksNoAuth              = 0
+	socksNoAcceptableMethods = 0xff
+	socksConnect             = 1
+	socksAddrIPv4            = 1
+	socksAddrDomain          = 3
+	socksAddrIPv6            = 4
+
+	socksSucceeded            = 0
+	socksGeneralFailure       = 1
+	socksCommandNotSupported  = 7
+	socksAddrTypeNotSupported = 8
+)
+
+// socksHandshake performs the server side of an unauthenticated SOCKS5
+// handshake and returns the address the client asks to CONNECT to.
+func socksHandshake(rw io.ReadWriter) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(rw, header); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("socks: unsupported version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(rw, methods); err != nil {
+		return "", err
+	}
+	method := byte(socksNoAcceptableMethods)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := rw.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method != socksNoAuth {
+		return "", errors.New("socks: no acceptable authentication method")
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(rw, request); err != nil {
+		return "", err
+	}
+	if request[0] != socksVersion {
+		return "", fmt.Errorf("socks: unsupported version %d", request[0])
+	}
+	if request[1] != socksConnect {
+		socksReply(rw, socksCommandNotSupported)
+		return "", fmt.Errorf("socks: unsupported command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == socksAddrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(rw, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksAddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(rw, length); err != nil {
+			return "", err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(rw, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		socksReply(rw, socksAddrTypeNotSupported)
+		return "", fmt.Errorf("socks: unsupported address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(rw, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply writes a SOCKS5 reply with an empty bound address.
+func socksReply(w io.Writer, code byte) error {
+	_, err := w.Write([]byte{socksVersion, code, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
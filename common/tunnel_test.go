@@ -0,0 +1,206 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mo-silent/go-devops/common/sshtest"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSocksHandshake(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   []byte
+		want      string
+		wantReply []byte
+		wantErr   bool
+	}{
+		{
+			name:      "ipv4",
+			request:   []byte{5, 1, 0, 5, 1, 0, 1, 127, 0, 0, 1, 0x23, 0x82},
+			want:      "127.0.0.1:9090",
+			wantReply: []byte{5, 0},
+		},
+		{
+			name:      "domain",
+			request:   append(append([]byte{5, 2, 2, 0, 5, 1, 0, 3, 9}, "localhost"...), 0x0c, 0xea),
+			want:      "localhost:3306",
+			wantReply: []byte{5, 0},
+		},
+		{
+			name:      "ipv6",
+			request:   []byte{5, 1, 0, 5, 1, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 22},
+			want:      "[::1]:22",
+			wantReply: []byte{5, 0},
+		},
+		{
+			name:      "password auth only",
+			request:   []byte{5, 1, 2},
+			wantReply: []byte{5, 0xff},
+			wantErr:   true,
+		},
+		{
+			name:      "bind command",
+			request:   []byte{5, 1, 0, 5, 2, 0, 1, 127, 0, 0, 1, 0, 80},
+			wantReply: []byte{5, 0, 5, 7, 0, 1, 0, 0, 0, 0, 0, 0},
+			wantErr:   true,
+		},
+		{
+			name:    "socks4",
+			request: []byte{4, 1, 0, 80, 127, 0, 0, 1, 0},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reply bytes.Buffer
+			got, err := socksHandshake(readWriter{bytes.NewReader(tt.request), &reply})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("socksHandshake() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("socksHandshake() got = %v, want %v", got, tt.want)
+			}
+			if !bytes.Equal(reply.Bytes(), tt.wantReply) {
+				t.Errorf("socksHandshake() reply = %v, want %v", reply.Bytes(), tt.wantReply)
+			}
+		})
+	}
+}
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+// echoServer starts a TCP server echoing back what it reads.
+func echoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// ping checks that conn echoes back what is written to it.
+func ping(conn net.Conn) error {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		return err
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if string(buf) != "ping" {
+		return fmt.Errorf("read %q, want ping", buf)
+	}
+	return nil
+}
+
+func TestTunnel(t *testing.T) {
+	server := sshtest.NewServer(sshtest.Config{})
+	defer server.Close()
+	target := echoServer(t)
+	host, port, _ := net.SplitHostPort(target)
+	portNum, _ := strconv.Atoi(port)
+
+	tests := []struct {
+		name string
+		opts TunnelOptions
+		dial func(addr string) (net.Conn, error)
+	}{
+		{
+			name: "local",
+			opts: TunnelOptions{Type: LocalForward, ListenAddr: "127.0.0.1:0", TargetAddr: target},
+		},
+		{
+			name: "remote",
+			opts: TunnelOptions{Type: RemoteForward, ListenAddr: "127.0.0.1:0", TargetAddr: target},
+		},
+		{
+			name: "dynamic",
+			opts: TunnelOptions{Type: DynamicForward, ListenAddr: "127.0.0.1:0"},
+			dial: func(addr string) (net.Conn, error) {
+				conn, err := net.Dial("tcp", addr)
+				if err != nil {
+					return nil, err
+				}
+				request := append([]byte{5, 1, 0, 5, 1, 0, 1}, net.ParseIP(host).To4()...)
+				request = append(request, byte(portNum>>8), byte(portNum))
+				if _, err := conn.Write(request); err != nil {
+					return nil, err
+				}
+				reply := make([]byte, 12)
+				if _, err := io.ReadFull(conn, reply); err != nil {
+					return nil, err
+				}
+				if reply[3] != socksSucceeded {
+					return nil, fmt.Errorf("socks reply %d", reply[3])
+				}
+				return conn, nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SSH{Addr: server.Addr, User: "root", HostKeyCallback: server.HostKeyCallback()}
+			tt.opts.KeepAlive = 50 * time.Millisecond
+			tunnel := s.NewTunnel(tt.opts)
+			if err := tunnel.Start(context.Background()); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			defer tunnel.Close()
+			dial := tt.dial
+			if dial == nil {
+				dial = func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) }
+			}
+
+			for round := 0; round < 2; round++ {
+				// the tunnel must come back after the connection drops
+				deadline := time.Now().Add(5 * time.Second)
+				for {
+					conn, err := dial(tunnel.Addr().String())
+					if err == nil {
+						err = ping(conn)
+					}
+					if err == nil {
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatalf("round %d: %v, tunnel err = %v", round, err, tunnel.Err())
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				server.CloseConnections()
+			}
+
+			tunnel.Close()
+			if tunnel.Healthy() || !errors.Is(tunnel.Err(), ErrTunnelClosed) {
+				t.Errorf("Err() = %v after Close, want %v", tunnel.Err(), ErrTunnelClosed)
+			}
+		})
+	}
+}
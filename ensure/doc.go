@@ -0,0 +1,26 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package ensure implements idempotent state management over SSH
+
+Steps bring a file, a line in a file, a systemd unit or a package to a
+desired state through a common.Executor. Each step reads the current
+state first and only changes what differs, reporting whether it did, and
+in dry-run mode describes the changes as diffs without applying them.
+
+References:
+
+	[Ansible builtin modules]: https://docs.ansible.com/ansible/latest/collections/ansible/builtin/index.html
+*/
+package ensure // import "github.com/mo-silent/go-devops/ensure"
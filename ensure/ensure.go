@@ -0,0 +1,115 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ensure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// Step brings one aspect of a host to a desired state.
+type Step interface {
+	// String describes the step, as in "file /etc/motd".
+	String() string
+	// Ensure checks the current state through e and changes it when it
+	// differs, unless dryRun is set. The Result tells what differed.
+	Ensure(ctx context.Context, e common.Executor, dryRun bool) (*Result, error)
+}
+
+// Result is the outcome of a Step.
+type Result struct {
+	Step string
+	// Changed reports whether the state differed, and was changed
+	// unless the step ran in dry-run mode.
+	Changed bool
+	DryRun  bool
+	// Diff describes the changes, as a unified diff for file content.
+	Diff string
+}
+
+func (r *Result) String() string {
+	state := "unchanged"
+	switch {
+	case r.Changed && r.DryRun:
+		state = "would change"
+	case r.Changed:
+		state = "changed"
+	}
+	return r.Step + ": " + state
+}
+
+// Switch is a state a Step can turn on or off, or leave alone.
+type Switch int
+
+const (
+	// Ignore leaves the state as it is.
+	Ignore Switch = iota
+	On
+	Off
+)
+
+// Run ensures each step in order through e and returns their results,
+// stopping at the first step failing.
+func Run(ctx context.Context, e common.Executor, dryRun bool, steps ...Step) ([]*Result, error) {
+	results := make([]*Result, 0, len(steps))
+	for _, step := range steps {
+		res, err := step.Ensure(ctx, e, dryRun)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", step, err)
+		}
+		log.Debugf("ensure %s", res)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// Changed reports whether any of results changed.
+func Changed(results []*Result) bool {
+	for _, r := range results {
+		if r.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs cmd with stdin and returns its output, failing
+// unless it exits with one of the accepted statuses or zero.
+func run(ctx context.Context, e common.Executor, cmd string, stdin []byte, accept ...int) (*common.ExecResult, error) {
+	opts := common.ExecOptions{}
+	if stdin != nil {
+		opts.Stdin = bytes.NewReader(stdin)
+	}
+	res, err := e.Run(ctx, cmd, opts)
+	if err != nil {
+		return nil, err
+	}
+	if res.ExitCode == 0 {
+		return res, nil
+	}
+	for _, code := range accept {
+		if res.ExitCode == code {
+			return res, nil
+		}
+	}
+	msg := strings.TrimSpace(string(res.Stderr))
+	if msg == "" {
+		msg = strings.TrimSpace(string(res.Stdout))
+	}
+	return nil, fmt.Errorf("%s: exit status %d: %s", strings.Fields(cmd)[0], res.ExitCode, msg)
+}
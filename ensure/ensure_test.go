@@ -0,0 +1,220 @@
+package ensure
+
+import (
+	"context"
+	"errors"
+	"github.com/mo-silent/go-devops/common"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// fakeExecutor replies to the commands it knows and records
+// every command it runs with its input.
+type fakeExecutor struct {
+	replies map[string]*common.ExecResult
+	cmds    []string
+	stdin   []string
+}
+
+func (f *fakeExecutor) Run(_ context.Context, cmd string, opts common.ExecOptions) (*common.ExecResult, error) {
+	f.cmds = append(f.cmds, cmd)
+	if opts.Stdin != nil {
+		b, _ := io.ReadAll(opts.Stdin)
+		f.stdin = append(f.stdin, string(b))
+	}
+	if res, ok := f.replies[cmd]; ok {
+		return res, nil
+	}
+	return &common.ExecResult{ExitCode: 127, Stderr: []byte("sh: command not found")}, nil
+}
+
+func (f *fakeExecutor) Close() error {
+	return nil
+}
+
+func reply(stdout string, code int) *common.ExecResult {
+	return &common.ExecResult{Stdout: []byte(stdout), ExitCode: code}
+}
+
+func TestService_Ensure(t *testing.T) {
+	const check = "systemctl is-active -- nginx; systemctl is-enabled -- nginx"
+	tests := []struct {
+		name     string
+		service  Service
+		state    *common.ExecResult
+		dryRun   bool
+		want     *Result
+		wantCmds []string
+		wantErr  bool
+	}{
+		{
+			name:     "unchanged",
+			service:  Service{Unit: "nginx", Running: On, Enabled: On},
+			state:    reply("active\nenabled\n", 0),
+			want:     &Result{Step: "service nginx"},
+			wantCmds: []string{check},
+		},
+		{
+			name:     "start and enable",
+			service:  Service{Unit: "nginx", Running: On, Enabled: On},
+			state:    reply("inactive\ndisabled\n", 1),
+			want:     &Result{Step: "service nginx", Changed: true, Diff: "running: inactive -> active\nenabled: disabled -> enabled\n"},
+			wantCmds: []string{check, "systemctl start -- nginx && systemctl enable -- nginx"},
+		},
+		{
+			name:     "stop dry run",
+			service:  Service{Unit: "nginx", Running: Off},
+			state:    reply("active\nenabled\n", 0),
+			dryRun:   true,
+			want:     &Result{Step: "service nginx", Changed: true, DryRun: true, Diff: "running: active -> inactive\n"},
+			wantCmds: []string{check},
+		},
+		{
+			name:     "failed is stopped",
+			service:  Service{Unit: "nginx", Running: Off, Enabled: Off},
+			state:    reply("failed\ndisabled\n", 3),
+			want:     &Result{Step: "service nginx"},
+			wantCmds: []string{check},
+		},
+		{
+			name:     "static",
+			service:  Service{Unit: "nginx", Enabled: On},
+			state:    reply("active\nstatic\n", 0),
+			want:     &Result{Step: "service nginx"},
+			wantCmds: []string{check},
+		},
+		{
+			name:    "not found",
+			service: Service{Unit: "nginx", Running: On},
+			state:   reply("inactive\n", 4),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &fakeExecutor{replies: map[string]*common.ExecResult{
+				check: tt.state,
+				"systemctl start -- nginx && systemctl enable -- nginx": reply("", 0),
+			}}
+			got, err := tt.service.Ensure(context.Background(), e, tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ensure() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(e.cmds, tt.wantCmds) {
+				t.Errorf("Ensure() ran %q, want %q", e.cmds, tt.wantCmds)
+			}
+		})
+	}
+}
+
+func TestPackage_Ensure(t *testing.T) {
+	const detect = "command -v apt-get dnf yum zypper apk"
+	tests := []struct {
+		name     string
+		pkg      Package
+		replies  map[string]*common.ExecResult
+		want     *Result
+		wantCmds []string
+		wantErr  bool
+	}{
+		{
+			name: "installed",
+			pkg:  Package{Name: "nginx"},
+			replies: map[string]*common.ExecResult{
+				detect:         reply("/usr/bin/dnf\n/usr/bin/yum\n", 1),
+				"rpm -q nginx": reply("nginx-1.20.1-1.el9.x86_64\n", 0),
+			},
+			want:     &Result{Step: "package nginx"},
+			wantCmds: []string{detect, "rpm -q nginx"},
+		},
+		{
+			name: "install",
+			pkg:  Package{Name: "nginx", Manager: "apt-get"},
+			replies: map[string]*common.ExecResult{
+				`dpkg-query -W -f='${Status}' nginx 2>/dev/null | grep -q ' installed$'`: reply("", 1),
+				"DEBIAN_FRONTEND=noninteractive apt-get install -y -q nginx":             reply("", 0),
+			},
+			want: &Result{Step: "package nginx", Changed: true, Diff: "install nginx with apt-get\n"},
+			wantCmds: []string{
+				`dpkg-query -W -f='${Status}' nginx 2>/dev/null | grep -q ' installed$'`,
+				"DEBIAN_FRONTEND=noninteractive apt-get install -y -q nginx",
+			},
+		},
+		{
+			name: "install failure",
+			pkg:  Package{Name: "nginx", Manager: "apk"},
+			replies: map[string]*common.ExecResult{
+				"apk info -e nginx": reply("", 1),
+				"apk add -q nginx":  {ExitCode: 1, Stderr: []byte("ERROR: unable to select packages")},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no manager",
+			pkg:     Package{Name: "nginx"},
+			replies: map[string]*common.ExecResult{detect: reply("", 127)},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported manager",
+			pkg:     Package{Name: "nginx", Manager: "pacman"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &fakeExecutor{replies: tt.replies}
+			got, err := tt.pkg.Ensure(context.Background(), e, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ensure() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(e.cmds, tt.wantCmds) {
+				t.Errorf("Ensure() ran %q, want %q", e.cmds, tt.wantCmds)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	e := &fakeExecutor{replies: map[string]*common.ExecResult{
+		"systemctl is-active -- nginx; systemctl is-enabled -- nginx": reply("active\nenabled\n", 0),
+		"command -v apt-get dnf yum zypper apk":                       reply("/usr/bin/apk\n", 1),
+		"apk info -e nginx":                                           reply("", 1),
+	}}
+	steps := []Step{
+		&Service{Unit: "nginx", Running: On},
+		&Package{Name: "nginx"},
+		&Service{Unit: "nginx", Running: Off},
+		&Package{Name: "missing", Manager: "apk"},
+	}
+	results, err := Run(context.Background(), e, true, steps...)
+	if err == nil || len(results) != 3 {
+		t.Fatalf("Run() = %v, %v, want 3 results and an error", results, err)
+	}
+	var want []string
+	for _, r := range results {
+		want = append(want, r.String())
+	}
+	if !reflect.DeepEqual(want, []string{"service nginx: unchanged", "package nginx: would change", "service nginx: would change"}) {
+		t.Errorf("Run() = %v", want)
+	}
+	if !Changed(results) || Changed(results[:1]) {
+		t.Errorf("Changed() is wrong for %v", want)
+	}
+	if len(e.stdin) != 0 || errors.Unwrap(err) == nil {
+		t.Errorf("Run() error = %v, stdin = %v", err, e.stdin)
+	}
+}
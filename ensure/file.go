@@ -0,0 +1,269 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ensure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// File ensures the content and attributes of a file. Fields left
+// empty are not managed.
+type File struct {
+	Path string
+	// Content is the content of the file, a nil Content leaves
+	// it alone while an empty one truncates the file.
+	Content []byte
+	// Mode holds the permission bits of the file and its setuid, setgid
+	// and sticky bits, set with os.ModeSetuid, os.ModeSetgid and
+	// os.ModeSticky or as for chmod, such as 04755.
+	Mode  os.FileMode
+	Owner string
+	Group string
+}
+
+func (f *File) String() string {
+	return "file " + f.Path
+}
+
+// Ensure creates or rewrites the file when its content differs and sets
+// its attributes. The file is rewritten in place, keeping the attributes
+// that are not managed; a missing file is created with the umask of the
+// remote user. Managing only the attributes of a missing file fails.
+func (f *File) Ensure(ctx context.Context, e common.Executor, dryRun bool) (*Result, error) {
+	res := &Result{Step: f.String(), DryRun: dryRun}
+	st, err := readFile(ctx, e, f.Path, f.Content != nil)
+	if err != nil {
+		return nil, err
+	}
+	if !st.exists && f.Content == nil {
+		return nil, fmt.Errorf("%s does not exist", f.Path)
+	}
+
+	var diff strings.Builder
+	var cmds []string
+	var stdin []byte
+	path := common.ShellQuote(f.Path)
+	if mode := unixMode(f.Mode); f.Mode != 0 && (!st.exists || st.mode != mode) {
+		fmt.Fprintf(&diff, "mode: %s -> %04o\n", st.attr(fmt.Sprintf("%04o", st.mode)), mode)
+		cmds = append(cmds, fmt.Sprintf("chmod %04o %s", mode, path))
+	}
+	if f.Owner != "" && (!st.exists || st.owner != f.Owner) {
+		fmt.Fprintf(&diff, "owner: %s -> %s\n", st.attr(st.owner), f.Owner)
+		cmds = append(cmds, "chown "+common.ShellQuote(f.Owner)+" "+path)
+	}
+	if f.Group != "" && (!st.exists || st.group != f.Group) {
+		fmt.Fprintf(&diff, "group: %s -> %s\n", st.attr(st.group), f.Group)
+		cmds = append(cmds, "chgrp "+common.ShellQuote(f.Group)+" "+path)
+	}
+	if f.Content != nil && (!st.exists || !bytes.Equal(st.content, f.Content)) {
+		diff.WriteString(st.diff(f.Path, f.Content))
+		cmds = append([]string{"cat > " + path}, cmds...)
+		stdin = f.Content
+	}
+
+	res.Changed, res.Diff = len(cmds) > 0, diff.String()
+	if res.Changed && !dryRun {
+		if _, err := run(ctx, e, strings.Join(cmds, " && "), stdin); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// unixMode returns the permission, setuid, setgid and sticky bits of m
+// as chmod takes them.
+func unixMode(m os.FileMode) uint32 {
+	mode := uint32(m & 07777)
+	if m&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	return mode
+}
+
+// Line ensures a line is present in, or absent from, a text file.
+type Line struct {
+	Path string
+	Line string
+	// Regexp selects the line to replace with Line, the last matching
+	// line is replaced and Line is appended when none matches. With
+	// Absent set every matching line is removed.
+	Regexp string
+	// Absent removes the line, or the lines matching Regexp, instead.
+	Absent bool
+	// Create creates a missing file instead of failing.
+	Create bool
+}
+
+func (l *Line) String() string {
+	return fmt.Sprintf("line %q in %s", l.Line, l.Path)
+}
+
+// Ensure rewrites the file in place when the line has to be added,
+// replaced or removed.
+func (l *Line) Ensure(ctx context.Context, e common.Executor, dryRun bool) (*Result, error) {
+	var re *regexp.Regexp
+	if l.Regexp != "" {
+		var err error
+		if re, err = regexp.Compile(l.Regexp); err != nil {
+			return nil, err
+		}
+	}
+	res := &Result{Step: l.String(), DryRun: dryRun}
+	st, err := readFile(ctx, e, l.Path, true)
+	if err != nil {
+		return nil, err
+	}
+	if !st.exists && l.Absent {
+		return res, nil
+	}
+	if !st.exists && !l.Create {
+		return nil, fmt.Errorf("%s does not exist", l.Path)
+	}
+
+	lines := strings.SplitAfter(string(st.content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	matches := func(line string) bool {
+		line = strings.TrimSuffix(line, "\n")
+		if re != nil {
+			return re.MatchString(line)
+		}
+		return line == l.Line
+	}
+	var out []string
+	if l.Absent {
+		for _, line := range lines {
+			if !matches(line) {
+				out = append(out, line)
+			}
+		}
+	} else {
+		last := -1
+		for i, line := range lines {
+			if matches(line) {
+				last = i
+			}
+		}
+		out = append(out, lines...)
+		switch {
+		case last >= 0:
+			out[last] = l.Line + "\n"
+		case re != nil && contains(lines, l.Line):
+		default:
+			if n := len(out); n > 0 && !strings.HasSuffix(out[n-1], "\n") {
+				out[n-1] += "\n"
+			}
+			out = append(out, l.Line+"\n")
+		}
+	}
+
+	content := []byte(strings.Join(out, ""))
+	if st.exists && bytes.Equal(content, st.content) {
+		return res, nil
+	}
+	res.Changed, res.Diff = true, st.diff(l.Path, content)
+	if !dryRun {
+		if _, err := run(ctx, e, "cat > "+common.ShellQuote(l.Path), content); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func contains(lines []string, s string) bool {
+	for _, line := range lines {
+		if strings.TrimSuffix(line, "\n") == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fileState is the state of a remote file.
+type fileState struct {
+	exists  bool
+	mode    uint32 // as chmod takes it
+	owner   string
+	group   string
+	content []byte
+}
+
+// attr returns value, or absent for a missing file.
+func (st *fileState) attr(value string) string {
+	if !st.exists {
+		return "absent"
+	}
+	return value
+}
+
+// diff returns the diff from the content of the file to content.
+func (st *fileState) diff(path string, content []byte) string {
+	oldName := path
+	if !st.exists {
+		oldName = "/dev/null"
+	}
+	return Diff(oldName, path, string(st.content), string(content))
+}
+
+// readFile returns the state of the file at path,
+// reading its content when content is set.
+func readFile(ctx context.Context, e common.Executor, path string, content bool) (*fileState, error) {
+	p := common.ShellQuote(path)
+	cmd := "if [ -e " + p + " ]; then stat -c '%a %U %G' -- " + p
+	if content {
+		cmd += " && cat -- " + p
+	}
+	cmd += "; else echo absent; fi"
+	res, err := run(ctx, e, cmd, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := string(res.Stdout)
+	i := strings.IndexByte(out, '\n')
+	if i < 0 {
+		return nil, fmt.Errorf("unexpected stat output %q", out)
+	}
+	if out[:i] == "absent" {
+		return &fileState{}, nil
+	}
+	fields := strings.Fields(out[:i])
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected stat output %q", out[:i])
+	}
+	mode, err := strconv.ParseUint(fields[0], 8, 12)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected stat output %q", out[:i])
+	}
+	return &fileState{
+		exists:  true,
+		mode:    uint32(mode),
+		owner:   fields[1],
+		group:   fields[2],
+		content: []byte(out[i+1:]),
+	}, nil
+}
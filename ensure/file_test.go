@@ -0,0 +1,192 @@
+package ensure
+
+import (
+	"context"
+	"github.com/mo-silent/go-devops/common"
+	"os"
+	"reflect"
+	"testing"
+)
+
+const motdStat = "if [ -e /etc/motd ]; then stat -c '%a %U %G' -- /etc/motd && cat -- /etc/motd; else echo absent; fi"
+
+func TestFile_Ensure(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      File
+		state     string
+		dryRun    bool
+		want      *Result
+		wantCmd   string
+		wantStdin string
+		wantErr   bool
+	}{
+		{
+			name:  "unchanged",
+			file:  File{Path: "/etc/motd", Content: []byte("hello\n"), Mode: 0644, Owner: "root"},
+			state: "644 root root\nhello\n",
+			want:  &Result{Step: "file /etc/motd"},
+		},
+		{
+			name:  "content and mode",
+			file:  File{Path: "/etc/motd", Content: []byte("hello\nworld\n"), Mode: 0600, Group: "adm"},
+			state: "644 root root\nhello\n",
+			want: &Result{Step: "file /etc/motd", Changed: true, Diff: "mode: 0644 -> 0600\ngroup: root -> adm\n" +
+				"--- /etc/motd\n+++ /etc/motd\n@@ -1 +1,2 @@\n hello\n+world\n"},
+			wantCmd:   "cat > /etc/motd && chmod 0600 /etc/motd && chgrp adm /etc/motd",
+			wantStdin: "hello\nworld\n",
+		},
+		{
+			name:   "create dry run",
+			file:   File{Path: "/etc/motd", Content: []byte("hello\n"), Owner: "root"},
+			state:  "absent\n",
+			dryRun: true,
+			want: &Result{Step: "file /etc/motd", Changed: true, DryRun: true, Diff: "owner: absent -> root\n" +
+				"--- /dev/null\n+++ /etc/motd\n@@ -0,0 +1 @@\n+hello\n"},
+		},
+		{
+			name:    "setuid",
+			file:    File{Path: "/etc/motd", Content: []byte("hello\n"), Mode: os.ModeSetuid | 0755},
+			state:   "755 root root\nhello\n",
+			want:    &Result{Step: "file /etc/motd", Changed: true, Diff: "mode: 0755 -> 4755\n"},
+			wantCmd: "chmod 4755 /etc/motd",
+		},
+		{
+			name:  "sticky as for chmod",
+			file:  File{Path: "/etc/motd", Content: []byte("hello\n"), Mode: 01777},
+			state: "1777 root root\nhello\n",
+			want:  &Result{Step: "file /etc/motd"},
+		},
+		{
+			name:    "drop setgid",
+			file:    File{Path: "/etc/motd", Content: []byte("hello\n"), Mode: 0755},
+			state:   "2755 root root\nhello\n",
+			want:    &Result{Step: "file /etc/motd", Changed: true, Diff: "mode: 2755 -> 0755\n"},
+			wantCmd: "chmod 0755 /etc/motd",
+		},
+		{
+			name:    "attributes of a missing file",
+			file:    File{Path: "/etc/motd", Mode: 0644},
+			state:   "absent\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stat := motdStat
+			if tt.file.Content == nil {
+				stat = "if [ -e /etc/motd ]; then stat -c '%a %U %G' -- /etc/motd; else echo absent; fi"
+			}
+			e := &fakeExecutor{replies: map[string]*common.ExecResult{stat: reply(tt.state, 0)}}
+			if tt.wantCmd != "" {
+				e.replies[tt.wantCmd] = reply("", 0)
+			}
+			got, err := tt.file.Ensure(context.Background(), e, tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ensure() = %+v, want %+v", got, tt.want)
+			}
+			wantCmds, wantStdin := []string{stat}, []string(nil)
+			if tt.wantCmd != "" {
+				wantCmds = append(wantCmds, tt.wantCmd)
+			}
+			if tt.wantStdin != "" {
+				wantStdin = []string{tt.wantStdin}
+			}
+			if !reflect.DeepEqual(e.cmds, wantCmds) || !reflect.DeepEqual(e.stdin, wantStdin) {
+				t.Errorf("Ensure() ran %q with %q, want %q with %q", e.cmds, e.stdin, wantCmds, wantStdin)
+			}
+		})
+	}
+}
+
+func TestLine_Ensure(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    Line
+		state   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "append",
+			line:  Line{Path: "/etc/motd", Line: "PermitRootLogin no"},
+			state: "644 root root\nPort 22",
+			want:  "Port 22\nPermitRootLogin no\n",
+		},
+		{
+			name:  "present",
+			line:  Line{Path: "/etc/motd", Line: "Port 22"},
+			state: "644 root root\nPort 22\nPermitRootLogin no\n",
+		},
+		{
+			name:  "replace last match",
+			line:  Line{Path: "/etc/motd", Line: "PermitRootLogin no", Regexp: `^#?PermitRootLogin`},
+			state: "644 root root\n#PermitRootLogin yes\nPort 22\nPermitRootLogin yes\n",
+			want:  "#PermitRootLogin yes\nPort 22\nPermitRootLogin no\n",
+		},
+		{
+			name:  "present without match",
+			line:  Line{Path: "/etc/motd", Line: "PermitRootLogin no", Regexp: `^PermitRootLogin yes`},
+			state: "644 root root\nPermitRootLogin no\n",
+		},
+		{
+			name:  "absent",
+			line:  Line{Path: "/etc/motd", Regexp: `^#`, Absent: true},
+			state: "644 root root\n# comment\nPort 22\n# another\n",
+			want:  "Port 22\n",
+		},
+		{
+			name:  "absent from missing file",
+			line:  Line{Path: "/etc/motd", Line: "Port 22", Absent: true, Create: true},
+			state: "absent\n",
+		},
+		{
+			name:  "create",
+			line:  Line{Path: "/etc/motd", Line: "Port 22", Create: true},
+			state: "absent\n",
+			want:  "Port 22\n",
+		},
+		{
+			name:    "missing file",
+			line:    Line{Path: "/etc/motd", Line: "Port 22"},
+			state:   "absent\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad regexp",
+			line:    Line{Path: "/etc/motd", Line: "Port 22", Regexp: "("},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &fakeExecutor{replies: map[string]*common.ExecResult{
+				motdStat:          reply(tt.state, 0),
+				"cat > /etc/motd": reply("", 0),
+			}}
+			got, err := tt.line.Ensure(context.Background(), e, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Changed != (tt.want != "") {
+				t.Errorf("Ensure() changed = %v, want %v", got.Changed, tt.want != "")
+			}
+			var written string
+			if len(e.stdin) > 0 {
+				written = e.stdin[0]
+			}
+			if written != tt.want {
+				t.Errorf("Ensure() wrote %q, want %q", written, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ensure
+
+import (
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"path"
+	"strings"
+)
+
+// packageManager holds the commands checking whether a package is
+// installed, exiting non-zero if not, and installing it.
+type packageManager struct {
+	check   string
+	install string
+}
+
+// packageManagers are the supported package managers,
+// in the order they are detected.
+var packageManagers = []struct {
+	name string
+	packageManager
+}{
+	{"apt-get", packageManager{
+		check:   `dpkg-query -W -f='${Status}' %s 2>/dev/null | grep -q ' installed$'`,
+		install: "DEBIAN_FRONTEND=noninteractive apt-get install -y -q %s",
+	}},
+	{"dnf", packageManager{check: "rpm -q %s", install: "dnf install -y -q %s"}},
+	{"yum", packageManager{check: "rpm -q %s", install: "yum install -y -q %s"}},
+	{"zypper", packageManager{check: "rpm -q %s", install: "zypper --non-interactive install %s"}},
+	{"apk", packageManager{check: "apk info -e %s", install: "apk add -q %s"}},
+}
+
+// Package ensures a package is installed.
+type Package struct {
+	Name string
+	// Manager is the package manager, one of apt-get, dnf, yum, zypper
+	// and apk. It is detected on the host when empty.
+	Manager string
+}
+
+func (p *Package) String() string {
+	return "package " + p.Name
+}
+
+// Ensure installs the package when it is missing.
+func (p *Package) Ensure(ctx context.Context, e common.Executor, dryRun bool) (*Result, error) {
+	res := &Result{Step: p.String(), DryRun: dryRun}
+	name, pm, err := p.manager(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	pkg := common.ShellQuote(p.Name)
+	out, err := run(ctx, e, fmt.Sprintf(pm.check, pkg), nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if out.ExitCode == 0 {
+		return res, nil
+	}
+	res.Changed, res.Diff = true, fmt.Sprintf("install %s with %s\n", p.Name, name)
+	if !dryRun {
+		if _, err := run(ctx, e, fmt.Sprintf(pm.install, pkg), nil); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// manager returns the package manager of the step,
+// or the first one found on the host.
+func (p *Package) manager(ctx context.Context, e common.Executor) (string, *packageManager, error) {
+	if p.Manager != "" {
+		for _, m := range packageManagers {
+			if m.name == p.Manager {
+				return m.name, &m.packageManager, nil
+			}
+		}
+		return "", nil, fmt.Errorf("unsupported package manager %s", p.Manager)
+	}
+	names := make([]string, 0, len(packageManagers))
+	for _, m := range packageManagers {
+		names = append(names, m.name)
+	}
+	// command -v exits non-zero when any command is missing
+	out, err := run(ctx, e, "command -v "+strings.Join(names, " "), nil, 1, 127)
+	if err != nil {
+		return "", nil, err
+	}
+	found := make(map[string]bool)
+	for _, line := range strings.Fields(string(out.Stdout)) {
+		found[path.Base(line)] = true
+	}
+	for _, m := range packageManagers {
+		if found[m.name] {
+			return m.name, &m.packageManager, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no supported package manager found")
+}
@@ -0,0 +1,78 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ensure
+
+import (
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"strings"
+)
+
+// Service ensures a systemd unit is running and enabled, or not.
+type Service struct {
+	Unit    string
+	Running Switch
+	Enabled Switch
+}
+
+func (s *Service) String() string {
+	return "service " + s.Unit
+}
+
+// Ensure starts or stops the unit and enables or disables it with
+// systemctl. Units that cannot be enabled, such as static ones, are
+// only checked for Running.
+func (s *Service) Ensure(ctx context.Context, e common.Executor, dryRun bool) (*Result, error) {
+	res := &Result{Step: s.String(), DryRun: dryRun}
+	unit := common.ShellQuote(s.Unit)
+	// is-active and is-enabled exit non-zero for inactive and disabled
+	// units, so their output tells the state
+	out, err := run(ctx, e, "systemctl is-active -- "+unit+"; systemctl is-enabled -- "+unit, nil, 1, 3, 4)
+	if err != nil {
+		return nil, err
+	}
+	state := strings.Fields(string(out.Stdout))
+	if len(state) != 2 {
+		return nil, fmt.Errorf("unit %s not found", s.Unit)
+	}
+	active, enabled := state[0], state[1]
+
+	var diff strings.Builder
+	var cmds []string
+	switch {
+	case s.Running == On && active != "active":
+		fmt.Fprintf(&diff, "running: %s -> active\n", active)
+		cmds = append(cmds, "systemctl start -- "+unit)
+	case s.Running == Off && active != "inactive" && active != "failed":
+		fmt.Fprintf(&diff, "running: %s -> inactive\n", active)
+		cmds = append(cmds, "systemctl stop -- "+unit)
+	}
+	switch {
+	case s.Enabled == On && enabled != "enabled" && enabled != "static" && enabled != "alias":
+		fmt.Fprintf(&diff, "enabled: %s -> enabled\n", enabled)
+		cmds = append(cmds, "systemctl enable -- "+unit)
+	case s.Enabled == Off && enabled == "enabled":
+		fmt.Fprintf(&diff, "enabled: %s -> disabled\n", enabled)
+		cmds = append(cmds, "systemctl disable -- "+unit)
+	}
+
+	res.Changed, res.Diff = len(cmds) > 0, diff.String()
+	if res.Changed && !dryRun {
+		if _, err := run(ctx, e, strings.Join(cmds, " && "), nil); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
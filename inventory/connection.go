@@ -0,0 +1,206 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connection holds the settings used to reach a host, read from the
+// Ansible behavioral inventory variables.
+type Connection struct {
+	// Host is the address to connect to, from ansible_host,
+	// defaults to the inventory name of the host.
+	Host string
+	// Port is read from ansible_port, defaults to 22.
+	Port int
+	// User is read from ansible_user.
+	User string
+	// Password is read from ansible_password or ansible_ssh_pass.
+	Password string
+	// KeyFile is read from ansible_ssh_private_key_file.
+	KeyFile string
+	// Timeout is read in seconds from ansible_timeout.
+	Timeout time.Duration
+	// Become is set from ansible_become, ansible_become_method,
+	// ansible_become_user and ansible_become_password when
+	// ansible_become is true.
+	Become *common.BecomeOptions
+}
+
+// Connection returns the connection settings of the named host,
+// resolved from its merged variables.
+func (inv *Inventory) Connection(name string) (*Connection, error) {
+	vars := inv.HostVars(name)
+	if vars == nil {
+		return nil, fmt.Errorf("unknown host %s", name)
+	}
+	c := &Connection{Host: name, Port: 22}
+	var err error
+	if s := vars.String("ansible_host"); s != "" {
+		c.Host = s
+	}
+	if _, ok := vars["ansible_port"]; ok {
+		if c.Port, err = vars.Int("ansible_port"); err != nil {
+			return nil, fmt.Errorf("host %s: %v", name, err)
+		}
+	}
+	if _, ok := vars["ansible_timeout"]; ok {
+		seconds, err := vars.Int("ansible_timeout")
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %v", name, err)
+		}
+		c.Timeout = time.Duration(seconds) * time.Second
+	}
+	c.User = vars.String("ansible_user")
+	c.Password = vars.String("ansible_password")
+	if c.Password == "" {
+		c.Password = vars.String("ansible_ssh_pass")
+	}
+	c.KeyFile = vars.String("ansible_ssh_private_key_file")
+
+	become, err := vars.Bool("ansible_become")
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %v", name, err)
+	}
+	if become {
+		c.Become = &common.BecomeOptions{
+			User:     vars.String("ansible_become_user"),
+			Password: vars.String("ansible_become_password"),
+		}
+		switch method := vars.String("ansible_become_method"); method {
+		case "", "sudo":
+			c.Become.Method = common.Sudo
+		case "su":
+			c.Become.Method = common.Su
+		default:
+			return nil, fmt.Errorf("host %s: unsupported become method %s", name, method)
+		}
+	}
+	return c, nil
+}
+
+// SSH returns the common.SSH reaching the host. Its HostKeyCallback is
+// left nil, callers verifying host keys should set it before dialing.
+func (c *Connection) SSH() (*common.SSH, error) {
+	s := &common.SSH{
+		Addr:    net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		User:    c.User,
+		Timeout: c.Timeout,
+	}
+	if c.KeyFile != "" {
+		auth, err := common.KeyFile(expandHome(c.KeyFile))
+		if err != nil {
+			return nil, err
+		}
+		s.Auth = append(s.Auth, auth)
+	}
+	if c.Password != "" {
+		s.Auth = append(s.Auth, common.Password(c.Password))
+	}
+	return s, nil
+}
+
+// Executor returns a common.Executor running commands on the named host,
+// escalating privileges when the host has ansible_become set.
+func (inv *Inventory) Executor(name string) (common.Executor, error) {
+	c, err := inv.Connection(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Executor(nil)
+}
+
+// Executor returns a common.Executor running commands on the host,
+// verifying its host key with hostKeyCallback when not nil.
+func (c *Connection) Executor(hostKeyCallback ssh.HostKeyCallback) (common.Executor, error) {
+	s, err := c.SSH()
+	if err != nil {
+		return nil, err
+	}
+	s.HostKeyCallback = hostKeyCallback
+	e := common.NewExecutor(s)
+	if c.Become != nil {
+		e = common.Become(e, *c.Become)
+	}
+	return e, nil
+}
+
+// String returns the variable as a string, or "" when it is not set.
+func (v Vars) String(key string) string {
+	value, ok := v[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// Int returns the variable as an int, or 0 when it is not set.
+func (v Vars) Int(key string) (int, error) {
+	switch value := v[key].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return value, nil
+	case string:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("%s: invalid integer %q", key, value)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("%s: invalid integer %v", key, value)
+	}
+}
+
+// Bool returns the variable as a bool, accepting the yes/no,
+// true/false, on/off and 1/0 forms, or false when it is not set.
+func (v Vars) Bool(key string) (bool, error) {
+	switch value := v[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	case int:
+		return value != 0, nil
+	case string:
+		switch strings.ToLower(value) {
+		case "yes", "true", "on", "1", "y":
+			return true, nil
+		case "no", "false", "off", "0", "n", "":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%s: invalid boolean %v", key, v[key])
+}
+
+func expandHome(file string) string {
+	if file == "~" || strings.HasPrefix(file, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, file[1:])
+		}
+	}
+	return file
+}
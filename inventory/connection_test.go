@@ -0,0 +1,84 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"github.com/mo-silent/go-devops/common/sshtest"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInventory_Connection(t *testing.T) {
+	inv, err := ParseINI(strings.NewReader(`
+web1 ansible_host=10.0.0.1 ansible_port=2222 ansible_ssh_pass=secret ansible_timeout=5
+web2 ansible_become=yes ansible_become_method=su ansible_become_user=postgres
+web3 ansible_port=ssh
+web4 ansible_become=maybe
+web5 ansible_become=true ansible_become_method=doas
+[all:vars]
+ansible_user=ops
+`))
+	if err != nil {
+		t.Fatalf("ParseINI() error = %v", err)
+	}
+	tests := []struct {
+		host    string
+		want    *Connection
+		wantErr bool
+	}{
+		{
+			host: "web1",
+			want: &Connection{Host: "10.0.0.1", Port: 2222, User: "ops", Password: "secret", Timeout: 5 * time.Second},
+		},
+		{
+			host: "web2",
+			want: &Connection{Host: "web2", Port: 22, User: "ops", Become: &common.BecomeOptions{Method: common.Su, User: "postgres"}},
+		},
+		{host: "web3", wantErr: true},
+		{host: "web4", wantErr: true},
+		{host: "web5", wantErr: true},
+		{host: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := inv.Connection(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Connection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Connection() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_Executor(t *testing.T) {
+	s := sshtest.NewServer(sshtest.Config{Passwords: map[string]string{"ops": "secret"}})
+	defer s.Close()
+	s.Handle("sudo -n -u root -- /bin/sh -c whoami", sshtest.Reply("root\n", 0))
+	host, port, _ := net.SplitHostPort(s.Addr)
+
+	inv := New()
+	h := inv.AddHost("web1", "web")
+	h.Vars["ansible_host"] = host
+	h.Vars["ansible_port"] = port
+	inv.AddGroup("web").Vars["ansible_become"] = true
+	inv.AddGroup(All).Vars = Vars{"ansible_user": "ops", "ansible_password": "secret"}
+
+	e, err := inv.Executor("web1")
+	if err != nil {
+		t.Fatalf("Executor() error = %v", err)
+	}
+	defer e.Close()
+	res, err := e.Run(context.Background(), "whoami", common.ExecOptions{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := fmt.Sprintf("%d %s", res.ExitCode, res.Stdout); got != "0 root\n" {
+		t.Errorf("Run() = %q, want %q", got, "0 root\n")
+	}
+}
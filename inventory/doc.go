@@ -0,0 +1,27 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package inventory implements a host inventory with groups and variables
+
+Inventories are built in code or loaded from Ansible INI and YAML files,
+hosts are selected with Ansible patterns such as web:&prod:!canary, and
+the ansible_* connection variables of a host turn into a common.SSH
+and a common.Executor.
+
+References:
+
+	[Ansible inventory]: https://docs.ansible.com/ansible/latest/inventory_guide/intro_inventory.html
+	[Ansible patterns]: https://docs.ansible.com/ansible/latest/inventory_guide/intro_patterns.html
+*/
+package inventory // import "github.com/mo-silent/go-devops/inventory"
@@ -0,0 +1,230 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hostRange matches the first range of a host name, as in
+// www[01:50].example.com, db-[a:f] or node[0:20:5].
+var hostRange = regexp.MustCompile(`\[([0-9]+|[a-zA-Z]):([0-9]+|[a-zA-Z])(?::([0-9]+))?\]`)
+
+// ParseINI parses an inventory in the Ansible INI format. Hosts are
+// listed under [group] sections, with optional key=value variables,
+// group variables under [group:vars] and child groups under
+// [group:children]. Hosts listed before any section are ungrouped.
+// Variable values are kept as strings.
+func ParseINI(r io.Reader) (*Inventory, error) {
+	inv := New()
+	group, kind := Ungrouped, ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %s", n, line)
+			}
+			group, kind = line[1:len(line)-1], ""
+			if i := strings.LastIndex(group, ":"); i >= 0 {
+				group, kind = group[:i], group[i+1:]
+			}
+			switch kind {
+			case "", "vars", "children":
+			default:
+				return nil, fmt.Errorf("line %d: invalid section type %s", n, kind)
+			}
+			if group == "" {
+				return nil, fmt.Errorf("line %d: missing group name", n)
+			}
+			inv.AddGroup(group)
+			continue
+		}
+
+		var err error
+		switch kind {
+		case "vars":
+			var key, value string
+			key, value, err = splitVar(line)
+			if err == nil {
+				inv.groups[group].Vars[key] = value
+			}
+		case "children":
+			err = inv.AddChild(group, line)
+		default:
+			err = inv.parseHostLine(group, line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// parseHostLine adds the hosts of a line such as
+// "web[1:3]:2222 ansible_user=deploy" to group.
+func (inv *Inventory) parseHostLine(group, line string) error {
+	fields, err := splitFields(line)
+	if err != nil {
+		return err
+	}
+	vars := Vars{}
+	for _, field := range fields[1:] {
+		key, value, err := splitVar(field)
+		if err != nil {
+			return err
+		}
+		vars[key] = value
+	}
+	pattern := fields[0]
+	// A single colon outside ranges separates the port,
+	// IPv6 addresses are left alone.
+	bare := hostRange.ReplaceAllString(pattern, "")
+	if strings.Count(bare, ":") == 1 {
+		i := strings.LastIndex(pattern, ":")
+		if _, err := strconv.Atoi(pattern[i+1:]); err != nil {
+			return fmt.Errorf("invalid port in %s", pattern)
+		}
+		vars["ansible_port"] = pattern[i+1:]
+		pattern = pattern[:i]
+	}
+	names, err := expandHosts(pattern)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		var h *Host
+		if group == Ungrouped {
+			h = inv.AddHost(name)
+		} else {
+			h = inv.AddHost(name, group)
+		}
+		for k, v := range vars {
+			h.Vars[k] = v
+		}
+	}
+	return nil
+}
+
+// splitVar splits a key=value pair, unquoting the value.
+func splitVar(s string) (string, string, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid variable %s, expected key=value", s)
+	}
+	key, value := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+		value = value[1 : n-1]
+	}
+	return key, value, nil
+}
+
+// splitFields splits a host line on whitespace outside quotes and drops
+// a trailing comment. Quotes are kept for splitVar to remove.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			if field.Len() == 0 {
+				return fields, nil
+			}
+		case c == ' ' || c == '\t':
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			continue
+		}
+		field.WriteRune(c)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %s", line)
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// expandHosts expands the ranges of a host pattern. Numeric ranges keep
+// the width of their start, so db[01:03] gives db01, db02 and db03,
+// and an optional third number is the step.
+func expandHosts(pattern string) ([]string, error) {
+	loc := hostRange.FindStringSubmatchIndex(pattern)
+	if loc == nil {
+		return []string{pattern}, nil
+	}
+	start, end := pattern[loc[2]:loc[3]], pattern[loc[4]:loc[5]]
+	step := 1
+	if loc[6] >= 0 {
+		step, _ = strconv.Atoi(pattern[loc[6]:loc[7]])
+		if step <= 0 {
+			return nil, fmt.Errorf("invalid range step in %s", pattern)
+		}
+	}
+
+	var values []string
+	first, err1 := strconv.Atoi(start)
+	last, err2 := strconv.Atoi(end)
+	switch {
+	case err1 == nil && err2 == nil:
+		for i := first; i <= last; i += step {
+			values = append(values, fmt.Sprintf("%0*d", len(start), i))
+		}
+	case err1 != nil && err2 != nil:
+		for c := start[0]; c <= end[0]; c += byte(step) {
+			values = append(values, string(c))
+			if int(c)+step > 'z' {
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid range in %s", pattern)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("empty range in %s", pattern)
+	}
+
+	rest, err := expandHosts(pattern[loc[1]:])
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, v := range values {
+		for _, r := range rest {
+			names = append(names, pattern[:loc[0]]+v+r)
+		}
+	}
+	return names, nil
+}
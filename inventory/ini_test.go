@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	tests := []struct {
+		name     string
+		ini      string
+		want     map[string][]string
+		wantVars map[string]Vars
+		wantErr  bool
+	}{
+		{
+			name: "host variables",
+			ini:  "[web]\nweb1 ansible_host=10.0.0.1 motd=\"hello world\" # primary\n",
+			want: map[string][]string{"web": {"web1"}},
+			wantVars: map[string]Vars{
+				"web1": {"ansible_host": "10.0.0.1", "motd": "hello world"},
+			},
+		},
+		{
+			name: "ports",
+			ini:  "db[1:2]:5432\n[v6]\n2001:db8::1\n",
+			want: map[string][]string{Ungrouped: {"db1", "db2"}, "v6": {"2001:db8::1"}},
+			wantVars: map[string]Vars{
+				"db1":         {"ansible_port": "5432"},
+				"2001:db8::1": {},
+			},
+		},
+		{
+			name: "ranges",
+			ini:  "[web]\nweb[08:10].example.com\nnode-[a:c]\nshard[0:10:5]\n",
+			want: map[string][]string{"web": {
+				"web08.example.com", "web09.example.com", "web10.example.com",
+				"node-a", "node-b", "node-c", "shard0", "shard5", "shard10",
+			}},
+		},
+		{
+			name: "children before groups",
+			ini:  "; comment\n[prod:children]\nweb\n\n[web]\nweb1\n",
+			want: map[string][]string{"prod": {"web1"}, "web": {"web1"}},
+		},
+		{name: "bad section", ini: "[web\n", wantErr: true},
+		{name: "bad section type", ini: "[web:hosts]\n", wantErr: true},
+		{name: "bad variable", ini: "[web:vars]\nport\n", wantErr: true},
+		{name: "bad range", ini: "web[1:c]\n", wantErr: true},
+		{name: "bad port", ini: "web:ssh\n", wantErr: true},
+		{name: "unterminated quote", ini: "web1 motd='hi\n", wantErr: true},
+		{name: "cycle", ini: "[a:children]\nb\n[b:children]\na\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := ParseINI(strings.NewReader(tt.ini))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseINI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for group, want := range tt.want {
+				if got := names(inv.GroupHosts(group)); !reflect.DeepEqual(got, want) {
+					t.Errorf("ParseINI() group %s = %v, want %v", group, got, want)
+				}
+			}
+			for host, want := range tt.wantVars {
+				h, ok := inv.Host(host)
+				if !ok {
+					t.Fatalf("ParseINI() missing host %s", host)
+				}
+				if !reflect.DeepEqual(h.Vars, want) {
+					t.Errorf("ParseINI() host %s vars = %v, want %v", host, h.Vars, want)
+				}
+			}
+		})
+	}
+}
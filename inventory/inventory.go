@@ -0,0 +1,313 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Names of the groups every inventory has.
+const (
+	All       = "all"
+	Ungrouped = "ungrouped"
+)
+
+// Vars holds inventory variables.
+type Vars map[string]interface{}
+
+// Host is a host of the inventory.
+type Host struct {
+	Name string
+	// Vars are the variables set on the host itself, see
+	// Inventory.HostVars for the variables it inherits.
+	Vars Vars
+	// Groups are the groups the host is a direct member of.
+	Groups []string
+}
+
+// Group is a named set of hosts and child groups.
+type Group struct {
+	Name     string
+	Vars     Vars
+	Hosts    []string
+	Children []string
+}
+
+// Inventory is a set of hosts organised in groups.
+type Inventory struct {
+	hosts  map[string]*Host
+	order  []string
+	groups map[string]*Group
+}
+
+// New returns an empty inventory holding the all and ungrouped groups.
+func New() *Inventory {
+	inv := &Inventory{
+		hosts:  make(map[string]*Host),
+		groups: make(map[string]*Group),
+	}
+	inv.AddGroup(All)
+	inv.AddGroup(Ungrouped)
+	return inv
+}
+
+// Load reads an inventory file, parsed as YAML when its
+// extension is .yml, .yaml or .json and as INI otherwise.
+func Load(file string) (*Inventory, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch filepath.Ext(file) {
+	case ".yml", ".yaml", ".json":
+		return ParseYAML(f)
+	}
+	return ParseINI(f)
+}
+
+// AddHost adds a host to the inventory, or returns the existing one,
+// and makes it a member of groups. A host without any group other
+// than all is a member of ungrouped.
+func (inv *Inventory) AddHost(name string, groups ...string) *Host {
+	h, ok := inv.hosts[name]
+	if !ok {
+		h = &Host{Name: name, Vars: Vars{}}
+		inv.hosts[name] = h
+		inv.order = append(inv.order, name)
+	}
+	for _, g := range groups {
+		inv.addMember(inv.AddGroup(g), h)
+	}
+	if len(h.Groups) == 0 {
+		inv.addMember(inv.groups[Ungrouped], h)
+	}
+	return h
+}
+
+func (inv *Inventory) addMember(g *Group, h *Host) {
+	if g.Name == All || contains(h.Groups, g.Name) {
+		return
+	}
+	if g.Name != Ungrouped && contains(h.Groups, Ungrouped) {
+		h.Groups = remove(h.Groups, Ungrouped)
+		u := inv.groups[Ungrouped]
+		u.Hosts = remove(u.Hosts, h.Name)
+	}
+	h.Groups = append(h.Groups, g.Name)
+	g.Hosts = append(g.Hosts, h.Name)
+}
+
+// AddGroup adds a group to the inventory, or returns the existing one.
+// New groups are children of all.
+func (inv *Inventory) AddGroup(name string) *Group {
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &Group{Name: name, Vars: Vars{}}
+		inv.groups[name] = g
+		if name != All {
+			all := inv.groups[All]
+			all.Children = append(all.Children, name)
+		}
+	}
+	return g
+}
+
+// AddChild makes child a child group of parent, adding both if necessary.
+func (inv *Inventory) AddChild(parent, child string) error {
+	if child == All {
+		return fmt.Errorf("group %s cannot be a child group", All)
+	}
+	p, c := inv.AddGroup(parent), inv.AddGroup(child)
+	if parent == child || inv.descends(parent, child) {
+		return fmt.Errorf("adding %s to %s creates a group cycle", child, parent)
+	}
+	if !contains(p.Children, c.Name) {
+		p.Children = append(p.Children, c.Name)
+	}
+	return nil
+}
+
+// descends reports whether group is a descendant of ancestor.
+func (inv *Inventory) descends(group, ancestor string) bool {
+	for _, child := range inv.groups[ancestor].Children {
+		if child == group || inv.descends(group, child) {
+			return true
+		}
+	}
+	return false
+}
+
+// Host returns the named host.
+func (inv *Inventory) Host(name string) (*Host, bool) {
+	h, ok := inv.hosts[name]
+	return h, ok
+}
+
+// Group returns the named group.
+func (inv *Inventory) Group(name string) (*Group, bool) {
+	g, ok := inv.groups[name]
+	return g, ok
+}
+
+// Hosts returns all hosts in the order they were added.
+func (inv *Inventory) Hosts() []*Host {
+	hosts := make([]*Host, 0, len(inv.order))
+	for _, name := range inv.order {
+		hosts = append(hosts, inv.hosts[name])
+	}
+	return hosts
+}
+
+// Groups returns all groups sorted by name.
+func (inv *Inventory) Groups() []*Group {
+	groups := make([]*Group, 0, len(inv.groups))
+	for _, g := range inv.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// GroupHosts returns the hosts of a group and of its descendants,
+// in inventory order.
+func (inv *Inventory) GroupHosts(name string) []*Host {
+	if name == All {
+		return inv.Hosts()
+	}
+	members := make(map[string]bool)
+	inv.collect(name, members)
+	return inv.ordered(members)
+}
+
+func (inv *Inventory) collect(group string, members map[string]bool) {
+	g, ok := inv.groups[group]
+	if !ok {
+		return
+	}
+	for _, h := range g.Hosts {
+		members[h] = true
+	}
+	for _, child := range g.Children {
+		inv.collect(child, members)
+	}
+}
+
+// ordered returns the hosts of set in inventory order.
+func (inv *Inventory) ordered(set map[string]bool) []*Host {
+	var hosts []*Host
+	for _, name := range inv.order {
+		if set[name] {
+			hosts = append(hosts, inv.hosts[name])
+		}
+	}
+	return hosts
+}
+
+// HostVars returns the variables of a host merged with those of its
+// groups. As in Ansible, the variables of all are overridden by those
+// of parent groups, which are overridden by those of child groups and
+// finally by the host's own. Groups at the same depth are merged in
+// name order.
+func (inv *Inventory) HostVars(name string) Vars {
+	h, ok := inv.hosts[name]
+	if !ok {
+		return nil
+	}
+	depth := make(map[string]int)
+	for _, g := range h.Groups {
+		inv.ancestors(g, depth)
+	}
+	groups := make([]string, 0, len(depth))
+	for g := range depth {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if depth[groups[i]] != depth[groups[j]] {
+			return depth[groups[i]] < depth[groups[j]]
+		}
+		return groups[i] < groups[j]
+	})
+
+	vars := Vars{}
+	for k, v := range inv.groups[All].Vars {
+		vars[k] = v
+	}
+	for _, g := range groups {
+		for k, v := range inv.groups[g].Vars {
+			vars[k] = v
+		}
+	}
+	for k, v := range h.Vars {
+		vars[k] = v
+	}
+	vars["inventory_hostname"] = h.Name
+	return vars
+}
+
+// ancestors records group and its ancestors other than
+// all in depth, with their distance from all.
+func (inv *Inventory) ancestors(group string, depth map[string]int) {
+	if group == All {
+		return
+	}
+	if _, ok := depth[group]; ok {
+		return
+	}
+	depth[group] = inv.depth(group)
+	for _, g := range inv.groups {
+		if contains(g.Children, group) {
+			inv.ancestors(g.Name, depth)
+		}
+	}
+}
+
+// depth returns the length of the longest path from all to group.
+func (inv *Inventory) depth(group string) int {
+	max := 0
+	for _, g := range inv.groups {
+		if contains(g.Children, group) {
+			d := 1
+			if g.Name != All {
+				d = inv.depth(g.Name) + 1
+			}
+			if d > max {
+				max = d
+			}
+		}
+	}
+	return max
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
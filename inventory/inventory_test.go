@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testINI = `
+# ungrouped hosts come first
+bastion ansible_host=203.0.113.10
+
+[web]
+web[1:3] ansible_user=deploy
+canary.example.com:2222 color=yellow
+
+[db]
+db01 role=primary
+db02 role=replica
+
+[prod:children]
+web
+db
+
+[prod:vars]
+env=prod
+color=blue
+
+[all:vars]
+ansible_user=ops
+color=red
+`
+
+func testInventory(t *testing.T) *Inventory {
+	t.Helper()
+	inv, err := ParseINI(strings.NewReader(testINI))
+	if err != nil {
+		t.Fatalf("ParseINI() error = %v", err)
+	}
+	return inv
+}
+
+func names(hosts []*Host) []string {
+	var names []string
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+func TestInventory_GroupHosts(t *testing.T) {
+	inv := testInventory(t)
+	tests := []struct {
+		group string
+		want  []string
+	}{
+		{group: All, want: []string{"bastion", "web1", "web2", "web3", "canary.example.com", "db01", "db02"}},
+		{group: Ungrouped, want: []string{"bastion"}},
+		{group: "web", want: []string{"web1", "web2", "web3", "canary.example.com"}},
+		{group: "prod", want: []string{"web1", "web2", "web3", "canary.example.com", "db01", "db02"}},
+		{group: "missing", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.group, func(t *testing.T) {
+			if got := names(inv.GroupHosts(tt.group)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventory_HostVars(t *testing.T) {
+	inv := testInventory(t)
+	inv.AddGroup("canary").Vars["color"] = "green"
+	inv.AddHost("canary.example.com", "canary")
+	if err := inv.AddChild("web", "canary"); err != nil {
+		t.Fatalf("AddChild() error = %v", err)
+	}
+
+	tests := []struct {
+		host string
+		want Vars
+	}{
+		{
+			host: "bastion",
+			want: Vars{"ansible_host": "203.0.113.10", "ansible_user": "ops", "color": "red", "inventory_hostname": "bastion"},
+		},
+		{
+			host: "web1",
+			want: Vars{"ansible_user": "deploy", "color": "blue", "env": "prod", "inventory_hostname": "web1"},
+		},
+		{
+			host: "canary.example.com",
+			want: Vars{"ansible_port": "2222", "ansible_user": "ops", "color": "yellow", "env": "prod", "inventory_hostname": "canary.example.com"},
+		},
+		{
+			host: "missing",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := inv.HostVars(tt.host); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HostVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without its own color the canary takes the one of
+	// its deepest group over those of prod and all.
+	h, _ := inv.Host("canary.example.com")
+	delete(h.Vars, "color")
+	if got := inv.HostVars("canary.example.com")["color"]; got != "green" {
+		t.Errorf("HostVars() color = %v, want green", got)
+	}
+}
+
+func TestInventory_AddChild(t *testing.T) {
+	inv := New()
+	if err := inv.AddChild("prod", "web"); err != nil {
+		t.Fatalf("AddChild() error = %v", err)
+	}
+	if err := inv.AddChild("web", "canary"); err != nil {
+		t.Fatalf("AddChild() error = %v", err)
+	}
+	tests := []struct {
+		parent, child string
+	}{
+		{parent: "canary", child: "prod"},
+		{parent: "web", child: "web"},
+		{parent: "web", child: All},
+	}
+	for _, tt := range tests {
+		if err := inv.AddChild(tt.parent, tt.child); err == nil {
+			t.Errorf("AddChild(%s, %s) error = nil, want error", tt.parent, tt.child)
+		}
+	}
+}
+
+func TestInventory_ungrouped(t *testing.T) {
+	inv := New()
+	inv.AddHost("app")
+	inv.AddHost("app", "web")
+	if got := names(inv.GroupHosts(Ungrouped)); got != nil {
+		t.Errorf("GroupHosts(ungrouped) = %v, want none", got)
+	}
+	if h, _ := inv.Host("app"); !reflect.DeepEqual(h.Groups, []string{"web"}) {
+		t.Errorf("Groups = %v, want [web]", h.Groups)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	ini := filepath.Join(dir, "hosts")
+	yml := filepath.Join(dir, "hosts.yml")
+	os.WriteFile(ini, []byte("[web]\nweb1\n"), 0600)
+	os.WriteFile(yml, []byte("web:\n  hosts:\n    web1:\n"), 0600)
+	for _, file := range []string{ini, yml} {
+		inv, err := Load(file)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", file, err)
+		}
+		if got := names(inv.GroupHosts("web")); !reflect.DeepEqual(got, []string{"web1"}) {
+			t.Errorf("Load(%s) web = %v, want [web1]", file, got)
+		}
+	}
+	if _, err := Load(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Load() of a missing file succeeded")
+	}
+}
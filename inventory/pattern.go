@@ -0,0 +1,217 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// subscript matches a term selecting hosts of a group by position,
+// as in web[0], web[-1], web[1:] or web[0:2].
+var subscript = regexp.MustCompile(`^(.+)\[(?:(-?\d+)|(-?\d*):(-?\d*))\]$`)
+
+// Select returns the hosts matching an Ansible host pattern, in
+// inventory order. A pattern is a list of terms separated by ':' or
+// ','. Hosts matching any plain term are selected, then those not
+// matching every term prefixed with '&' are dropped, and finally those
+// matching a term prefixed with '!' are removed, so "web:&prod:!canary"
+// selects the production web servers except the canaries. A pattern
+// without plain terms starts from all hosts, as in "&prod:!canary".
+//
+// A term is all or *, the name of a group or of a host, a shell glob
+// matched against group and host names, a regular expression prefixed
+// with '~' matched against host names, or a group followed by a
+// subscript such as web[0] or web[0:2]. A term matching neither a host
+// nor a group is an error, so a misspelled exclusion is not ignored.
+func (inv *Inventory) Select(pattern string) ([]*Host, error) {
+	terms := splitPattern(pattern)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("empty host pattern")
+	}
+	selected := make(map[string]bool)
+	var intersect, exclude []map[string]bool
+	plain := false
+	for _, term := range terms {
+		op := term[0]
+		if op == '&' || op == '!' {
+			term = term[1:]
+		}
+		hosts, err := inv.match(term)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case '&':
+			intersect = append(intersect, hosts)
+		case '!':
+			exclude = append(exclude, hosts)
+		default:
+			plain = true
+			for h := range hosts {
+				selected[h] = true
+			}
+		}
+	}
+	if !plain {
+		for _, h := range inv.order {
+			selected[h] = true
+		}
+	}
+	for _, set := range intersect {
+		for h := range selected {
+			if !set[h] {
+				delete(selected, h)
+			}
+		}
+	}
+	for _, set := range exclude {
+		for h := range set {
+			delete(selected, h)
+		}
+	}
+	return inv.ordered(selected), nil
+}
+
+// splitPattern splits a pattern into its terms. Patterns holding a comma
+// are split on commas only, so IPv6 addresses can be listed, otherwise
+// on colons outside subscripts.
+func splitPattern(pattern string) []string {
+	var terms []string
+	add := func(term string) {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	if strings.Contains(pattern, ",") {
+		for _, term := range strings.Split(pattern, ",") {
+			add(term)
+		}
+		return terms
+	}
+	depth, start := 0, 0
+	for i, c := range pattern {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				add(pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(pattern[start:])
+	return terms
+}
+
+// match returns the names of the hosts matching a single term.
+func (inv *Inventory) match(term string) (map[string]bool, error) {
+	hosts := make(map[string]bool)
+	addGroup := func(group string) {
+		for _, h := range inv.GroupHosts(group) {
+			hosts[h.Name] = true
+		}
+	}
+
+	switch {
+	case term == All || term == "*":
+		addGroup(All)
+		return hosts, nil
+	case strings.HasPrefix(term, "~"):
+		re, err := regexp.Compile(term[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %v", term, err)
+		}
+		for _, name := range inv.order {
+			if re.MatchString(name) {
+				hosts[name] = true
+			}
+		}
+		return hosts, nil
+	}
+	if _, ok := inv.groups[term]; ok {
+		addGroup(term)
+		return hosts, nil
+	}
+	if _, ok := inv.hosts[term]; ok {
+		hosts[term] = true
+		return hosts, nil
+	}
+	if m := subscript.FindStringSubmatch(term); m != nil {
+		if _, ok := inv.groups[m[1]]; ok {
+			return inv.slice(m[1], m[2:])
+		}
+	}
+	if strings.ContainsAny(term, "*?[") {
+		matched := false
+		for name := range inv.groups {
+			ok, err := path.Match(term, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid host pattern %q: %v", term, err)
+			}
+			if ok {
+				addGroup(name)
+				matched = true
+			}
+		}
+		for _, name := range inv.order {
+			if ok, _ := path.Match(term, name); ok {
+				hosts[name] = true
+				matched = true
+			}
+		}
+		if matched {
+			return hosts, nil
+		}
+	}
+	return nil, fmt.Errorf("host pattern %q matches no host or group", term)
+}
+
+// slice returns the hosts of group selected by a subscript, given as
+// the index or the start and end of the range matched by subscript.
+// As in Ansible, the end is inclusive, so web[0:2] selects three hosts.
+func (inv *Inventory) slice(group string, sub []string) (map[string]bool, error) {
+	members := inv.GroupHosts(group)
+	index := func(s string, def int) int {
+		if s == "" {
+			return def
+		}
+		i, _ := strconv.Atoi(s)
+		if i < 0 {
+			i += len(members)
+		}
+		return i
+	}
+	var start, end int
+	if sub[0] != "" {
+		start = index(sub[0], 0)
+		end = start + 1
+	} else {
+		start, end = index(sub[1], 0), index(sub[2], len(members)-1)+1
+	}
+	if start < 0 || start >= len(members) || end > len(members) || start >= end {
+		return nil, fmt.Errorf("subscript of %s out of range: %d hosts", group, len(members))
+	}
+	hosts := make(map[string]bool)
+	for _, h := range members[start:end] {
+		hosts[h.Name] = true
+	}
+	return hosts, nil
+}
@@ -0,0 +1,51 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInventory_Select(t *testing.T) {
+	inv := testInventory(t)
+	inv.AddHost("canary.example.com", "canary")
+	inv.AddGroup("empty")
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "all", want: []string{"bastion", "web1", "web2", "web3", "canary.example.com", "db01", "db02"}},
+		{pattern: "web:db", want: []string{"web1", "web2", "web3", "canary.example.com", "db01", "db02"}},
+		{pattern: "web:&prod:!canary", want: []string{"web1", "web2", "web3"}},
+		{pattern: "&prod:!web", want: []string{"db01", "db02"}},
+		{pattern: "bastion,db02", want: []string{"bastion", "db02"}},
+		{pattern: "web*:!web2", want: []string{"web1", "web3", "canary.example.com"}},
+		{pattern: "*.example.com", want: []string{"canary.example.com"}},
+		{pattern: "~db0[12]", want: []string{"db01", "db02"}},
+		{pattern: "web[0]", want: []string{"web1"}},
+		{pattern: "web[-1]", want: []string{"canary.example.com"}},
+		{pattern: "web[1:2]:db", want: []string{"web2", "web3", "db01", "db02"}},
+		{pattern: "web[0:2]", want: []string{"web1", "web2", "web3"}},
+		{pattern: "web[:-2]", want: []string{"web1", "web2", "web3"}},
+		{pattern: "web[2:]", want: []string{"web3", "canary.example.com"}},
+		{pattern: "db:&web", want: nil},
+		{pattern: "empty:&prod", want: nil},
+		{pattern: "!web:!canary", want: []string{"bastion", "db01", "db02"}},
+		{pattern: "web[9]", wantErr: true},
+		{pattern: "web[1:4]", wantErr: true},
+		{pattern: "web:!canaries", wantErr: true},
+		{pattern: "~[", wantErr: true},
+		{pattern: " ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			hosts, err := inv.Select(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := names(hosts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+// ParseYAML parses an inventory in the Ansible YAML format, where each
+// top-level key is a group holding optional hosts, vars and children
+// mappings. Hosts keep the order of the document and host names may
+// hold ranges as in the INI format.
+func ParseYAML(r io.Reader) (*Inventory, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return New(), nil
+		}
+		return nil, err
+	}
+	inv := New()
+	root := doc.Content[0]
+	if err := mapping(root, func(key string, value *yaml.Node) error {
+		return inv.parseYAMLGroup(key, value)
+	}); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (inv *Inventory) parseYAMLGroup(name string, node *yaml.Node) error {
+	g := inv.AddGroup(name)
+	if isNull(node) {
+		return nil
+	}
+	return mapping(node, func(key string, value *yaml.Node) error {
+		if isNull(value) {
+			return nil
+		}
+		switch key {
+		case "hosts":
+			return mapping(value, func(pattern string, vars *yaml.Node) error {
+				names, err := expandHosts(pattern)
+				if err != nil {
+					return fmt.Errorf("line %d: %v", vars.Line, err)
+				}
+				hostVars := Vars{}
+				if !isNull(vars) {
+					if err := vars.Decode(&hostVars); err != nil {
+						return err
+					}
+				}
+				for _, n := range names {
+					var h *Host
+					if name == All || name == Ungrouped {
+						h = inv.AddHost(n)
+					} else {
+						h = inv.AddHost(n, name)
+					}
+					for k, v := range hostVars {
+						h.Vars[k] = v
+					}
+				}
+				return nil
+			})
+		case "vars":
+			vars := Vars{}
+			if err := value.Decode(&vars); err != nil {
+				return err
+			}
+			for k, v := range vars {
+				g.Vars[k] = v
+			}
+			return nil
+		case "children":
+			return mapping(value, func(child string, node *yaml.Node) error {
+				if name != All {
+					if err := inv.AddChild(name, child); err != nil {
+						return fmt.Errorf("line %d: %v", node.Line, err)
+					}
+				}
+				return inv.parseYAMLGroup(child, node)
+			})
+		}
+		return fmt.Errorf("line %d: unexpected key %s in group %s", value.Line, key, name)
+	})
+}
+
+// mapping calls fn with each key and value of a mapping node, in order.
+func mapping(node *yaml.Node, fn func(key string, value *yaml.Node) error) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if err := fn(node.Content[i].Value, node.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
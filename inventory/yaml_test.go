@@ -0,0 +1,78 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testYAML = `
+all:
+  hosts:
+    bastion:
+      ansible_host: 203.0.113.10
+  vars:
+    ansible_user: ops
+  children:
+    prod:
+      vars:
+        env: prod
+      children:
+        web:
+          hosts:
+            web[1:2]:
+              ansible_port: 2222
+            canary.example.com:
+        db:
+          hosts:
+            db01:
+              replicas: [db02, db03]
+`
+
+func TestParseYAML(t *testing.T) {
+	inv, err := ParseYAML(strings.NewReader(testYAML))
+	if err != nil {
+		t.Fatalf("ParseYAML() error = %v", err)
+	}
+	groups := map[string][]string{
+		All:       {"bastion", "web1", "web2", "canary.example.com", "db01"},
+		Ungrouped: {"bastion"},
+		"prod":    {"web1", "web2", "canary.example.com", "db01"},
+		"web":     {"web1", "web2", "canary.example.com"},
+	}
+	for group, want := range groups {
+		if got := names(inv.GroupHosts(group)); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseYAML() group %s = %v, want %v", group, got, want)
+		}
+	}
+	want := Vars{"ansible_user": "ops", "env": "prod", "ansible_port": 2222, "inventory_hostname": "web2"}
+	if got := inv.HostVars("web2"); !reflect.DeepEqual(got, want) {
+		t.Errorf("HostVars() = %v, want %v", got, want)
+	}
+	if got := inv.HostVars("db01")["replicas"]; !reflect.DeepEqual(got, []interface{}{"db02", "db03"}) {
+		t.Errorf("HostVars() replicas = %v", got)
+	}
+}
+
+func TestParseYAML_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{name: "not a mapping", yaml: "- web1\n"},
+		{name: "unknown key", yaml: "web:\n  host:\n    web1:\n"},
+		{name: "hosts list", yaml: "web:\n  hosts:\n    - web1\n"},
+		{name: "cycle", yaml: "a:\n  children:\n    b:\n      children:\n        a:\n"},
+		{name: "syntax", yaml: "web: [\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseYAML(strings.NewReader(tt.yaml)); err == nil {
+				t.Errorf("ParseYAML() error = nil, want error")
+			}
+		})
+	}
+	if inv, err := ParseYAML(strings.NewReader("")); err != nil || len(inv.Hosts()) != 0 {
+		t.Errorf("ParseYAML() of an empty document = %v, %v", inv, err)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// This file decodes the XOR chunks of the Prometheus TSDB, see
+// tsdb/chunkenc/xor.go in the Prometheus repository.
+
+var errChunk = errors.New("invalid XOR chunk")
+
+// bitReader reads a stream of bits, most significant bit first.
+type bitReader struct {
+	b   []byte
+	pos uint // position of the next bit
+}
+
+func (r *bitReader) readBits(n uint8) (uint64, error) {
+	if r.pos+uint(n) > uint(len(r.b))*8 {
+		return 0, errChunk
+	}
+	var v uint64
+	for i := uint8(0); i < n; i++ {
+		bit := r.b[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+	return v, nil
+}
+
+func (r *bitReader) ReadByte() (byte, error) {
+	v, err := r.readBits(8)
+	return byte(v), err
+}
+
+// decodeXORChunk returns the samples of an XOR chunk.
+func decodeXORChunk(chunk []byte) ([]MetricValues, error) {
+	if len(chunk) < 2 {
+		return nil, errChunk
+	}
+	num := int(binary.BigEndian.Uint16(chunk))
+	r := &bitReader{b: chunk[2:]}
+	samples := make([]MetricValues, 0, num)
+
+	var (
+		t, tDelta         int64
+		value             uint64
+		leading, trailing uint8
+	)
+	for i := 0; i < num; i++ {
+		switch i {
+		case 0:
+			var err error
+			if t, err = binary.ReadVarint(r); err != nil {
+				return nil, errChunk
+			}
+			if value, err = r.readBits(64); err != nil {
+				return nil, err
+			}
+			samples = append(samples, MetricValues{Timestamp: t, Value: math.Float64frombits(value)})
+			continue
+		case 1:
+			d, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, errChunk
+			}
+			tDelta = int64(d)
+		default:
+			// delta of delta, prefixed by 0, 10, 110, 1110 or 1111
+			var prefix uint8
+			for ; prefix < 4; prefix++ {
+				bit, err := r.readBits(1)
+				if err != nil {
+					return nil, err
+				}
+				if bit == 0 {
+					break
+				}
+			}
+			var dod int64
+			if sz := [...]uint8{0, 14, 17, 20, 64}[prefix]; sz == 64 {
+				bits, err := r.readBits(64)
+				if err != nil {
+					return nil, err
+				}
+				dod = int64(bits)
+			} else if sz > 0 {
+				bits, err := r.readBits(sz)
+				if err != nil {
+					return nil, err
+				}
+				// negative numbers come back as high unsigned numbers
+				if bits > 1<<(sz-1) {
+					bits -= 1 << sz
+				}
+				dod = int64(bits)
+			}
+			tDelta += dod
+		}
+		t += tDelta
+
+		// value xor the previous value: 0 if equal, 10 with the previous
+		// leading and trailing zeros, or 11 with new ones.
+		bit, err := r.readBits(1)
+		if err != nil {
+			return nil, err
+		}
+		if bit == 1 {
+			if bit, err = r.readBits(1); err != nil {
+				return nil, err
+			}
+			if bit == 1 {
+				bits, err := r.readBits(5)
+				if err != nil {
+					return nil, err
+				}
+				leading = uint8(bits)
+				if bits, err = r.readBits(6); err != nil {
+					return nil, err
+				}
+				mbits := uint8(bits)
+				// 0 significant bits means 64, which overflows 6 bits
+				if mbits == 0 {
+					mbits = 64
+				}
+				if leading+mbits > 64 {
+					return nil, errChunk
+				}
+				trailing = 64 - leading - mbits
+			}
+			bits, err := r.readBits(64 - leading - trailing)
+			if err != nil {
+				return nil, err
+			}
+			value ^= bits << trailing
+		}
+		samples = append(samples, MetricValues{Timestamp: t, Value: math.Float64frombits(value)})
+	}
+	return samples, nil
+}
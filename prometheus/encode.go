@@ -0,0 +1,382 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is an encoding of results.
+type Format string
+
+const (
+	FormatJSON     Format = "json"     // the JSON of ConvertByte
+	FormatCSV      Format = "csv"      // CSV with a header row
+	FormatMarkdown Format = "markdown" // a GitHub flavored Markdown table
+	FormatHTML     Format = "html"     // an HTML table
+	// FormatColumnar is a compact JSON of the labels of the series and
+	// a column of values by series, sharing a column of timestamps in
+	// milliseconds. Missing values and NaN are null. DecodeColumnar
+	// decodes it.
+	FormatColumnar Format = "columnar"
+)
+
+// Layout is the layout of the tables of FormatCSV, FormatMarkdown and FormatHTML.
+type Layout int
+
+const (
+	// LayoutLong has a row by sample: its timestamp, its labels and its value.
+	LayoutLong Layout = iota
+	// LayoutWide has a row by timestamp: the timestamp and the value of
+	// each series, empty if missing.
+	LayoutWide
+)
+
+// Timestamp formats of EncodeOptions other than the layouts of time.Format.
+const (
+	TimeUnix      = "unix"    // seconds, with milliseconds as decimals
+	TimeUnixMilli = "unix_ms" // milliseconds
+)
+
+// EncodeOptions configures Encode.
+type EncodeOptions struct {
+	Format Format // defaults to FormatJSON
+	Layout Layout
+	// TimeFormat is a layout of time.Format, TimeUnix or TimeUnixMilli,
+	// defaults to time.RFC3339.
+	TimeFormat string
+	Location   *time.Location // of the timestamps, defaults to UTC
+	// Labels are the label columns of LayoutLong, defaults to all the
+	// labels of the series sorted by name.
+	Labels []string
+	// Legend names the series columns of LayoutWide, a format of
+	// Labels.Legend such as {{instance}}.
+	Legend string
+}
+
+// Encoder encodes results.
+type Encoder interface {
+	Encode(w io.Writer, opts EncodeOptions) error
+}
+
+// Encode writes the series to w in the format of opts.
+// Native histogram samples are not encoded but in JSON.
+func (m Matrix) Encode(w io.Writer, opts EncodeOptions) error {
+	return encode(w, m, m, opts)
+}
+
+// Encode writes the samples to w in the format of opts,
+// as series of a sample.
+func (v Vector) Encode(w io.Writer, opts EncodeOptions) error {
+	m := make(Matrix, 0, len(v))
+	for _, r := range v {
+		if r.Histogram == nil {
+			m = append(m, MatrixResult{Metric: r.Metric, Labels: r.Labels, Values: []MetricValues{r.Values}})
+		}
+	}
+	return encode(w, v, m, opts)
+}
+
+// Encode writes the scalar to w in the format of opts,
+// as a series without labels.
+func (s Scalar) Encode(w io.Writer, opts EncodeOptions) error {
+	return encode(w, s, Matrix{{Labels: Labels{}, Values: []MetricValues{s.Value}}}, opts)
+}
+
+// encode writes r, as m unless in JSON, to w.
+func encode(w io.Writer, r ResultValues, m Matrix, opts EncodeOptions) error {
+	switch opts.Format {
+	case "", FormatJSON:
+		b, err := r.ConvertByte()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case FormatColumnar:
+		return json.NewEncoder(w).Encode(newColumnar(m))
+	}
+
+	var header []string
+	var rows [][]string
+	if opts.Layout == LayoutWide {
+		header, rows = opts.wide(m)
+	} else {
+		header, rows = opts.long(m)
+	}
+	switch opts.Format {
+	case FormatCSV:
+		return csv.NewWriter(w).WriteAll(append([][]string{header}, rows...))
+	case FormatMarkdown:
+		return writeMarkdown(w, header, rows)
+	case FormatHTML:
+		return writeHTML(w, header, rows)
+	}
+	return fmt.Errorf("unsupported format %q", opts.Format)
+}
+
+// timestamp formats the timestamp ms in milliseconds.
+func (o EncodeOptions) timestamp(ms int64) string {
+	switch o.TimeFormat {
+	case TimeUnix:
+		return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64)
+	case TimeUnixMilli:
+		return strconv.FormatInt(ms, 10)
+	}
+	layout, loc := o.TimeFormat, o.Location
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).In(loc).Format(layout)
+}
+
+// formatValue formats v, or "" if it is NaN.
+func formatValue(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// labelNames returns the names of the labels of the series, sorted.
+func labelNames(m Matrix) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, r := range m {
+		for name := range r.Labels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (o EncodeOptions) long(m Matrix) ([]string, [][]string) {
+	labels := o.Labels
+	if labels == nil {
+		labels = labelNames(m)
+	}
+	header := append(append([]string{"timestamp"}, labels...), "value")
+	var rows [][]string
+	for _, r := range m {
+		for _, v := range r.Values {
+			row := make([]string, 0, len(header))
+			row = append(row, o.timestamp(v.Timestamp))
+			for _, name := range labels {
+				row = append(row, r.Labels[name])
+			}
+			rows = append(rows, append(row, formatValue(v.Value)))
+		}
+	}
+	return header, rows
+}
+
+func (o EncodeOptions) wide(m Matrix) ([]string, [][]string) {
+	header := []string{"timestamp"}
+	columns := make([]map[int64]float64, 0, len(m))
+	var timestamps []int64
+	seen := make(map[int64]bool)
+	for _, r := range m {
+		name := "value"
+		if len(r.Labels) > 0 || o.Legend != "" {
+			name = r.Labels.Legend(o.Legend)
+		}
+		header = append(header, name)
+		column := make(map[int64]float64, len(r.Values))
+		for _, v := range r.Values {
+			column[v.Timestamp] = v.Value
+			if !seen[v.Timestamp] {
+				seen[v.Timestamp] = true
+				timestamps = append(timestamps, v.Timestamp)
+			}
+		}
+		columns = append(columns, column)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	rows := make([][]string, 0, len(timestamps))
+	for _, ts := range timestamps {
+		row := make([]string, 0, len(header))
+		row = append(row, o.timestamp(ts))
+		for _, column := range columns {
+			v, ok := column[ts]
+			if !ok {
+				v = math.NaN()
+			}
+			row = append(row, formatValue(v))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+var markdownEscaper = strings.NewReplacer(`|`, `\|`, "\r\n", "<br>", "\n", "<br>")
+
+func writeMarkdown(w io.Writer, header []string, rows [][]string) error {
+	bw := bufio.NewWriter(w)
+	writeRow := func(cells []string) {
+		bw.WriteString("|")
+		for _, c := range cells {
+			bw.WriteString(" " + markdownEscaper.Replace(c) + " |")
+		}
+		bw.WriteString("\n")
+	}
+	writeRow(header)
+	bw.WriteString("|" + strings.Repeat(" --- |", len(header)) + "\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return bw.Flush()
+}
+
+func writeHTML(w io.Writer, header []string, rows [][]string) error {
+	bw := bufio.NewWriter(w)
+	writeRow := func(tag string, cells []string) {
+		bw.WriteString("<tr>")
+		for _, c := range cells {
+			bw.WriteString("<" + tag + ">" + html.EscapeString(c) + "</" + tag + ">")
+		}
+		bw.WriteString("</tr>\n")
+	}
+	bw.WriteString("<table>\n<thead>\n")
+	writeRow("th", header)
+	bw.WriteString("</thead>\n<tbody>\n")
+	for _, row := range rows {
+		writeRow("td", row)
+	}
+	bw.WriteString("</tbody>\n</table>\n")
+	return bw.Flush()
+}
+
+// columnar is the JSON of FormatColumnar.
+type columnar struct {
+	Labels     []string        `json:"labels"`
+	Series     [][]string      `json:"series"` // values of Labels by series
+	Timestamps []int64         `json:"timestamps"`
+	Values     [][]columnValue `json:"values"` // values by series and timestamp
+}
+
+// columnValue is a value of FormatColumnar, null if NaN and a string if infinite.
+type columnValue float64
+
+func (v columnValue) MarshalJSON() ([]byte, error) {
+	f := float64(v)
+	switch {
+	case math.IsNaN(f):
+		return []byte("null"), nil
+	case math.IsInf(f, 0):
+		return []byte(`"` + strconv.FormatFloat(f, 'f', -1, 64) + `"`), nil
+	}
+	return []byte(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+func (v *columnValue) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		*v = columnValue(math.NaN())
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %s", b)
+	}
+	*v = columnValue(f)
+	return nil
+}
+
+func newColumnar(m Matrix) columnar {
+	c := columnar{Labels: labelNames(m), Series: [][]string{}, Timestamps: []int64{}, Values: [][]columnValue{}}
+	index := make(map[int64]int)
+	for _, r := range m {
+		for _, v := range r.Values {
+			if _, ok := index[v.Timestamp]; !ok {
+				index[v.Timestamp] = 0
+				c.Timestamps = append(c.Timestamps, v.Timestamp)
+			}
+		}
+	}
+	sort.Slice(c.Timestamps, func(i, j int) bool { return c.Timestamps[i] < c.Timestamps[j] })
+	for i, ts := range c.Timestamps {
+		index[ts] = i
+	}
+	for _, r := range m {
+		labels := make([]string, 0, len(c.Labels))
+		for _, name := range c.Labels {
+			labels = append(labels, r.Labels[name])
+		}
+		values := make([]columnValue, len(c.Timestamps))
+		for i := range values {
+			values[i] = columnValue(math.NaN())
+		}
+		for _, v := range r.Values {
+			values[index[v.Timestamp]] = columnValue(v.Value)
+		}
+		c.Series = append(c.Series, labels)
+		c.Values = append(c.Values, values)
+	}
+	return c
+}
+
+// DecodeColumnar decodes series encoded in FormatColumnar. Null values
+// are missing, so NaN values are not decoded.
+func DecodeColumnar(r io.Reader) (Matrix, error) {
+	var c columnar
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, err
+	}
+	if len(c.Values) != len(c.Series) {
+		return nil, fmt.Errorf("columnar has %d series but %d value columns", len(c.Series), len(c.Values))
+	}
+	m := make(Matrix, 0, len(c.Series))
+	for i, values := range c.Series {
+		if len(values) != len(c.Labels) {
+			return nil, fmt.Errorf("series %d has %d label values, want %d", i, len(values), len(c.Labels))
+		}
+		if len(c.Values[i]) != len(c.Timestamps) {
+			return nil, fmt.Errorf("series %d has %d values, want %d", i, len(c.Values[i]), len(c.Timestamps))
+		}
+		labels := make(Labels, len(values))
+		for j, v := range values {
+			if v != "" {
+				labels[c.Labels[j]] = v
+			}
+		}
+		r := MatrixResult{Metric: labels.String(), Labels: labels}
+		for j, v := range c.Values[i] {
+			if !math.IsNaN(float64(v)) {
+				r.Values = append(r.Values, MetricValues{Timestamp: c.Timestamps[j], Value: float64(v)})
+			}
+		}
+		m = append(m, r)
+	}
+	return m, nil
+}
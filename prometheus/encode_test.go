@@ -0,0 +1,123 @@
+package prometheus
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	up := Labels{"__name__": "up", "job": "api", "instance": "a:9090"}
+	db := Labels{"__name__": "up", "job": "db"}
+	m := Matrix{series(up, 60000, 60000, 1, 0), series(db, 120000, 60000, 1, math.NaN())}
+	v := Vector{
+		{Metric: up.String(), Labels: up, Values: MetricValues{Timestamp: 1500, Value: 1}},
+		{Metric: db.String(), Labels: db, Values: MetricValues{Timestamp: 1500, Value: 0.5}},
+		{Labels: up, Histogram: &HistogramValues{Timestamp: 1500, Count: 1}},
+	}
+	tests := []struct {
+		name   string
+		result Encoder
+		opts   EncodeOptions
+		want   string
+	}{
+		{
+			name:   "csv long",
+			result: m,
+			opts:   EncodeOptions{Format: FormatCSV},
+			want: "timestamp,__name__,instance,job,value\n" +
+				"1970-01-01T00:01:00Z,up,a:9090,api,1\n" +
+				"1970-01-01T00:02:00Z,up,a:9090,api,0\n" +
+				"1970-01-01T00:02:00Z,up,,db,1\n" +
+				"1970-01-01T00:03:00Z,up,,db,\n",
+		},
+		{
+			name:   "csv wide",
+			result: m,
+			opts:   EncodeOptions{Format: FormatCSV, Layout: LayoutWide, TimeFormat: TimeUnix, Legend: "{{job}}"},
+			want:   "timestamp,api,db\n60,1,\n120,0,1\n180,,\n",
+		},
+		{
+			name:   "csv label columns",
+			result: v,
+			opts:   EncodeOptions{Format: FormatCSV, Labels: []string{"job"}, TimeFormat: TimeUnixMilli},
+			want:   "timestamp,job,value\n1500,api,1\n1500,db,0.5\n",
+		},
+		{
+			name:   "markdown",
+			result: v,
+			opts:   EncodeOptions{Format: FormatMarkdown, Layout: LayoutWide, TimeFormat: "15:04:05.000", Location: time.FixedZone("", 3600)},
+			want: "| timestamp | up{instance=\"a:9090\", job=\"api\"} | up{job=\"db\"} |\n" +
+				"| --- | --- | --- |\n" +
+				"| 01:00:01.500 | 1 | 0.5 |\n",
+		},
+		{
+			name:   "markdown escaping",
+			result: Vector{{Labels: Labels{"path": "a|b\nc"}, Values: MetricValues{Value: 1}}},
+			opts:   EncodeOptions{Format: FormatMarkdown, TimeFormat: TimeUnix},
+			want:   "| timestamp | path | value |\n| --- | --- | --- |\n| 0 | a\\|b<br>c | 1 |\n",
+		},
+		{
+			name:   "html",
+			result: Scalar{Value: MetricValues{Timestamp: 2000, Value: math.Inf(1)}},
+			opts:   EncodeOptions{Format: FormatHTML, Layout: LayoutWide, TimeFormat: "<2006>"},
+			want: "<table>\n<thead>\n<tr><th>timestamp</th><th>value</th></tr>\n</thead>\n" +
+				"<tbody>\n<tr><td>&lt;1970&gt;</td><td>+Inf</td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:   "json",
+			result: Scalar{Value: MetricValues{Timestamp: 2000, Value: 3}},
+			want:   `{"value":{"Timestamp":2000,"Value":3}}`,
+		},
+		{
+			name:   "columnar",
+			result: m,
+			opts:   EncodeOptions{Format: FormatColumnar},
+			want: `{"labels":["__name__","instance","job"],"series":[["up","a:9090","api"],["up","","db"]],` +
+				`"timestamps":[60000,120000,180000],"values":[[1,0,null],[null,1,null]]}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.result.Encode(&b, tt.opts); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("Encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode_UnsupportedFormat(t *testing.T) {
+	if err := (Matrix{}).Encode(&bytes.Buffer{}, EncodeOptions{Format: "parquet"}); err == nil {
+		t.Error("Encode() error = nil, want unsupported format")
+	}
+}
+
+func TestDecodeColumnar(t *testing.T) {
+	m := Matrix{
+		series(Labels{"__name__": "up", "job": "api"}, 1000, 1000, 1, math.Inf(-1), 3),
+		series(Labels{"__name__": "up", "job": "db"}, 2000, 1000, 5),
+	}
+	var b bytes.Buffer
+	if err := m.Encode(&b, EncodeOptions{Format: FormatColumnar}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	got, err := DecodeColumnar(&b)
+	if err != nil {
+		t.Fatalf("DecodeColumnar() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("DecodeColumnar() = %v, want %v", got, m)
+	}
+
+	_, err = DecodeColumnar(strings.NewReader(`{"labels":["job"],"series":[["a"]],"timestamps":[1],"values":[[1,2]]}`))
+	if err == nil {
+		t.Error("DecodeColumnar() error = nil, want mismatched values")
+	}
+}
@@ -50,6 +50,59 @@ func ExamplePrometheus_Push() {
 	}
 }
 
+// ExamplePrometheus_Push_histogram demonstrates how to
+// push a histogram using Prometheus.Push.
+func ExamplePrometheus_Push_histogram() {
+	// prometheus push histogram example
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	p := devops.NewDevops().Prometheus()
+	pm := prometheus.PushMetrics{
+		Name:        "backup_duration_seconds",
+		Label:       []string{"db"},
+		Type:        prometheus.Histogram,
+		Help:        "Duration of the nightly backups.",
+		ConstLabels: map[string]string{"team": "ops"},
+		Buckets:     []float64{60, 300, 900},
+		Metrics: []prometheus.PromMetrics{
+			{
+				Values:       []string{"users"},
+				Observations: []float64{42, 318},
+			},
+		},
+	}
+	if err := p.Push(ctx, pm, "localhost:9091"); err != nil {
+		log.Errorf("push metrics error, err:  %v", err)
+	}
+}
+
+// ExamplePushgateway_PushAdd demonstrates how to push metrics
+// to a grouping of an authenticated Pushgateway.
+func ExamplePushgateway_PushAdd() {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	g := &prometheus.Pushgateway{
+		URL:         "localhost:9091",
+		Job:         "backup",
+		Grouping:    map[string]string{"instance": "db1"},
+		BearerToken: os.Getenv("PUSHGATEWAY_TOKEN"),
+	}
+	pm := prometheus.PushMetrics{
+		Name:    "backup_last_success_timestamp_seconds",
+		Help:    "Time of the last successful backup.",
+		Metrics: []prometheus.PromMetrics{{Data: float64(time.Now().Unix())}},
+	}
+	if err := g.PushAdd(ctx, pm); err != nil {
+		log.Errorf("push metrics error, err:  %v", err)
+	}
+	// delete the group once the instance is decommissioned
+	if err := g.Delete(ctx); err != nil {
+		log.Errorf("delete metrics error, err:  %v", err)
+	}
+}
+
 // ExamplePrometheus_Query_vector demonstrates how to
 // query vector data using Prometheus.Query.
 // Open the link to see an example: https://go.dev/play/p/cbqzfN1JDOE
@@ -167,3 +220,141 @@ func ExamplePrometheus_QueryRange() {
 	fmt.Println(result)
 
 }
+
+// ExamplePrometheus_Targets demonstrates how to list
+// unhealthy scrape targets using Prometheus.Targets.
+func ExamplePrometheus_Targets() {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	client, err := api.NewClient(api.Config{
+		Address: "http://localhost:9090",
+	})
+	if err != nil {
+		log.Errorf("Error creating client: %v\n", err)
+		return
+	}
+	targets, err := devops.NewDevops().Prometheus().Targets(ctx, client)
+	if err != nil {
+		log.Errorf("Error querying Prometheus: %v\n", err)
+		return
+	}
+	for _, t := range targets.Active {
+		if t.Health != "up" {
+			fmt.Println(t.ScrapeURL, t.LastError)
+		}
+	}
+}
+
+// ExampleRemoteWriter_Write demonstrates how to backfill
+// samples to Mimir using RemoteWriter.Write.
+func ExampleRemoteWriter_Write() {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	w := &prometheus.RemoteWriter{
+		URL:     "http://localhost:9009/api/v1/push",
+		Headers: map[string]string{"X-Scope-OrgID": "ops"},
+	}
+	now := time.Now()
+	s := prometheus.Series{
+		Labels: prometheus.Labels{"__name__": "backup_size_bytes", "db": "users"},
+		Samples: []prometheus.MetricValues{
+			{Timestamp: now.Add(-time.Hour).UnixMilli(), Value: 1 << 30},
+			{Timestamp: now.UnixMilli(), Value: 1 << 31},
+		},
+	}
+	if err := w.Write(ctx, s); err != nil {
+		log.Errorf("remote write error, err:  %v", err)
+	}
+}
+
+// ExampleRemoteReader_Read demonstrates how to export
+// the raw samples of a day using RemoteReader.Read.
+func ExampleRemoteReader_Read() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	r := &prometheus.RemoteReader{URL: "http://localhost:9090/api/v1/read"}
+	end := time.Now()
+	matrix, err := r.Read(ctx, end.Add(-24*time.Hour), end,
+		prometheus.Matcher{Name: "__name__", Value: "node_filesystem_avail_bytes"},
+		prometheus.Matcher{Type: prometheus.MatchNotEqual, Name: "fstype", Value: "tmpfs"},
+	)
+	if err != nil {
+		log.Errorf("remote read error, err:  %v", err)
+		return
+	}
+	for _, s := range matrix {
+		fmt.Println(s.Metric, len(s.Values))
+	}
+}
+
+// ExampleRangePlanner_QueryRange demonstrates how to query 90 days
+// at a 1 minute step, beyond the 11,000 points of a range query.
+func ExampleRangePlanner_QueryRange() {
+	client, err := api.NewClient(api.Config{Address: "http://localhost:9090"})
+	if err != nil {
+		log.Errorf("new client error, err:  %v", err)
+		return
+	}
+	p := &prometheus.RangePlanner{Client: client, Cache: prometheus.NewMemoryCache(1000)}
+	end := time.Now()
+	res, err := p.QueryRange(context.Background(), "sum(rate(http_requests_total[5m]))", v1.Range{
+		Start: end.Add(-90 * 24 * time.Hour),
+		End:   end,
+		Step:  time.Minute,
+	})
+	if err != nil {
+		log.Errorf("range query error, err:  %v", err)
+		return
+	}
+	for _, s := range res.Values.(prometheus.Matrix) {
+		fmt.Println(s.Metric, len(s.Values))
+	}
+}
+
+// ExampleFederation demonstrates how to query one Prometheus
+// by region through MetricsInterface.
+func ExampleFederation() {
+	clients := make(map[string]api.Client)
+	for region, addr := range map[string]string{"eu": "http://prometheus-eu:9090", "us": "http://prometheus-us:9090"} {
+		client, err := api.NewClient(api.Config{Address: addr})
+		if err != nil {
+			log.Errorf("new client error, err:  %v", err)
+			return
+		}
+		clients[region] = client
+	}
+	var m prometheus.MetricsInterface = &prometheus.Federation{Clients: clients, SourceLabel: "region"}
+	res, err := m.QueryWithOptions(context.Background(), nil, "sum(up)", time.Now(), prometheus.QueryOptions{})
+	if err != nil {
+		log.Errorf("query error, err:  %v", err)
+		return
+	}
+	for _, w := range res.Warnings {
+		log.Warnf("query warning: %s", w)
+	}
+	for _, s := range res.Values.(prometheus.Vector) {
+		fmt.Println(s.Label("region"), s.Values.Value)
+	}
+}
+
+// ExampleBinary demonstrates how to build the error ratio
+// of a user selected job with the PromQL builder.
+func ExampleBinary() {
+	job := `api"} or vector(1)` // user input is escaped
+	errors := prometheus.Sum(prometheus.Rate(prometheus.Metric("http_requests_total", prometheus.Eq("job", job), prometheus.Re("code", "5..")).Range(5 * time.Minute))).By("job")
+	total := prometheus.Sum(prometheus.Rate(prometheus.Metric("http_requests_total", prometheus.Eq("job", job)).Range(5 * time.Minute))).By("job")
+	fmt.Println(prometheus.Binary(errors, prometheus.OpDiv, total))
+	// Output: sum by (job) (rate(http_requests_total{job="api\"} or vector(1)", code=~"5.."}[5m])) / sum by (job) (rate(http_requests_total{job="api\"} or vector(1)"}[5m]))
+}
+
+func ExampleValidateQuery() {
+	findings, err := prometheus.ValidateQuery(`sum(rate(node_load1[5m])) / rate(http_requests_total)`, prometheus.LintOptions{})
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	fmt.Println(err != nil)
+	// Output:
+	// warning: rate-on-gauge: rate() of node_load1, which does not look like a counter
+	// error: missing-range: rate() expects a range vector, add a range such as http_requests_total[5m]
+	// true
+}
@@ -0,0 +1,313 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/prometheus/common/model"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricFamily is a metric family of an exposition.
+type MetricFamily struct {
+	Name string
+	// Type is counter, gauge, histogram, summary or untyped, or else
+	// unknown, gaugehistogram, info or stateset in OpenMetrics.
+	Type string
+	Help string
+	Unit string // OpenMetrics only
+	// Samples are the samples of the family, such as the _bucket,
+	// _sum and _count samples of histograms, named by __name__.
+	Samples Vector
+}
+
+// suffixes are the suffixes of the samples of the types of families.
+var suffixes = map[string][]string{
+	"counter":        {"_total", "_created"},
+	"histogram":      {"_bucket", "_sum", "_count", "_created"},
+	"gaugehistogram": {"_gbucket", "_gsum", "_gcount"},
+	"summary":        {"_sum", "_count", "_created"},
+	"info":           {"_info"},
+}
+
+// owns reports whether the sample name belongs to the family.
+func (f *MetricFamily) owns(name string) bool {
+	if name == f.Name {
+		return true
+	}
+	for _, suffix := range suffixes[f.Type] {
+		if name == f.Name+suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMetrics parses metric families in the Prometheus text format,
+// or OpenMetrics if data ends with # EOF. Samples without timestamp
+// get ts. Exemplars are ignored.
+func ParseMetrics(data []byte, ts time.Time) ([]MetricFamily, error) {
+	data = bytes.TrimRight(data, "\n")
+	openMetrics := bytes.HasSuffix(data, []byte("\n# EOF")) || bytes.Equal(data, []byte("# EOF"))
+	if openMetrics {
+		data = data[:len(data)-len("# EOF")]
+	}
+	untyped := "untyped"
+	if openMetrics {
+		untyped = "unknown"
+	}
+	defaultTs := ts.UnixNano() / int64(time.Millisecond)
+
+	var (
+		families []MetricFamily
+		seen     = map[string]bool{}
+	)
+	// family returns the current family if it is name, or a new one.
+	family := func(name string) (*MetricFamily, error) {
+		if n := len(families); n > 0 && families[n-1].Name == name {
+			return &families[n-1], nil
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("metric family %q is not contiguous", name)
+		}
+		seen[name] = true
+		families = append(families, MetricFamily{Name: name, Type: untyped})
+		return &families[len(families)-1], nil
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("line %d: %s", i+1, fmt.Sprintf(format, args...))
+		}
+
+		if strings.HasPrefix(line, "#") {
+			parts := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+			if len(parts) < 2 {
+				if openMetrics && parts[0] == "EOF" {
+					return nil, fail("# EOF before end of data")
+				}
+				continue
+			}
+			keyword, name, rest := parts[0], parts[1], ""
+			if len(parts) == 3 {
+				rest = parts[2]
+			}
+			if keyword != "HELP" && keyword != "TYPE" && keyword != "UNIT" {
+				continue
+			}
+			if !model.IsValidMetricName(model.LabelValue(name)) {
+				return nil, fail("invalid metric name %q", name)
+			}
+			f, err := family(name)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			switch keyword {
+			case "HELP":
+				f.Help = unescape(rest, openMetrics)
+			case "TYPE":
+				if len(f.Samples) > 0 {
+					return nil, fail("TYPE of %q after its samples", name)
+				}
+				switch rest {
+				case "counter", "gauge", "histogram", "summary", untyped:
+				case "gaugehistogram", "info", "stateset":
+					if !openMetrics {
+						return nil, fail("invalid type %q", rest)
+					}
+				default:
+					return nil, fail("invalid type %q", rest)
+				}
+				f.Type = rest
+			case "UNIT":
+				f.Unit = rest
+			}
+			continue
+		}
+
+		labels, value, sampleTs, err := parseSample(line, openMetrics)
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		if sampleTs == nil {
+			sampleTs = &defaultTs
+		}
+		name := labels.Name()
+		f := &MetricFamily{}
+		if n := len(families); n > 0 {
+			f = &families[n-1]
+		}
+		if !f.owns(name) {
+			if f, err = family(name); err != nil {
+				return nil, fail("%v", err)
+			}
+		}
+		f.Samples = append(f.Samples, VectorResult{
+			Metric: labels.String(),
+			Labels: labels,
+			Values: MetricValues{Timestamp: *sampleTs, Value: value},
+		})
+	}
+	return families, nil
+}
+
+// parseSample parses a sample line: the metric name, optional labels,
+// the value, an optional timestamp and, in OpenMetrics, an optional
+// exemplar. Timestamps are in milliseconds, or seconds in OpenMetrics.
+func parseSample(line string, openMetrics bool) (Labels, float64, *int64, error) {
+	i := 0
+	for i < len(line) && isNameChar(line[i], i == 0, true) {
+		i++
+	}
+	if i == 0 {
+		return nil, 0, nil, fmt.Errorf("invalid metric name in %q", line)
+	}
+	labels := Labels{model.MetricNameLabel: line[:i]}
+
+	if i < len(line) && line[i] == '{' {
+		i++
+		for {
+			for i < len(line) && line[i] == ' ' {
+				i++
+			}
+			if i < len(line) && line[i] == '}' {
+				i++
+				break
+			}
+			start := i
+			for i < len(line) && isNameChar(line[i], i == start, false) {
+				i++
+			}
+			name := line[start:i]
+			for i < len(line) && line[i] == ' ' {
+				i++
+			}
+			if name == "" || i >= len(line) || line[i] != '=' {
+				return nil, 0, nil, fmt.Errorf("invalid label at %q", line[start:])
+			}
+			for i++; i < len(line) && line[i] == ' '; i++ {
+			}
+			if i >= len(line) || line[i] != '"' {
+				return nil, 0, nil, fmt.Errorf("invalid label at %q", line[start:])
+			}
+			i++
+			var value strings.Builder
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						value.WriteByte('\n')
+					case '\\', '"':
+						value.WriteByte(line[i])
+					default:
+						return nil, 0, nil, fmt.Errorf("invalid escape \\%c in label %q", line[i], name)
+					}
+					continue
+				}
+				value.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, 0, nil, fmt.Errorf("unterminated value of label %q", name)
+			}
+			if _, ok := labels[name]; ok {
+				return nil, 0, nil, fmt.Errorf("duplicate label %q", name)
+			}
+			labels[name] = value.String()
+			i++
+			for i < len(line) && line[i] == ' ' {
+				i++
+			}
+			if i < len(line) && line[i] == ',' {
+				i++
+			} else if i >= len(line) || line[i] != '}' {
+				return nil, 0, nil, fmt.Errorf("missing comma after label %q", name)
+			}
+		}
+	}
+
+	rest := line[i:]
+	if openMetrics {
+		if j := strings.Index(rest, " # "); j >= 0 {
+			rest = rest[:j] // exemplar
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, 0, nil, fmt.Errorf("invalid value and timestamp %q", rest)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("invalid value %q", fields[0])
+	}
+	if len(fields) == 1 {
+		return labels, value, nil, nil
+	}
+	var ts int64
+	if openMetrics {
+		s, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+		ts = int64(math.Round(s * 1000))
+	} else if ts, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return nil, 0, nil, fmt.Errorf("invalid timestamp %q", fields[1])
+	}
+	return labels, value, &ts, nil
+}
+
+// isNameChar reports whether c may be in a metric name, or
+// a label name, at the first position or else elsewhere.
+func isNameChar(c byte, first, metric bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' ||
+		metric && c == ':' || !first && c >= '0' && c <= '9'
+}
+
+// unescape unescapes \\ and \n, and \" in OpenMetrics, of a HELP text.
+func unescape(s string, openMetrics bool) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			case '"':
+				if openMetrics {
+					b.WriteByte('"')
+					i++
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
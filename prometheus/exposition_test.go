@@ -0,0 +1,168 @@
+package prometheus
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sample returns a sample of a family with the labels name=value pairs.
+func sample(ts int64, value float64, labels ...string) VectorResult {
+	l := Labels{}
+	for i := 0; i < len(labels); i += 2 {
+		l[labels[i]] = labels[i+1]
+	}
+	return VectorResult{Metric: l.String(), Labels: l, Values: MetricValues{Timestamp: ts, Value: value}}
+}
+
+func TestParseMetrics(t *testing.T) {
+	ts := time.Unix(10, 0)
+	tests := []struct {
+		name    string
+		data    string
+		want    []MetricFamily
+		wantErr string
+	}{
+		{
+			name: "text",
+			data: `# HELP http_requests_total Requests \\ handled.\n
+# TYPE http_requests_total counter
+http_requests_total{code="200",path="/a\"b"} 1027 1395066363000
+http_requests_total{ code = "500" , } 3
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.5"} 1
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 1.5
+latency_seconds_count 2
+temperature -Inf
+`,
+			want: []MetricFamily{
+				{Name: "http_requests_total", Type: "counter", Help: "Requests \\ handled.\n", Samples: Vector{
+					sample(1395066363000, 1027, "__name__", "http_requests_total", "code", "200", "path", `/a"b`),
+					sample(10000, 3, "__name__", "http_requests_total", "code", "500"),
+				}},
+				{Name: "latency_seconds", Type: "histogram", Samples: Vector{
+					sample(10000, 1, "__name__", "latency_seconds_bucket", "le", "0.5"),
+					sample(10000, 2, "__name__", "latency_seconds_bucket", "le", "+Inf"),
+					sample(10000, 1.5, "__name__", "latency_seconds_sum"),
+					sample(10000, 2, "__name__", "latency_seconds_count"),
+				}},
+				{Name: "temperature", Type: "untyped", Samples: Vector{sample(10000, math.Inf(-1), "__name__", "temperature")}},
+			},
+		},
+		{
+			name: "openmetrics",
+			data: `# TYPE backup_duration_seconds gauge
+# UNIT backup_duration_seconds seconds
+# HELP backup_duration_seconds Duration of \"backups\".
+backup_duration_seconds 42.5 1700000000.123
+# TYPE jobs counter
+jobs_total{job="backup"} 3 # {trace_id="abc"} 1 1700000000
+jobs_created{job="backup"} 1.6e9
+build_info{version="1.0"} 1
+# EOF
+`,
+			want: []MetricFamily{
+				{Name: "backup_duration_seconds", Type: "gauge", Unit: "seconds", Help: `Duration of "backups".`, Samples: Vector{
+					sample(1700000000123, 42.5, "__name__", "backup_duration_seconds"),
+				}},
+				{Name: "jobs", Type: "counter", Samples: Vector{
+					sample(10000, 3, "__name__", "jobs_total", "job", "backup"),
+					sample(10000, 1.6e9, "__name__", "jobs_created", "job", "backup"),
+				}},
+				{Name: "build_info", Type: "unknown", Samples: Vector{
+					sample(10000, 1, "__name__", "build_info", "version", "1.0"),
+				}},
+			},
+		},
+		{name: "invalid value", data: "up 1\nup{job=\"a\"} one\n", wantErr: `line 2: invalid value "one"`},
+		{name: "unterminated label", data: "up{job=\"a} 1\n", wantErr: `line 1: unterminated value of label "job"`},
+		{name: "missing comma", data: "up{a=\"1\" b=\"2\"} 1\n", wantErr: `line 1: missing comma after label "a"`},
+		{name: "invalid type", data: "# TYPE up info\n", wantErr: `line 1: invalid type "info"`},
+		{name: "not contiguous", data: "a 1\nb 1\na 2\n", wantErr: `line 3: metric family "a" is not contiguous`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMetrics([]byte(tt.data), ts)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseMetrics() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMetrics() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMetrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.prom")
+	metrics := []PushMetrics{
+		{Name: "backup_success", Label: []string{"db"}, Help: "Backup succeeded.", Metrics: []PromMetrics{{Values: []string{"users"}, Data: 1}}},
+		{Name: "backup_runs_total", Type: Counter, Metrics: []PromMetrics{{Data: 3}}},
+	}
+	if err := WriteTextfile(path, metrics...); err != nil {
+		t.Fatalf("WriteTextfile() error = %v", err)
+	}
+	if err := WriteTextfile(path, PushMetrics{Name: "backup-success"}); err == nil {
+		t.Errorf("WriteTextfile(invalid) error = nil")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("WriteTextfile() left %d files", len(entries))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	families, err := ParseMetrics(data, time.Unix(0, 0))
+	if err != nil {
+		t.Fatalf("ParseMetrics() error = %v", err)
+	}
+	want := []MetricFamily{
+		{Name: "backup_runs_total", Type: "counter", Help: "The jobs of backup_runs_total in dynatrace.", Samples: Vector{sample(0, 3, "__name__", "backup_runs_total")}},
+		{Name: "backup_success", Type: "gauge", Help: "Backup succeeded.", Samples: Vector{sample(0, 1, "__name__", "backup_success", "db", "users")}},
+	}
+	if !reflect.DeepEqual(families, want) {
+		t.Errorf("WriteTextfile() wrote %s", data)
+	}
+}
+
+func TestScraper_Scrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Accept"), "application/openmetrics-text") || r.Header.Get("Authorization") != "Bearer t0ken" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		if r.URL.Path != "/metrics" {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("# TYPE up gauge\nup 1\n# EOF\n"))
+	}))
+	defer srv.Close()
+
+	s := &Scraper{Headers: map[string]string{"Authorization": "Bearer t0ken"}}
+	families, err := s.Scrape(context.Background(), srv.URL+"/metrics")
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	if len(families) != 1 || families[0].Type != "gauge" || len(families[0].Samples) != 1 || families[0].Samples[0].Values.Value != 1 {
+		t.Errorf("Scrape() = %+v", families)
+	}
+	if _, err := s.Scrape(context.Background(), srv.URL+"/broken"); err == nil || !strings.Contains(err.Error(), "500 Internal Server Error") {
+		t.Errorf("Scrape(broken) error = %v, want the status", err)
+	}
+}
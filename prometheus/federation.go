@@ -0,0 +1,528 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSourceLabel is the label of the source of federated series.
+const DefaultSourceLabel = "source"
+
+// Federation fans queries out to several Prometheus servers, such as
+// one by region, and merges their results. It implements MetricsInterface,
+// ignoring the clients given to its methods: queries go to all Clients.
+//
+// The queries fail only if all Clients fail. The errors of the others are
+// warnings which, like the warnings of the sources, are prefixed by their
+// source and returned in the Warnings of QueryResult, or else printed
+// once as the warnings of Prometheus.
+type Federation struct {
+	Clients map[string]api.Client // by source name
+	// SourceLabel is set to the name of the source of every series, target
+	// and alert, replacing their label of the same name. Defaults to
+	// DefaultSourceLabel.
+	SourceLabel string
+}
+
+// sourceResult is the result of a source.
+type sourceResult struct {
+	name  string
+	value interface{}
+}
+
+func (f *Federation) sourceLabel() string {
+	if f.SourceLabel == "" {
+		return DefaultSourceLabel
+	}
+	return f.SourceLabel
+}
+
+// tag returns labels with the source label set to source.
+func (f *Federation) tag(labels Labels, source string) Labels {
+	l := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		l[k] = v
+	}
+	l[f.sourceLabel()] = source
+	return l
+}
+
+// sourceFunc returns the value of a source and its warnings.
+type sourceFunc func(ctx context.Context, client api.Client) (interface{}, []string, error)
+
+// withoutWarnings adapts fn returning no warnings to a sourceFunc.
+func withoutWarnings(fn func(ctx context.Context, client api.Client) (interface{}, error)) sourceFunc {
+	return func(ctx context.Context, client api.Client) (interface{}, []string, error) {
+		v, err := fn(ctx, client)
+		return v, nil, err
+	}
+}
+
+// fanOut calls fn with every client concurrently. It returns the results
+// of the sources that succeeded in the order of their names, and their
+// warnings followed by the errors of the others, prefixed by their
+// source, or the first error if all failed.
+func (f *Federation) fanOut(ctx context.Context, fn sourceFunc) ([]sourceResult, []string, error) {
+	if len(f.Clients) == 0 {
+		return nil, nil, errors.New("federation has no clients")
+	}
+	names := make([]string, 0, len(f.Clients))
+	for name := range f.Clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]interface{}, len(names))
+	sourceWarnings := make([][]string, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, client api.Client) {
+			defer wg.Done()
+			values[i], sourceWarnings[i], errs[i] = fn(ctx, client)
+		}(i, f.Clients[name])
+	}
+	wg.Wait()
+
+	var (
+		results            []sourceResult
+		warnings, failures []string
+	)
+	for i, name := range names {
+		if errs[i] != nil {
+			failures = append(failures, fmt.Sprintf("source %q: %v", name, errs[i]))
+			continue
+		}
+		for _, w := range sourceWarnings[i] {
+			warnings = append(warnings, fmt.Sprintf("source %q: %s", name, w))
+		}
+		results = append(results, sourceResult{name: name, value: values[i]})
+	}
+	if len(results) == 0 {
+		return nil, nil, fmt.Errorf("source %q: %w", names[0], errs[0])
+	}
+	return results, append(warnings, failures...), nil
+}
+
+// collect is fanOut printing the warnings.
+func (f *Federation) collect(ctx context.Context, fn sourceFunc) ([]sourceResult, error) {
+	results, warnings, err := f.fanOut(ctx, fn)
+	if len(warnings) > 0 {
+		fmt.Printf("Warnings: %v\n", warnings)
+	}
+	return results, err
+}
+
+// Push implements prometheus push metrics, see Prometheus.Push.
+func (f *Federation) Push(ctx context.Context, pm PushMetrics, addr string) error {
+	return (&Prometheus{}).Push(ctx, pm, addr)
+}
+
+// PushBatch implements prometheus push metrics, see Prometheus.PushBatch.
+func (f *Federation) PushBatch(ctx context.Context, metrics []PushMetrics, addr string) error {
+	return (&Prometheus{}).PushBatch(ctx, metrics, addr)
+}
+
+// Query implements federated instant query at endTime in milliseconds.
+func (f *Federation) Query(ctx context.Context, _ api.Client, query string, endTime int64) (ResultValues, error) {
+	end := time.Unix(0, endTime*int64(time.Millisecond)).UTC()
+	res, err := f.QueryWithOptions(ctx, nil, query, end, QueryOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Warnings) > 0 {
+		fmt.Printf("Warnings: %v\n", res.Warnings)
+	}
+	return res.Values, nil
+}
+
+// QueryRange implements federated range query.
+func (f *Federation) QueryRange(ctx context.Context, _ api.Client, query string, r v1.Range, opts ...v1.Option) (ResultValues, error) {
+	res, err := f.query(ctx, func(ctx context.Context, client api.Client) (*QueryResult, error) {
+		value, warnings, err := v1.NewAPI(client).QueryRange(ctx, query, r, opts...)
+		if err != nil {
+			return nil, err
+		}
+		values, err := convertValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return &QueryResult{Values: values, Warnings: warnings}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Warnings) > 0 {
+		fmt.Printf("Warnings: %v\n", res.Warnings)
+	}
+	return res.Values, nil
+}
+
+// QueryWithOptions implements federated instant query, see
+// Prometheus.QueryWithOptions. Warnings are prefixed by their source.
+func (f *Federation) QueryWithOptions(ctx context.Context, _ api.Client, query string, ts time.Time, opts QueryOptions) (*QueryResult, error) {
+	return f.query(ctx, func(ctx context.Context, client api.Client) (*QueryResult, error) {
+		return (&Prometheus{}).QueryWithOptions(ctx, client, query, ts, opts)
+	})
+}
+
+// QueryRangeWithOptions implements federated range query, see
+// Prometheus.QueryRangeWithOptions. Warnings are prefixed by their source.
+func (f *Federation) QueryRangeWithOptions(ctx context.Context, _ api.Client, query string, r v1.Range, opts QueryOptions) (*QueryResult, error) {
+	return f.query(ctx, func(ctx context.Context, client api.Client) (*QueryResult, error) {
+		return (&Prometheus{}).QueryRangeWithOptions(ctx, client, query, r, opts)
+	})
+}
+
+func (f *Federation) query(ctx context.Context, fn func(ctx context.Context, client api.Client) (*QueryResult, error)) (*QueryResult, error) {
+	results, warnings, err := f.fanOut(ctx, func(ctx context.Context, client api.Client) (interface{}, []string, error) {
+		qr, err := fn(ctx, client)
+		if err != nil {
+			return nil, nil, err
+		}
+		return qr, qr.Warnings, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &QueryResult{}
+	values := make([]sourceResult, 0, len(results))
+	for _, r := range results {
+		qr := r.value.(*QueryResult)
+		res.addStats(qr.Stats)
+		values = append(values, sourceResult{name: r.name, value: qr.Values})
+	}
+	res.Values, res.Warnings, err = f.merge(values, warnings...)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// merge merges the ResultValues of the sources. Series are tagged with
+// their source, series of matrices sorted by labels and samples of vectors
+// kept in the order of the sources. Scalars and strings are those of the
+// first source, with a warning if others differ.
+func (f *Federation) merge(results []sourceResult, warnings ...string) (ResultValues, []string, error) {
+	first := results[0].value
+	for _, r := range results[1:] {
+		if reflect.TypeOf(r.value) != reflect.TypeOf(first) {
+			return nil, warnings, fmt.Errorf("sources %q and %q returned %T and %T",
+				results[0].name, r.name, first, r.value)
+		}
+	}
+	switch first.(type) {
+	case Matrix:
+		matrices := make([]Matrix, 0, len(results))
+		for _, r := range results {
+			m := make(Matrix, 0, len(r.value.(Matrix)))
+			for _, s := range r.value.(Matrix) {
+				s.Labels = f.tag(s.Labels, r.name)
+				s.Metric = s.Labels.String()
+				m = append(m, s)
+			}
+			matrices = append(matrices, m)
+		}
+		return mergeMatrices(matrices...), warnings, nil
+	case Vector:
+		var res Vector
+		for _, r := range results {
+			for _, s := range r.value.(Vector) {
+				s.Labels = f.tag(s.Labels, r.name)
+				s.Metric = s.Labels.String()
+				res = append(res, s)
+			}
+		}
+		return res, warnings, nil
+	}
+	for _, r := range results[1:] {
+		if !reflect.DeepEqual(r.value, first) {
+			warnings = append(warnings, fmt.Sprintf("sources %q and %q returned different results, using %q",
+				results[0].name, r.name, results[0].name))
+			break
+		}
+	}
+	return first.(ResultValues), warnings, nil
+}
+
+// Series returns the labels of the series of all sources, tagged
+// with their source and sorted, see Prometheus.Series.
+func (f *Federation) Series(ctx context.Context, _ api.Client, matches []string, start, end time.Time) ([]Labels, error) {
+	results, err := f.collect(ctx, func(ctx context.Context, client api.Client) (interface{}, []string, error) {
+		sets, warnings, err := v1.NewAPI(client).Series(ctx, matches, start, endOrNow(end))
+		if err != nil {
+			return nil, nil, err
+		}
+		res := make([]Labels, 0, len(sets))
+		for _, set := range sets {
+			res = append(res, decodeLabelSet(set))
+		}
+		return res, warnings, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var res []Labels
+	for _, r := range results {
+		for _, l := range r.value.([]Labels) {
+			res = append(res, f.tag(l, r.name))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].String() < res[j].String() })
+	return res, nil
+}
+
+// union returns the sorted union of the string slices of the results.
+func union(results []sourceResult, more ...string) []string {
+	seen := make(map[string]bool)
+	res := []string{}
+	for _, r := range results {
+		for _, s := range append(r.value.([]string), more...) {
+			if !seen[s] {
+				seen[s] = true
+				res = append(res, s)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// LabelNames returns the sorted label names of all sources, and
+// the source label, see Prometheus.LabelNames.
+func (f *Federation) LabelNames(ctx context.Context, _ api.Client, matches []string, start, end time.Time) ([]string, error) {
+	results, err := f.collect(ctx, func(ctx context.Context, client api.Client) (interface{}, []string, error) {
+		names, warnings, err := v1.NewAPI(client).LabelNames(ctx, matches, start, endOrNow(end))
+		return names, warnings, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return union(results, f.sourceLabel()), nil
+}
+
+// LabelValues returns the sorted values of the label of all sources, or
+// the names of the sources for the source label, see Prometheus.LabelValues.
+func (f *Federation) LabelValues(ctx context.Context, _ api.Client, label string, matches []string, start, end time.Time) ([]string, error) {
+	if label == f.sourceLabel() {
+		names := make([]string, 0, len(f.Clients))
+		for name := range f.Clients {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+	results, err := f.collect(ctx, func(ctx context.Context, client api.Client) (interface{}, []string, error) {
+		values, warnings, err := v1.NewAPI(client).LabelValues(ctx, label, matches, start, endOrNow(end))
+		if err != nil {
+			return nil, nil, err
+		}
+		res := make([]string, 0, len(values))
+		for _, v := range values {
+			res = append(res, string(v))
+		}
+		return res, warnings, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return union(results), nil
+}
+
+// Targets returns the targets of all sources, with labels
+// tagged with their source, see Prometheus.Targets.
+func (f *Federation) Targets(ctx context.Context, _ api.Client) (Targets, error) {
+	results, err := f.collect(ctx, withoutWarnings(func(ctx context.Context, client api.Client) (interface{}, error) {
+		return (&Prometheus{}).Targets(ctx, client)
+	}))
+	if err != nil {
+		return Targets{}, err
+	}
+	var res Targets
+	for _, r := range results {
+		targets := r.value.(Targets)
+		for _, t := range targets.Active {
+			t.Labels = f.tag(t.Labels, r.name)
+			res.Active = append(res.Active, t)
+		}
+		for _, t := range targets.Dropped {
+			t.DiscoveredLabels = f.tag(t.DiscoveredLabels, r.name)
+			res.Dropped = append(res.Dropped, t)
+		}
+	}
+	return res, nil
+}
+
+// Rules returns the rule groups of all sources, with the labels
+// of their alerts tagged with their source, see Prometheus.Rules.
+func (f *Federation) Rules(ctx context.Context, _ api.Client) ([]RuleGroup, error) {
+	results, err := f.collect(ctx, withoutWarnings(func(ctx context.Context, client api.Client) (interface{}, error) {
+		return (&Prometheus{}).Rules(ctx, client)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	var res []RuleGroup
+	for _, r := range results {
+		for _, g := range r.value.([]RuleGroup) {
+			rules := make([]Rule, 0, len(g.Rules))
+			for _, rule := range g.Rules {
+				alerts := make([]Alert, 0, len(rule.Alerts))
+				for _, a := range rule.Alerts {
+					a.Labels = f.tag(a.Labels, r.name)
+					alerts = append(alerts, a)
+				}
+				if len(alerts) > 0 {
+					rule.Alerts = alerts
+				}
+				rules = append(rules, rule)
+			}
+			g.Rules = rules
+			res = append(res, g)
+		}
+	}
+	return res, nil
+}
+
+// Alerts returns the alerts of all sources, with labels
+// tagged with their source, see Prometheus.Alerts.
+func (f *Federation) Alerts(ctx context.Context, _ api.Client) ([]Alert, error) {
+	results, err := f.collect(ctx, withoutWarnings(func(ctx context.Context, client api.Client) (interface{}, error) {
+		return (&Prometheus{}).Alerts(ctx, client)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	var res []Alert
+	for _, r := range results {
+		for _, a := range r.value.([]Alert) {
+			a.Labels = f.tag(a.Labels, r.name)
+			res = append(res, a)
+		}
+	}
+	return res, nil
+}
+
+// Metadata returns the distinct metadata of the metrics of all sources,
+// see Prometheus.Metadata. The limit applies to each source.
+func (f *Federation) Metadata(ctx context.Context, _ api.Client, metric string, limit int) (map[string][]Metadata, error) {
+	results, err := f.collect(ctx, withoutWarnings(func(ctx context.Context, client api.Client) (interface{}, error) {
+		return (&Prometheus{}).Metadata(ctx, client, metric, limit)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]Metadata)
+	for _, r := range results {
+		for name, mds := range r.value.(map[string][]Metadata) {
+		next:
+			for _, md := range mds {
+				for _, seen := range res[name] {
+					if seen == md {
+						continue next
+					}
+				}
+				res[name] = append(res[name], md)
+			}
+		}
+	}
+	return res, nil
+}
+
+// TSDB returns the cardinality statistics of all sources summed up,
+// see Prometheus.TSDB. The top lists are summed by name and keep as many
+// entries as the longest list of the sources.
+func (f *Federation) TSDB(ctx context.Context, _ api.Client) (TSDBStats, error) {
+	results, err := f.collect(ctx, withoutWarnings(func(ctx context.Context, client api.Client) (interface{}, error) {
+		return (&Prometheus{}).TSDB(ctx, client)
+	}))
+	if err != nil {
+		return TSDBStats{}, err
+	}
+	var (
+		res   TSDBStats
+		lists [4][][]Stat
+	)
+	for i, r := range results {
+		s := r.value.(TSDBStats)
+		res.NumSeries += s.NumSeries
+		res.NumLabelPairs += s.NumLabelPairs
+		res.ChunkCount += s.ChunkCount
+		if i == 0 || s.MinTime < res.MinTime {
+			res.MinTime = s.MinTime
+		}
+		if i == 0 || s.MaxTime > res.MaxTime {
+			res.MaxTime = s.MaxTime
+		}
+		for j, l := range [4][]Stat{s.SeriesCountByMetricName, s.LabelValueCountByLabelName, s.MemoryInBytesByLabelName, s.SeriesCountByLabelValuePair} {
+			lists[j] = append(lists[j], l)
+		}
+	}
+	res.SeriesCountByMetricName = sumStats(lists[0])
+	res.LabelValueCountByLabelName = sumStats(lists[1])
+	res.MemoryInBytesByLabelName = sumStats(lists[2])
+	res.SeriesCountByLabelValuePair = sumStats(lists[3])
+	return res, nil
+}
+
+// sumStats sums the top lists by name, sorted by value in decreasing
+// order, keeping as many entries as the longest list.
+func sumStats(lists [][]Stat) []Stat {
+	var (
+		res   []Stat
+		index = make(map[string]int)
+		size  int
+	)
+	for _, l := range lists {
+		if len(l) > size {
+			size = len(l)
+		}
+		for _, s := range l {
+			i, ok := index[s.Name]
+			if !ok {
+				i = len(res)
+				index[s.Name] = i
+				res = append(res, Stat{Name: s.Name})
+			}
+			res[i].Value += s.Value
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Value > res[j].Value })
+	if len(res) > size {
+		res = res[:size]
+	}
+	return res
+}
+
+// BuildInfo returns the build information of the first source, in the
+// order of their names, that answers, see Prometheus.BuildInfo.
+func (f *Federation) BuildInfo(ctx context.Context, _ api.Client) (BuildInfo, error) {
+	results, err := f.collect(ctx, withoutWarnings(func(ctx context.Context, client api.Client) (interface{}, error) {
+		return (&Prometheus{}).BuildInfo(ctx, client)
+	}))
+	if err != nil {
+		return BuildInfo{}, err
+	}
+	return results[0].value.(BuildInfo), nil
+}
@@ -0,0 +1,210 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ MetricsInterface = (*Federation)(nil)
+
+// federationServer answers the APIs with responses by path, and
+// queries with the response of their query.
+func federationServer(t *testing.T, responses map[string]string) api.Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		data, ok := responses[r.URL.Path+"?"+r.Form.Get("query")]
+		if !ok {
+			data, ok = responses[r.URL.Path]
+		}
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"unavailable","error":"down"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success",` + data + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	client, err := api.NewClient(api.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestFederation_Query(t *testing.T) {
+	eu := federationServer(t, map[string]string{
+		"/api/v1/query?up":            `"warnings":["partial"],"data":{"resultType":"vector","result":[{"metric":{"job":"b","source":"x"},"value":[1,"1"]}]}`,
+		"/api/v1/query?time()":        `"data":{"resultType":"scalar","result":[1,"1"]}`,
+		"/api/v1/query_range?up":      `"data":{"resultType":"matrix","result":[{"metric":{"job":"b"},"values":[[1,"1"]]}]}`,
+		"/api/v1/query?count(up)":     `"data":{"resultType":"vector","result":[]}`,
+		"/api/v1/query?vector(1)[1m]": `"data":{"resultType":"matrix","result":[]}`,
+	})
+	us := federationServer(t, map[string]string{
+		"/api/v1/query?up":            `"data":{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1,"0"]}]}`,
+		"/api/v1/query?time()":        `"data":{"resultType":"scalar","result":[1,"2"]}`,
+		"/api/v1/query_range?up":      `"data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[1,"0"]]}]}`,
+		"/api/v1/query?count(up)":     `"data":{"resultType":"vector","result":[]}`,
+		"/api/v1/query?vector(1)[1m]": `"data":{"resultType":"vector","result":[]}`,
+	})
+	down := federationServer(t, nil)
+	f := &Federation{Clients: map[string]api.Client{"eu": eu, "us": us, "ap": down}, SourceLabel: "source"}
+	ctx := context.Background()
+
+	res, err := f.QueryWithOptions(ctx, nil, "up", time.Unix(1, 0), QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryWithOptions() error = %v", err)
+	}
+	b := Labels{"job": "b", "source": "eu"}
+	a := Labels{"job": "a", "source": "us"}
+	want := Vector{
+		{Metric: b.String(), Labels: b, Values: MetricValues{Timestamp: 1000, Value: 1}},
+		{Metric: a.String(), Labels: a, Values: MetricValues{Timestamp: 1000, Value: 0}},
+	}
+	if !reflect.DeepEqual(res.Values, want) {
+		t.Errorf("QueryWithOptions() = %v, want %v", res.Values, want)
+	}
+	if len(res.Warnings) != 2 || res.Warnings[0] != `source "eu": partial` || !strings.HasPrefix(res.Warnings[1], `source "ap": `) {
+		t.Errorf("QueryWithOptions() warnings = %q, want the warning of eu and the error of ap", res.Warnings)
+	}
+
+	res, err = f.QueryRangeWithOptions(ctx, nil, "up", v1.Range{Start: time.Unix(0, 0), End: time.Unix(1, 0), Step: time.Second}, QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryRangeWithOptions() error = %v", err)
+	}
+	wantMatrix := Matrix{series(a, 1000, 1000, 0), series(b, 1000, 1000, 1)}
+	if !reflect.DeepEqual(res.Values, wantMatrix) {
+		t.Errorf("QueryRangeWithOptions() = %v, want %v", res.Values, wantMatrix)
+	}
+	values, err := f.QueryRange(ctx, nil, "up", v1.Range{Start: time.Unix(0, 0), End: time.Unix(1, 0), Step: time.Second})
+	if err != nil {
+		t.Fatalf("QueryRange() error = %v", err)
+	}
+	if !reflect.DeepEqual(values, wantMatrix) {
+		t.Errorf("QueryRange() = %v, want %v", values, wantMatrix)
+	}
+
+	res, err = f.QueryWithOptions(ctx, nil, "time()", time.Unix(1, 0), QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryWithOptions() error = %v", err)
+	}
+	if s := (Scalar{Value: MetricValues{Timestamp: 1000, Value: 1}}); !reflect.DeepEqual(res.Values, s) {
+		t.Errorf("QueryWithOptions() = %v, want %v", res.Values, s)
+	}
+	if len(res.Warnings) != 2 || res.Warnings[1] != `sources "eu" and "us" returned different results, using "eu"` {
+		t.Errorf("QueryWithOptions() warnings = %q, want different scalars", res.Warnings)
+	}
+
+	if _, err = f.QueryWithOptions(ctx, nil, "vector(1)[1m]", time.Time{}, QueryOptions{}); err == nil {
+		t.Error("QueryWithOptions() error = nil, want mismatched types")
+	}
+
+	f.Clients = map[string]api.Client{"ap": down}
+	if _, err = f.Query(ctx, nil, "up", 1000); err == nil || !strings.Contains(err.Error(), `source "ap"`) {
+		t.Errorf("Query() error = %v, want the error of ap", err)
+	}
+}
+
+func TestFederation_fanOut(t *testing.T) {
+	eu, us, ap := federationServer(t, nil), federationServer(t, nil), federationServer(t, nil)
+	f := &Federation{Clients: map[string]api.Client{"eu": eu, "us": us, "ap": ap}}
+	results, warnings, err := f.fanOut(context.Background(), func(_ context.Context, client api.Client) (interface{}, []string, error) {
+		switch client {
+		case eu:
+			return 1, []string{"partial"}, nil
+		case us:
+			return 2, nil, nil
+		}
+		return nil, nil, errors.New("down")
+	})
+	if err != nil {
+		t.Fatalf("fanOut() error = %v", err)
+	}
+	if want := []sourceResult{{name: "eu", value: 1}, {name: "us", value: 2}}; !reflect.DeepEqual(results, want) {
+		t.Errorf("fanOut() = %v, want %v", results, want)
+	}
+	if want := []string{`source "eu": partial`, `source "ap": down`}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("fanOut() warnings = %q, want %q", warnings, want)
+	}
+}
+
+func TestFederation_metadata(t *testing.T) {
+	eu := federationServer(t, map[string]string{
+		"/api/v1/series":           `"data":[{"__name__":"up","job":"api"}]`,
+		"/api/v1/labels":           `"data":["__name__","job"]`,
+		"/api/v1/label/job/values": `"data":["api","db"]`,
+		"/api/v1/alerts":           `"data":{"alerts":[{"labels":{"alertname":"APIDown"},"annotations":{},"state":"firing","value":"0"}]}`,
+		"/api/v1/metadata":         `"data":{"up":[{"type":"gauge","help":"Target is up.","unit":""}]}`,
+		"/api/v1/status/tsdb":      `"data":{"headStats":{"numSeries":2,"minTime":1000,"maxTime":2000},"seriesCountByMetricName":[{"name":"up","value":2},{"name":"a","value":1}]}`,
+	})
+	us := federationServer(t, map[string]string{
+		"/api/v1/series":           `"data":[{"__name__":"up","job":"api"}]`,
+		"/api/v1/labels":           `"data":["__name__","instance"]`,
+		"/api/v1/label/job/values": `"data":["web"]`,
+		"/api/v1/alerts":           `"data":{"alerts":[]}`,
+		"/api/v1/metadata":         `"data":{"up":[{"type":"gauge","help":"Target is up.","unit":""}]}`,
+		"/api/v1/status/tsdb":      `"data":{"headStats":{"numSeries":3,"minTime":500,"maxTime":1500},"seriesCountByMetricName":[{"name":"b","value":2},{"name":"up","value":1}]}`,
+	})
+	f := &Federation{Clients: map[string]api.Client{"eu": eu, "us": us}}
+	ctx := context.Background()
+
+	series, err := f.Series(ctx, nil, []string{"up"}, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Series() error = %v", err)
+	}
+	wantSeries := []Labels{{"__name__": "up", "job": "api", "source": "eu"}, {"__name__": "up", "job": "api", "source": "us"}}
+	if !reflect.DeepEqual(series, wantSeries) {
+		t.Errorf("Series() = %v, want %v", series, wantSeries)
+	}
+
+	names, err := f.LabelNames(ctx, nil, nil, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("LabelNames() error = %v", err)
+	}
+	if want := []string{"__name__", "instance", "job", "source"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("LabelNames() = %v, want %v", names, want)
+	}
+	values, err := f.LabelValues(ctx, nil, "job", nil, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("LabelValues() error = %v", err)
+	}
+	if want := []string{"api", "db", "web"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("LabelValues() = %v, want %v", values, want)
+	}
+	if values, _ = f.LabelValues(ctx, nil, "source", nil, time.Time{}, time.Time{}); !reflect.DeepEqual(values, []string{"eu", "us"}) {
+		t.Errorf("LabelValues() = %v, want the sources", values)
+	}
+
+	alerts, err := f.Alerts(ctx, nil)
+	if err != nil {
+		t.Fatalf("Alerts() error = %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Labels.Get("source") != "eu" {
+		t.Errorf("Alerts() = %v, want the alert of eu", alerts)
+	}
+
+	metadata, err := f.Metadata(ctx, nil, "", 0)
+	if err != nil {
+		t.Fatalf("Metadata() error = %v", err)
+	}
+	if want := map[string][]Metadata{"up": {{Type: "gauge", Help: "Target is up."}}}; !reflect.DeepEqual(metadata, want) {
+		t.Errorf("Metadata() = %v, want %v", metadata, want)
+	}
+
+	tsdb, err := f.TSDB(ctx, nil)
+	if err != nil {
+		t.Fatalf("TSDB() error = %v", err)
+	}
+	wantTSDB := TSDBStats{NumSeries: 5, MinTime: 500, MaxTime: 2000, SeriesCountByMetricName: []Stat{{Name: "up", Value: 3}, {Name: "b", Value: 2}}}
+	if !reflect.DeepEqual(tsdb, wantTSDB) {
+		t.Errorf("TSDB() = %+v, want %+v", tsdb, wantTSDB)
+	}
+}
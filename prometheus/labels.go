@@ -0,0 +1,150 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/common/model"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Labels are the labels of a series, including __name__.
+type Labels map[string]string
+
+// decodeLabels implements new Labels from a model.Metric.
+func decodeLabels(metric model.Metric) Labels {
+	l := make(Labels, len(metric))
+	for k, v := range metric {
+		l[string(k)] = string(v)
+	}
+	return l
+}
+
+// Get returns the value of the label name, or "" if it is not set.
+func (l Labels) Get(name string) string {
+	return l[name]
+}
+
+// Name returns the metric name of the series.
+func (l Labels) Name() string {
+	return l[model.MetricNameLabel]
+}
+
+// List returns the labels sorted by name.
+func (l Labels) List() []MetricLabel {
+	list := make([]MetricLabel, 0, len(l))
+	for k, v := range l {
+		list = append(list, MetricLabel{Label: k, Value: v})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	return list
+}
+
+// String returns the labels the way Prometheus prints a
+// series, as in up{instance="localhost:9090", job="prometheus"}.
+func (l Labels) String() string {
+	metric := make(model.Metric, len(l))
+	for k, v := range l {
+		metric[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return metric.String()
+}
+
+var legendRe = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
+
+// Legend renders a legend format like Grafana does, replacing
+// {{label}} with the value of the label, or "" if it is not set.
+// An empty format renders the labels with String.
+func (l Labels) Legend(format string) string {
+	if format == "" {
+		return l.String()
+	}
+	return legendRe.ReplaceAllStringFunc(format, func(s string) string {
+		return l[legendRe.FindStringSubmatch(s)[1]]
+	})
+}
+
+// only returns the labels with the given names, and
+// a key identifying their values.
+func (l Labels) only(names []string) (Labels, string) {
+	group := make(Labels, len(names))
+	var key strings.Builder
+	for _, name := range names {
+		if v, ok := l[name]; ok {
+			group[name] = v
+		}
+		key.WriteString(l[name])
+		key.WriteByte(0xff)
+	}
+	return group, key.String()
+}
+
+// MatrixGroup is a group of series with the same values of Labels.
+type MatrixGroup struct {
+	Labels Labels
+	Series Matrix
+}
+
+// VectorGroup is a group of samples with the same values of Labels.
+type VectorGroup struct {
+	Labels Labels
+	Series Vector
+}
+
+// Label returns the value of the label name of the series.
+func (r MatrixResult) Label(name string) string {
+	return r.Labels.Get(name)
+}
+
+// Label returns the value of the label name of the sample.
+func (r VectorResult) Label(name string) string {
+	return r.Labels.Get(name)
+}
+
+// GroupBy groups the series by the values of the labels names,
+// in the order in which the groups first appear.
+func (m Matrix) GroupBy(names ...string) []MatrixGroup {
+	var groups []MatrixGroup
+	index := make(map[string]int)
+	for _, r := range m {
+		labels, key := r.Labels.only(names)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, MatrixGroup{Labels: labels})
+		}
+		groups[i].Series = append(groups[i].Series, r)
+	}
+	return groups
+}
+
+// GroupBy groups the samples by the values of the labels names,
+// in the order in which the groups first appear.
+func (v Vector) GroupBy(names ...string) []VectorGroup {
+	var groups []VectorGroup
+	index := make(map[string]int)
+	for _, r := range v {
+		labels, key := r.Labels.only(names)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, VectorGroup{Labels: labels})
+		}
+		groups[i].Series = append(groups[i].Series, r)
+	}
+	return groups
+}
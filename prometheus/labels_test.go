@@ -0,0 +1,61 @@
+package prometheus
+
+import (
+	"github.com/prometheus/common/model"
+	"reflect"
+	"testing"
+)
+
+func TestLabels_Legend(t *testing.T) {
+	l := Labels{"__name__": "up", "instance": "localhost:9090", "job": "prometheus"}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "{{instance}}", want: "localhost:9090"},
+		{format: "{{ job }} - {{instance}}", want: "prometheus - localhost:9090"},
+		{format: "{{missing}}/{{__name__}}", want: "/up"},
+		{format: "", want: `up{instance="localhost:9090", job="prometheus"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := l.Legend(tt.format); got != tt.want {
+				t.Errorf("Legend() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := l.List(); !reflect.DeepEqual(got, []MetricLabel{{"__name__", "up"}, {"instance", "localhost:9090"}, {"job", "prometheus"}}) {
+		t.Errorf("List() = %v", got)
+	}
+}
+
+func TestMatrix_GroupBy(t *testing.T) {
+	m := decodeMatrix(model.Matrix{
+		{Metric: model.Metric{"job": "api", "instance": "a"}},
+		{Metric: model.Metric{"job": "db", "instance": "b"}},
+		{Metric: model.Metric{"job": "api", "instance": "c"}},
+		{Metric: model.Metric{"instance": "d"}},
+	})
+	if m[0].Label("instance") != "a" || m[0].Metric != `{instance="a", job="api"}` {
+		t.Fatalf("decodeMatrix() = %+v", m[0])
+	}
+	groups := m.GroupBy("job")
+	var got []string
+	for _, g := range groups {
+		for _, r := range g.Series {
+			got = append(got, g.Labels.Get("job")+":"+r.Label("instance"))
+		}
+	}
+	want := []string{"api:a", "api:c", "db:b", ":d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupBy() = %v, want %v", got, want)
+	}
+	if len(groups[2].Labels) != 0 {
+		t.Errorf("GroupBy() labels = %v, want none", groups[2].Labels)
+	}
+
+	v := decodeVector(model.Vector{{Metric: model.Metric{"job": "api"}}, {Metric: model.Metric{"job": "api"}}})
+	if vg := v.GroupBy("job"); len(vg) != 1 || len(vg[0].Series) != 2 {
+		t.Errorf("Vector.GroupBy() = %+v", vg)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"strconv"
+	"time"
+)
+
+// Rule types.
+const (
+	AlertingRule  = "alerting"
+	RecordingRule = "recording"
+)
+
+// Target is a scrape target.
+type Target struct {
+	ScrapePool         string        `json:"scrapePool"`
+	ScrapeURL          string        `json:"scrapeUrl"`
+	GlobalURL          string        `json:"globalUrl"`
+	Labels             Labels        `json:"labels"`
+	DiscoveredLabels   Labels        `json:"discoveredLabels"`
+	Health             string        `json:"health"` // up, down or unknown
+	LastError          string        `json:"lastError"`
+	LastScrape         time.Time     `json:"lastScrape"`
+	LastScrapeDuration time.Duration `json:"lastScrapeDuration"`
+}
+
+// Targets are the active and dropped scrape targets.
+type Targets struct {
+	Active  []Target `json:"active"`
+	Dropped []Target `json:"dropped"` // only DiscoveredLabels are set
+}
+
+// RuleGroup is a group of rules evaluated together.
+type RuleGroup struct {
+	Name     string        `json:"name"`
+	File     string        `json:"file"`
+	Interval time.Duration `json:"interval"`
+	Rules    []Rule        `json:"rules"`
+}
+
+// Rule is an alerting or recording rule.
+type Rule struct {
+	Type           string        `json:"type"` // AlertingRule or RecordingRule
+	Name           string        `json:"name"`
+	Query          string        `json:"query"`
+	Labels         Labels        `json:"labels"`
+	Annotations    Labels        `json:"annotations,omitempty"` // alerting rules only
+	Duration       time.Duration `json:"duration,omitempty"`    // alerting rules only
+	State          string        `json:"state,omitempty"`       // alerting rules only
+	Alerts         []Alert       `json:"alerts,omitempty"`      // alerting rules only
+	Health         string        `json:"health"`
+	LastError      string        `json:"lastError,omitempty"`
+	EvaluationTime time.Duration `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+}
+
+// Alert is an active alert.
+type Alert struct {
+	Labels      Labels    `json:"labels"`
+	Annotations Labels    `json:"annotations"`
+	State       string    `json:"state"` // pending or firing
+	ActiveAt    time.Time `json:"activeAt"`
+	Value       string    `json:"value"`
+}
+
+// Metadata is the metadata of a metric.
+type Metadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// Stat is a TSDB statistic.
+type Stat struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// TSDBStats are the cardinality statistics of the TSDB.
+type TSDBStats struct {
+	NumSeries                   int    `json:"numSeries"`
+	NumLabelPairs               int    `json:"numLabelPairs"`
+	ChunkCount                  int    `json:"chunkCount"`
+	MinTime                     int64  `json:"minTime"` // milliseconds
+	MaxTime                     int64  `json:"maxTime"` // milliseconds
+	SeriesCountByMetricName     []Stat `json:"seriesCountByMetricName"`
+	LabelValueCountByLabelName  []Stat `json:"labelValueCountByLabelName"`
+	MemoryInBytesByLabelName    []Stat `json:"memoryInBytesByLabelName"`
+	SeriesCountByLabelValuePair []Stat `json:"seriesCountByLabelValuePair"`
+}
+
+// BuildInfo is the build information of the Prometheus server.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	BuildUser string `json:"buildUser"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Series returns the labels of the series matching any of matches
+// between start and end. A zero end means now.
+func (p *Prometheus) Series(ctx context.Context, client api.Client, matches []string, start, end time.Time) ([]Labels, error) {
+	sets, warnings, err := v1.NewAPI(client).Series(ctx, matches, start, endOrNow(end))
+	if err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		fmt.Printf("Warnings: %v\n", warnings)
+	}
+	res := make([]Labels, 0, len(sets))
+	for _, set := range sets {
+		res = append(res, decodeLabelSet(set))
+	}
+	return res, nil
+}
+
+// LabelNames returns the sorted label names of the series matching
+// any of matches, or of all series if there are none, between start
+// and end. A zero end means now.
+func (p *Prometheus) LabelNames(ctx context.Context, client api.Client, matches []string, start, end time.Time) ([]string, error) {
+	names, warnings, err := v1.NewAPI(client).LabelNames(ctx, matches, start, endOrNow(end))
+	if err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		fmt.Printf("Warnings: %v\n", warnings)
+	}
+	return names, nil
+}
+
+// LabelValues returns the values of the label of the series matching
+// any of matches, or of all series if there are none, between start
+// and end. A zero end means now.
+func (p *Prometheus) LabelValues(ctx context.Context, client api.Client, label string, matches []string, start, end time.Time) ([]string, error) {
+	values, warnings, err := v1.NewAPI(client).LabelValues(ctx, label, matches, start, endOrNow(end))
+	if err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		fmt.Printf("Warnings: %v\n", warnings)
+	}
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		res = append(res, string(v))
+	}
+	return res, nil
+}
+
+// Targets returns the active and dropped scrape targets.
+func (p *Prometheus) Targets(ctx context.Context, client api.Client) (Targets, error) {
+	v, err := v1.NewAPI(client).Targets(ctx)
+	if err != nil {
+		return Targets{}, err
+	}
+	var res Targets
+	for _, t := range v.Active {
+		res.Active = append(res.Active, Target{
+			ScrapePool:         t.ScrapePool,
+			ScrapeURL:          t.ScrapeURL,
+			GlobalURL:          t.GlobalURL,
+			Labels:             decodeLabelSet(t.Labels),
+			DiscoveredLabels:   Labels(t.DiscoveredLabels),
+			Health:             string(t.Health),
+			LastError:          t.LastError,
+			LastScrape:         t.LastScrape,
+			LastScrapeDuration: seconds(t.LastScrapeDuration),
+		})
+	}
+	for _, t := range v.Dropped {
+		res.Dropped = append(res.Dropped, Target{DiscoveredLabels: Labels(t.DiscoveredLabels)})
+	}
+	return res, nil
+}
+
+// Rules returns the groups of alerting and recording rules.
+func (p *Prometheus) Rules(ctx context.Context, client api.Client) ([]RuleGroup, error) {
+	v, err := v1.NewAPI(client).Rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]RuleGroup, 0, len(v.Groups))
+	for _, g := range v.Groups {
+		group := RuleGroup{Name: g.Name, File: g.File, Interval: seconds(g.Interval)}
+		for _, r := range g.Rules {
+			switch r := r.(type) {
+			case v1.AlertingRule:
+				rule := Rule{
+					Type:           AlertingRule,
+					Name:           r.Name,
+					Query:          r.Query,
+					Labels:         decodeLabelSet(r.Labels),
+					Annotations:    decodeLabelSet(r.Annotations),
+					Duration:       seconds(r.Duration),
+					State:          r.State,
+					Health:         string(r.Health),
+					LastError:      r.LastError,
+					EvaluationTime: seconds(r.EvaluationTime),
+					LastEvaluation: r.LastEvaluation,
+				}
+				for _, a := range r.Alerts {
+					rule.Alerts = append(rule.Alerts, decodeAlert(*a))
+				}
+				group.Rules = append(group.Rules, rule)
+			case v1.RecordingRule:
+				group.Rules = append(group.Rules, Rule{
+					Type:           RecordingRule,
+					Name:           r.Name,
+					Query:          r.Query,
+					Labels:         decodeLabelSet(r.Labels),
+					Health:         string(r.Health),
+					LastError:      r.LastError,
+					EvaluationTime: seconds(r.EvaluationTime),
+					LastEvaluation: r.LastEvaluation,
+				})
+			}
+		}
+		res = append(res, group)
+	}
+	return res, nil
+}
+
+// Alerts returns the active alerts.
+func (p *Prometheus) Alerts(ctx context.Context, client api.Client) ([]Alert, error) {
+	v, err := v1.NewAPI(client).Alerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Alert, 0, len(v.Alerts))
+	for _, a := range v.Alerts {
+		res = append(res, decodeAlert(a))
+	}
+	return res, nil
+}
+
+// Metadata returns the metadata of the metric, or of all metrics
+// if metric is empty, by metric name. A positive limit limits
+// the number of metrics returned.
+func (p *Prometheus) Metadata(ctx context.Context, client api.Client, metric string, limit int) (map[string][]Metadata, error) {
+	var l string
+	if limit > 0 {
+		l = strconv.Itoa(limit)
+	}
+	v, err := v1.NewAPI(client).Metadata(ctx, metric, l)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]Metadata, len(v))
+	for name, mds := range v {
+		for _, md := range mds {
+			res[name] = append(res[name], Metadata{Type: string(md.Type), Help: md.Help, Unit: md.Unit})
+		}
+	}
+	return res, nil
+}
+
+// TSDB returns the cardinality statistics of the TSDB.
+func (p *Prometheus) TSDB(ctx context.Context, client api.Client) (TSDBStats, error) {
+	v, err := v1.NewAPI(client).TSDB(ctx)
+	if err != nil {
+		return TSDBStats{}, err
+	}
+	return TSDBStats{
+		NumSeries:                   v.HeadStats.NumSeries,
+		NumLabelPairs:               v.HeadStats.NumLabelPairs,
+		ChunkCount:                  v.HeadStats.ChunkCount,
+		MinTime:                     int64(v.HeadStats.MinTime),
+		MaxTime:                     int64(v.HeadStats.MaxTime),
+		SeriesCountByMetricName:     decodeStats(v.SeriesCountByMetricName),
+		LabelValueCountByLabelName:  decodeStats(v.LabelValueCountByLabelName),
+		MemoryInBytesByLabelName:    decodeStats(v.MemoryInBytesByLabelName),
+		SeriesCountByLabelValuePair: decodeStats(v.SeriesCountByLabelValuePair),
+	}, nil
+}
+
+// BuildInfo returns the build information of the Prometheus server.
+func (p *Prometheus) BuildInfo(ctx context.Context, client api.Client) (BuildInfo, error) {
+	v, err := v1.NewAPI(client).Buildinfo(ctx)
+	if err != nil {
+		return BuildInfo{}, err
+	}
+	return BuildInfo(v), nil
+}
+
+func decodeLabelSet(set model.LabelSet) Labels {
+	return decodeLabels(model.Metric(set))
+}
+
+func decodeAlert(a v1.Alert) Alert {
+	return Alert{
+		Labels:      decodeLabelSet(a.Labels),
+		Annotations: decodeLabelSet(a.Annotations),
+		State:       string(a.State),
+		ActiveAt:    a.ActiveAt,
+		Value:       a.Value,
+	}
+}
+
+func decodeStats(stats []v1.Stat) []Stat {
+	res := make([]Stat, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, Stat(s))
+	}
+	return res
+}
+
+// seconds converts the seconds returned by the API to a time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func endOrNow(end time.Time) time.Time {
+	if end.IsZero() {
+		return time.Now()
+	}
+	return end
+}
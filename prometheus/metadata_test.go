@@ -0,0 +1,131 @@
+package prometheus
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPrometheus_metadata(t *testing.T) {
+	responses := map[string]string{
+		"/api/v1/series":           `[{"__name__":"up","job":"api"}]`,
+		"/api/v1/labels":           `["__name__","job"]`,
+		"/api/v1/label/job/values": `["api","db"]`,
+		"/api/v1/targets":          `{"activeTargets":[{"scrapePool":"api","scrapeUrl":"http://api:9100/metrics","labels":{"job":"api"},"health":"up","lastScrapeDuration":0.5}],"droppedTargets":[{"discoveredLabels":{"__address__":"db:9100"}}]}`,
+		"/api/v1/rules":            `{"groups":[{"name":"api","file":"api.yml","interval":30,"rules":[{"type":"alerting","name":"APIDown","query":"up == 0","duration":300,"labels":{"severity":"page"},"annotations":{},"alerts":[{"labels":{"job":"api"},"state":"firing","value":"0"}],"health":"ok","state":"firing"},{"type":"recording","name":"job:up:sum","query":"sum by (job) (up)","health":"ok"}]}]}`,
+		"/api/v1/alerts":           `{"alerts":[{"labels":{"alertname":"APIDown"},"annotations":{},"state":"firing","value":"0"}]}`,
+		"/api/v1/metadata":         `{"up":[{"type":"gauge","help":"Target is up.","unit":""}]}`,
+		"/api/v1/status/tsdb":      `{"headStats":{"numSeries":2,"minTime":1000,"maxTime":2000},"seriesCountByMetricName":[{"name":"up","value":2}]}`,
+		"/api/v1/status/buildinfo": `{"version":"2.40.0","goVersion":"go1.19"}`,
+	}
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		query = r.Form.Encode()
+		data, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":` + data + `}`))
+	}))
+	defer srv.Close()
+	client, err := api.NewClient(api.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	start, end := time.Unix(1000, 0), time.Unix(2000, 0)
+	p := &Prometheus{}
+
+	tests := []struct {
+		name      string
+		call      func() (interface{}, error)
+		want      interface{}
+		wantQuery string
+	}{
+		{
+			name:      "series",
+			call:      func() (interface{}, error) { return p.Series(ctx, client, []string{`up{job="api"}`}, start, end) },
+			want:      []Labels{{"__name__": "up", "job": "api"}},
+			wantQuery: "end=2000&match%5B%5D=up%7Bjob%3D%22api%22%7D&start=1000",
+		},
+		{
+			name: "label names",
+			call: func() (interface{}, error) { return p.LabelNames(ctx, client, nil, start, end) },
+			want: []string{"__name__", "job"},
+		},
+		{
+			name:      "label values",
+			call:      func() (interface{}, error) { return p.LabelValues(ctx, client, "job", []string{"up"}, start, end) },
+			want:      []string{"api", "db"},
+			wantQuery: "end=2000&match%5B%5D=up&start=1000",
+		},
+		{
+			name: "targets",
+			call: func() (interface{}, error) { return p.Targets(ctx, client) },
+			want: Targets{
+				Active:  []Target{{ScrapePool: "api", ScrapeURL: "http://api:9100/metrics", Labels: Labels{"job": "api"}, Health: "up", LastScrapeDuration: 500 * time.Millisecond}},
+				Dropped: []Target{{DiscoveredLabels: Labels{"__address__": "db:9100"}}},
+			},
+		},
+		{
+			name: "rules",
+			call: func() (interface{}, error) { return p.Rules(ctx, client) },
+			want: []RuleGroup{{Name: "api", File: "api.yml", Interval: 30 * time.Second, Rules: []Rule{
+				{
+					Type: AlertingRule, Name: "APIDown", Query: "up == 0", Duration: 5 * time.Minute,
+					Labels: Labels{"severity": "page"}, Annotations: Labels{}, State: "firing", Health: "ok",
+					Alerts: []Alert{{Labels: Labels{"job": "api"}, Annotations: Labels{}, State: "firing", Value: "0"}},
+				},
+				{Type: RecordingRule, Name: "job:up:sum", Query: "sum by (job) (up)", Labels: Labels{}, Health: "ok"},
+			}}},
+		},
+		{
+			name: "alerts",
+			call: func() (interface{}, error) { return p.Alerts(ctx, client) },
+			want: []Alert{{Labels: Labels{"alertname": "APIDown"}, Annotations: Labels{}, State: "firing", Value: "0"}},
+		},
+		{
+			name:      "metadata",
+			call:      func() (interface{}, error) { return p.Metadata(ctx, client, "up", 10) },
+			want:      map[string][]Metadata{"up": {{Type: "gauge", Help: "Target is up."}}},
+			wantQuery: "limit=10&metric=up",
+		},
+		{
+			name: "tsdb",
+			call: func() (interface{}, error) { return p.TSDB(ctx, client) },
+			want: TSDBStats{
+				NumSeries: 2, MinTime: 1000, MaxTime: 2000,
+				SeriesCountByMetricName:     []Stat{{Name: "up", Value: 2}},
+				LabelValueCountByLabelName:  []Stat{},
+				MemoryInBytesByLabelName:    []Stat{},
+				SeriesCountByLabelValuePair: []Stat{},
+			},
+		},
+		{
+			name: "build info",
+			call: func() (interface{}, error) { return p.BuildInfo(ctx, client) },
+			want: BuildInfo{Version: "2.40.0", GoVersion: "go1.19"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call()
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if tt.wantQuery != "" && query != tt.wantQuery {
+				t.Errorf("query = %s, want %s", query, tt.wantQuery)
+			}
+		})
+	}
+}
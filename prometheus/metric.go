@@ -0,0 +1,132 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricType is the type of pushed metrics.
+type MetricType int
+
+const (
+	Gauge MetricType = iota
+	Counter
+	Histogram
+	Summary
+)
+
+func (t MetricType) String() string {
+	switch t {
+	case Gauge:
+		return "gauge"
+	case Counter:
+		return "counter"
+	case Histogram:
+		return "histogram"
+	case Summary:
+		return "summary"
+	}
+	return fmt.Sprintf("MetricType(%d)", int(t))
+}
+
+// help returns the help text of the metrics.
+func (pm PushMetrics) help() string {
+	if pm.Help != "" {
+		return pm.Help
+	}
+	return fmt.Sprintf("The jobs of %s in dynatrace.", pm.Name)
+}
+
+// Collector implements a new prometheus collector of the type of
+// the metrics, holding their values. Counters are increased by Data,
+// histograms and summaries observe Observations, or Data if there
+// are none.
+func (pm PushMetrics) Collector() (prometheus.Collector, error) {
+	switch pm.Type {
+	case Gauge:
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        pm.Name,
+			Help:        pm.help(),
+			ConstLabels: pm.ConstLabels,
+		}, pm.Label)
+		for _, m := range pm.Metrics {
+			g, err := vec.GetMetricWithLabelValues(m.Values...)
+			if err != nil {
+				return nil, err
+			}
+			g.Set(m.Data)
+		}
+		return vec, nil
+	case Counter:
+		vec := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        pm.Name,
+			Help:        pm.help(),
+			ConstLabels: pm.ConstLabels,
+		}, pm.Label)
+		for _, m := range pm.Metrics {
+			if m.Data < 0 {
+				return nil, fmt.Errorf("counter %s cannot decrease by %v", pm.Name, m.Data)
+			}
+			c, err := vec.GetMetricWithLabelValues(m.Values...)
+			if err != nil {
+				return nil, err
+			}
+			c.Add(m.Data)
+		}
+		return vec, nil
+	case Histogram:
+		vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        pm.Name,
+			Help:        pm.help(),
+			ConstLabels: pm.ConstLabels,
+			Buckets:     pm.Buckets,
+		}, pm.Label)
+		for _, m := range pm.Metrics {
+			o, err := vec.GetMetricWithLabelValues(m.Values...)
+			if err != nil {
+				return nil, err
+			}
+			m.observe(o)
+		}
+		return vec, nil
+	case Summary:
+		vec := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Name:        pm.Name,
+			Help:        pm.help(),
+			ConstLabels: pm.ConstLabels,
+			Objectives:  pm.Objectives,
+		}, pm.Label)
+		for _, m := range pm.Metrics {
+			o, err := vec.GetMetricWithLabelValues(m.Values...)
+			if err != nil {
+				return nil, err
+			}
+			m.observe(o)
+		}
+		return vec, nil
+	}
+	return nil, fmt.Errorf("unsupported metric type %v", pm.Type)
+}
+
+func (m PromMetrics) observe(o prometheus.Observer) {
+	if len(m.Observations) == 0 {
+		o.Observe(m.Data)
+		return
+	}
+	for _, v := range m.Observations {
+		o.Observe(v)
+	}
+}
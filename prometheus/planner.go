@@ -0,0 +1,340 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of RangePlanner.
+const (
+	DefaultMaxPoints     = 11000 // the maximum number of points of a Prometheus range query
+	DefaultConcurrency   = 4
+	DefaultMutableWindow = 10 * time.Minute
+)
+
+// ChunkCache caches the series of the chunks of range queries.
+// It must be safe for concurrent use.
+type ChunkCache interface {
+	Get(key string) (Matrix, bool)
+	Set(key string, m Matrix)
+}
+
+// RangePlanner runs range queries too long for a single request, split
+// into chunks aligned on multiples of their duration. Chunks are queried
+// concurrently, then their series are merged.
+type RangePlanner struct {
+	Client  api.Client
+	Options QueryOptions // options of the query of each chunk
+	// MaxPoints is the maximum number of steps of a chunk,
+	// defaults to DefaultMaxPoints.
+	MaxPoints int
+	// ChunkDuration is the duration of chunks, rounded down to a multiple
+	// of the step, defaults to and is at most MaxPoints steps.
+	ChunkDuration time.Duration
+	// Concurrency is the maximum number of concurrent chunk queries,
+	// defaults to DefaultConcurrency.
+	Concurrency int
+	// Cache caches the complete chunks ending before MutableWindow, which
+	// defaults to DefaultMutableWindow, and returned without warnings.
+	Cache         ChunkCache
+	MutableWindow time.Duration
+}
+
+// chunk is a range query of a chunk.
+type chunk struct {
+	r   v1.Range
+	key string // key of the cache, if the chunk is cacheable
+}
+
+// QueryRange runs the range query over r in chunks and returns the merged
+// series, sorted by labels, with the warnings of the chunks and, if
+// Options.Stats is set, the stats of the chunks not cached summed up.
+// It returns the first error of the chunks and cancels the others.
+func (p *RangePlanner) QueryRange(ctx context.Context, query string, r v1.Range) (*QueryResult, error) {
+	if r.Step <= 0 {
+		return nil, errors.New("range query step must be positive")
+	}
+	if r.End.Before(r.Start) {
+		return nil, errors.New("range query end is before start")
+	}
+	chunks := p.plan(query, r, time.Now())
+	results := make([]*QueryResult, len(chunks))
+
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		work     = make(chan int)
+	)
+	for w := 0; w < concurrency && w < len(chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				res, err := p.queryChunk(ctx, query, chunks[i])
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = res
+			}
+		}()
+	}
+send:
+	for i := range chunks {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(work)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return mergeResults(results), nil
+}
+
+// plan splits r into chunks of ChunkDuration aligned on its multiples,
+// shifted by the offset of r.Start to a multiple of the step so that
+// every chunk evaluates the steps of r.
+func (p *RangePlanner) plan(query string, r v1.Range, now time.Time) []chunk {
+	step := r.Step
+	maxPoints := p.MaxPoints
+	if maxPoints <= 0 {
+		maxPoints = DefaultMaxPoints
+	}
+	size := p.ChunkDuration / step * step
+	if size <= 0 || size > step*time.Duration(maxPoints) {
+		size = step * time.Duration(maxPoints)
+	}
+	mutable := p.MutableWindow
+	if mutable <= 0 {
+		mutable = DefaultMutableWindow
+	}
+	immutable := now.Add(-mutable)
+	phase := mod(r.Start.UnixNano(), int64(step))
+
+	var chunks []chunk
+	for start := r.Start; !start.After(r.End); {
+		ns := start.UnixNano() - phase
+		aligned := time.Unix(0, ns-mod(ns, int64(size))+phase)
+		next := aligned.Add(size)
+		c := chunk{r: v1.Range{Start: start, End: next.Add(-step), Step: step}}
+		if c.r.End.After(r.End) {
+			c.r.End = r.End
+		} else if p.Cache != nil && start.Equal(aligned) && c.r.End.Before(immutable) {
+			c.key = p.cacheKey(query, c.r)
+		}
+		chunks = append(chunks, c)
+		start = next
+	}
+	return chunks
+}
+
+// mod returns the non-negative remainder of a divided by b.
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+// cacheKey identifies the query of a chunk on the server of the client.
+func (p *RangePlanner) cacheKey(query string, r v1.Range) string {
+	args := p.Options.values(query)
+	args.Set("start", formatTime(r.Start))
+	args.Set("end", formatTime(r.End))
+	args.Set("step", formatSeconds(r.Step))
+	return p.Client.URL("/api/v1/query_range", nil).String() + "?" + args.Encode()
+}
+
+func (p *RangePlanner) queryChunk(ctx context.Context, query string, c chunk) (*QueryResult, error) {
+	if c.key != "" {
+		if m, ok := p.Cache.Get(c.key); ok {
+			return &QueryResult{Values: m}, nil
+		}
+	}
+	res, err := (&Prometheus{}).QueryRangeWithOptions(ctx, p.Client, query, c.r, p.Options)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := res.Values.(Matrix)
+	if !ok {
+		return nil, fmt.Errorf("range query returned %T, want Matrix", res.Values)
+	}
+	if c.key != "" && len(res.Warnings) == 0 {
+		p.Cache.Set(c.key, m)
+	}
+	return res, nil
+}
+
+// mergeResults merges the series, warnings and stats of the results.
+func mergeResults(results []*QueryResult) *QueryResult {
+	res := &QueryResult{}
+	matrices := make([]Matrix, 0, len(results))
+	seen := make(map[string]bool)
+	for _, r := range results {
+		matrices = append(matrices, r.Values.(Matrix))
+		for _, w := range r.Warnings {
+			if !seen[w] {
+				seen[w] = true
+				res.Warnings = append(res.Warnings, w)
+			}
+		}
+		res.addStats(r.Stats)
+	}
+	res.Values = mergeMatrices(matrices...)
+	return res
+}
+
+// addStats adds the timings and samples of s, if any, to the stats of r.
+func (r *QueryResult) addStats(s *QueryStats) {
+	if s == nil {
+		return
+	}
+	if r.Stats == nil {
+		r.Stats = &QueryStats{}
+	}
+	t := &r.Stats.Timings
+	t.EvalTotal += s.Timings.EvalTotal
+	t.ResultSort += s.Timings.ResultSort
+	t.QueryPreparation += s.Timings.QueryPreparation
+	t.InnerEval += s.Timings.InnerEval
+	t.ExecQueue += s.Timings.ExecQueue
+	t.ExecTotal += s.Timings.ExecTotal
+	r.Stats.Samples.TotalQueryableSamples += s.Samples.TotalQueryableSamples
+	if s.Samples.PeakSamples > r.Stats.Samples.PeakSamples {
+		r.Stats.Samples.PeakSamples = s.Samples.PeakSamples
+	}
+}
+
+// mergeMatrices merges the series with the same labels, sorted by labels,
+// with their samples sorted by timestamp and the first sample of each
+// timestamp only. The matrices are left unchanged.
+func mergeMatrices(matrices ...Matrix) Matrix {
+	var res Matrix
+	index := make(map[string]int)
+	for _, m := range matrices {
+		for _, r := range m {
+			key := r.Labels.String()
+			i, ok := index[key]
+			if !ok {
+				i = len(res)
+				index[key] = i
+				res = append(res, MatrixResult{Metric: r.Metric, Labels: r.Labels})
+			}
+			res[i].Values = append(res[i].Values, r.Values...)
+			res[i].Histograms = append(res[i].Histograms, r.Histograms...)
+		}
+	}
+	for i := range res {
+		values := res[i].Values
+		sort.SliceStable(values, func(a, b int) bool { return values[a].Timestamp < values[b].Timestamp })
+		n := 0
+		for j, v := range values {
+			if j == 0 || v.Timestamp != values[n-1].Timestamp {
+				values[n] = v
+				n++
+			}
+		}
+		res[i].Values = values[:n]
+
+		histograms := res[i].Histograms
+		sort.SliceStable(histograms, func(a, b int) bool { return histograms[a].Timestamp < histograms[b].Timestamp })
+		n = 0
+		for j, h := range histograms {
+			if j == 0 || h.Timestamp != histograms[n-1].Timestamp {
+				histograms[n] = h
+				n++
+			}
+		}
+		res[i].Histograms = histograms[:n]
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Metric < res[j].Metric })
+	return res
+}
+
+// MemoryCache is a ChunkCache in memory of at most Size chunks,
+// evicting the least recently used ones, or unbounded if Size is 0.
+type MemoryCache struct {
+	Size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // of *memoryEntry, most recently used first
+}
+
+type memoryEntry struct {
+	key string
+	m   Matrix
+}
+
+// NewMemoryCache returns a MemoryCache of at most size chunks.
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{Size: size}
+}
+
+func (c *MemoryCache) Get(key string) (Matrix, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*memoryEntry).m, true
+}
+
+func (c *MemoryCache) Set(key string, m Matrix) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*memoryEntry).m = m
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&memoryEntry{key: key, m: m})
+	for c.Size > 0 && c.lru.Len() > c.Size {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*memoryEntry).key)
+	}
+}
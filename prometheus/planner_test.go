@@ -0,0 +1,206 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rangeServer answers range queries with a series of the
+// value of the timestamp of each step, in seconds.
+type rangeServer struct {
+	mu       sync.Mutex
+	ranges   []string
+	active   int32
+	peak     int32
+	warnings string
+	fail     string // start of the failing chunk
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if n := atomic.AddInt32(&s.active, 1); n > atomic.LoadInt32(&s.peak) {
+		atomic.StoreInt32(&s.peak, n)
+	}
+	defer atomic.AddInt32(&s.active, -1)
+	time.Sleep(5 * time.Millisecond)
+
+	_ = r.ParseForm()
+	start, _ := strconv.ParseFloat(r.Form.Get("start"), 64)
+	end, _ := strconv.ParseFloat(r.Form.Get("end"), 64)
+	step, _ := strconv.ParseFloat(r.Form.Get("step"), 64)
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Form.Get("start")+"-"+r.Form.Get("end"))
+	s.mu.Unlock()
+	if r.Form.Get("start") == s.fail {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"status":"error","errorType":"execution","error":"query timed out"}`))
+		return
+	}
+	var values []string
+	for t := start; t <= end; t += step {
+		values = append(values, fmt.Sprintf(`[%g,"%g"]`, t, t))
+	}
+	_, _ = fmt.Fprintf(w, `{"status":"success","warnings":[%s],"data":{"resultType":"matrix","result":[`+
+		`{"metric":{"job":"b"},"values":[[%g,"0"]]},{"metric":{"job":"a"},"values":[%s]}]}}`,
+		s.warnings, start, strings.Join(values, ","))
+}
+
+func TestRangePlanner_QueryRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		planner RangePlanner
+		r       v1.Range
+		ranges  []string
+		points  int
+	}{
+		{
+			name:    "aligned",
+			planner: RangePlanner{MaxPoints: 10},
+			r:       v1.Range{Start: time.Unix(0, 0), End: time.Unix(1740, 0), Step: time.Minute},
+			ranges:  []string{"0-540", "1200-1740", "600-1140"},
+			points:  30,
+		},
+		{
+			name:    "unaligned",
+			planner: RangePlanner{ChunkDuration: 10 * time.Minute},
+			r:       v1.Range{Start: time.Unix(930, 0), End: time.Unix(1800, 0), Step: time.Minute},
+			ranges:  []string{"1230-1770", "930-1170"},
+			points:  15,
+		},
+		{
+			name:    "single chunk",
+			planner: RangePlanner{},
+			r:       v1.Range{Start: time.Unix(0, 0), End: time.Unix(300, 0), Step: time.Minute},
+			ranges:  []string{"0-300"},
+			points:  6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &rangeServer{}
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+			client, err := api.NewClient(api.Config{Address: ts.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := tt.planner
+			p.Client, p.Concurrency = client, 2
+			res, err := p.QueryRange(context.Background(), "up", tt.r)
+			if err != nil {
+				t.Fatalf("QueryRange() error = %v", err)
+			}
+			m := res.Values.(Matrix)
+			if len(m) != 2 || m[0].Label("job") != "a" || m[1].Label("job") != "b" {
+				t.Fatalf("QueryRange() = %v, want series a and b", m)
+			}
+			if len(m[0].Values) != tt.points {
+				t.Errorf("QueryRange() = %d samples, want %d", len(m[0].Values), tt.points)
+			}
+			for i, v := range m[0].Values {
+				want := tt.r.Start.Add(time.Duration(i) * tt.r.Step).Unix()
+				if v.Timestamp != want*1000 || v.Value != float64(want) {
+					t.Errorf("QueryRange() sample %d = %v, want %d", i, v, want)
+				}
+			}
+			srv.mu.Lock()
+			got := append([]string(nil), srv.ranges...)
+			srv.mu.Unlock()
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.ranges) {
+				t.Errorf("QueryRange() chunks = %v, want %v", got, tt.ranges)
+			}
+			if peak := atomic.LoadInt32(&srv.peak); peak > 2 {
+				t.Errorf("QueryRange() ran %d concurrent chunks, want at most 2", peak)
+			}
+		})
+	}
+}
+
+func TestRangePlanner_Cache(t *testing.T) {
+	srv := &rangeServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	client, err := api.NewClient(api.Config{Address: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &RangePlanner{Client: client, MaxPoints: 10, Cache: NewMemoryCache(10)}
+	r := v1.Range{Start: time.Unix(0, 0), End: time.Unix(1500, 0), Step: time.Minute}
+	first, err := p.QueryRange(context.Background(), "up", r)
+	if err != nil {
+		t.Fatalf("QueryRange() error = %v", err)
+	}
+	srv.ranges = nil
+	second, err := p.QueryRange(context.Background(), "up", r)
+	if err != nil {
+		t.Fatalf("QueryRange() error = %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("QueryRange() = %v, want %v", second, first)
+	}
+	// the last chunk is partial, so not cached
+	if want := []string{"1200-1500"}; !reflect.DeepEqual(srv.ranges, want) {
+		t.Errorf("QueryRange() chunks = %v, want %v", srv.ranges, want)
+	}
+
+	// chunks with warnings are not cached
+	srv.warnings, srv.ranges = `"partial"`, nil
+	res, err := p.QueryRange(context.Background(), "sum(up)", r)
+	if err != nil {
+		t.Fatalf("QueryRange() error = %v", err)
+	}
+	if !reflect.DeepEqual(res.Warnings, []string{"partial"}) {
+		t.Errorf("QueryRange() warnings = %v, want [partial]", res.Warnings)
+	}
+	srv.ranges = nil
+	if _, err = p.QueryRange(context.Background(), "sum(up)", r); err != nil {
+		t.Fatalf("QueryRange() error = %v", err)
+	}
+	if len(srv.ranges) != 3 {
+		t.Errorf("QueryRange() chunks = %v, want 3 chunks", srv.ranges)
+	}
+}
+
+func TestRangePlanner_Error(t *testing.T) {
+	srv := &rangeServer{fail: "600"}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	client, err := api.NewClient(api.Config{Address: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &RangePlanner{Client: client, MaxPoints: 10}
+	_, err = p.QueryRange(context.Background(), "up", v1.Range{Start: time.Unix(0, 0), End: time.Unix(3600, 0), Step: time.Minute})
+	if err == nil || !strings.Contains(err.Error(), "query timed out") {
+		t.Errorf("QueryRange() error = %v, want query timed out", err)
+	}
+	_, err = p.QueryRange(context.Background(), "up", v1.Range{Start: time.Unix(0, 0), End: time.Unix(60, 0)})
+	if err == nil {
+		t.Error("QueryRange() error = nil, want step error")
+	}
+}
+
+func TestMergeMatrices(t *testing.T) {
+	a, b := Labels{"job": "a"}, Labels{"job": "b"}
+	first := Matrix{series(b, 0, 1000, 1, 2), series(a, 0, 1000, 1, 2, 3)}
+	second := Matrix{series(a, 2000, 1000, 30, 4)}
+	want := Matrix{series(a, 0, 1000, 1, 2, 3, 4), series(b, 0, 1000, 1, 2)}
+	if got := mergeMatrices(first, second); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeMatrices() = %v, want %v", got, want)
+	}
+	if len(first[1].Values) != 3 {
+		t.Errorf("mergeMatrices() changed its matrices")
+	}
+}
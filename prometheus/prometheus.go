@@ -15,23 +15,35 @@ package prometheus
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/push"
 	"github.com/prometheus/common/model"
 	"net/http/httptrace"
 	"time"
 )
 
 // MetricsInterface is the interface that
-// implements prometheus push metrics
-// and range queries.
+// implements prometheus push metrics,
+// range queries and metadata queries.
 type MetricsInterface interface {
 	Push(context.Context, PushMetrics, string) error
+	PushBatch(context.Context, []PushMetrics, string) error
 	Query(ctx context.Context, client api.Client, query string, endTime int64) (ResultValues, error)
 	QueryRange(ctx context.Context, client api.Client, query string, r v1.Range, opts ...v1.Option) (ResultValues, error)
+	QueryWithOptions(ctx context.Context, client api.Client, query string, ts time.Time, opts QueryOptions) (*QueryResult, error)
+	QueryRangeWithOptions(ctx context.Context, client api.Client, query string, r v1.Range, opts QueryOptions) (*QueryResult, error)
+	Series(ctx context.Context, client api.Client, matches []string, start, end time.Time) ([]Labels, error)
+	LabelNames(ctx context.Context, client api.Client, matches []string, start, end time.Time) ([]string, error)
+	LabelValues(ctx context.Context, client api.Client, label string, matches []string, start, end time.Time) ([]string, error)
+	Targets(ctx context.Context, client api.Client) (Targets, error)
+	Rules(ctx context.Context, client api.Client) ([]RuleGroup, error)
+	Alerts(ctx context.Context, client api.Client) ([]Alert, error)
+	Metadata(ctx context.Context, client api.Client, metric string, limit int) (map[string][]Metadata, error)
+	TSDB(ctx context.Context, client api.Client) (TSDBStats, error)
+	BuildInfo(ctx context.Context, client api.Client) (BuildInfo, error)
 }
 
 // Prometheus implements MetricsInterface.
@@ -39,15 +51,23 @@ type Prometheus struct{}
 
 // PushMetrics implements a new Prometheus metrics.
 type PushMetrics struct {
-	Name    string        // description of metrics name
-	Label   []string      // description of metrics label
-	Metrics []PromMetrics // values of metrics label and metrics value
+	Name        string              // description of metrics name
+	Label       []string            // description of metrics label
+	Metrics     []PromMetrics       // values of metrics label and metrics value
+	Type        MetricType          // type of metrics, defaults to Gauge
+	Help        string              // help of metrics, defaults to "The jobs of <Name> in dynatrace."
+	ConstLabels map[string]string   // labels with the same value for all metrics
+	Buckets     []float64           // upper bounds of histogram buckets, defaults to prometheus.DefBuckets
+	Objectives  map[float64]float64 // quantiles of summary and their absolute error, none by default
+	Job         string              // job of the Pushgateway group, defaults to Name
+	Grouping    map[string]string   // other labels of the Pushgateway group
 }
 
 // PromMetrics is a prometheus metrics values.
 type PromMetrics struct {
-	Values []string // values of metrics label
-	Data   float64  // metrics value
+	Values       []string  // values of metrics label
+	Data         float64   // metrics value
+	Observations []float64 // values observed by histogram and summary, Data is observed when empty
 }
 
 var clientTrace = &httptrace.ClientTrace{
@@ -65,37 +85,45 @@ func DataValueVec(name string, label []string) *prometheus.GaugeVec {
 	}, label)
 }
 
-// Push implements a new prometheus metrics pushed to PushGateway.
+// Push implements a new prometheus metrics pushed to PushGateway,
+// of the type set in PushMetrics. It replaces the metrics of the
+// group of pm.Job, defaulting to pm.Name, and pm.Grouping.
 func (p *Prometheus) Push(ctx context.Context, pm PushMetrics, addr string) error {
-
-	gauge := DataValueVec(pm.Name, pm.Label)
-	for _, k := range pm.Metrics {
-		gauge.WithLabelValues(k.Values...).Set(k.Data)
+	traceCtx := httptrace.WithClientTrace(ctx, clientTrace)
+	g := &Pushgateway{URL: addr}
+	if err := g.Push(traceCtx, pm); err != nil {
+		fmt.Printf("prometheus push metrics error, err: %v", err)
+		return err
 	}
+	return nil
+}
 
+// PushBatch pushes all metrics to PushGateway in one request,
+// replacing the metrics of their group. Nothing is pushed if any
+// of the metrics is invalid or they belong to different groups.
+func (p *Prometheus) PushBatch(ctx context.Context, metrics []PushMetrics, addr string) error {
 	traceCtx := httptrace.WithClientTrace(ctx, clientTrace)
-	pn := push.New(addr, pm.Name)
-	err := pn.AddContext(traceCtx)
-	if err != nil {
-		fmt.Printf("prometheus add context error, err: %v", err)
+	g := &Pushgateway{URL: addr}
+	if err := g.Push(traceCtx, metrics...); err != nil {
+		fmt.Printf("prometheus push metrics error, err: %v", err)
 		return err
 	}
-	pn.Collector(gauge)
-	return pn.Push()
-	//return nil
+	return nil
 }
 
+// Query implements prometheus instant query at endTime, in milliseconds,
+// with a timeout of 5 seconds. Use QueryWithOptions for other options
+// and to get the warnings and stats of the query.
 func (p *Prometheus) Query(ctx context.Context, client api.Client, query string, endTime int64) (ResultValues, error) {
 	end := time.Unix(0, endTime*int64(time.Millisecond)).UTC()
-	v1api := v1.NewAPI(client)
-	res, warnings, err := v1api.Query(ctx, query, end, v1.WithTimeout(5*time.Second))
+	res, err := p.QueryWithOptions(ctx, client, query, end, QueryOptions{Timeout: 5 * time.Second})
 	if err != nil {
 		return nil, err
 	}
-	if len(warnings) > 0 {
-		fmt.Printf("Warnings: %v\n", warnings)
+	if len(res.Warnings) > 0 {
+		fmt.Printf("Warnings: %v\n", res.Warnings)
 	}
-	return convertValue(res), nil
+	return res.Values, nil
 }
 
 // QueryRange implements prometheus range query.
@@ -108,24 +136,25 @@ func (p *Prometheus) QueryRange(ctx context.Context, client api.Client, query st
 	if len(warnings) > 0 {
 		fmt.Printf("Warnings: %v\n", warnings)
 	}
-	return convertValue(v1Res), nil
+	return convertValue(v1Res)
 }
 
-func convertValue(value model.Value) (res ResultValues) {
-	switch value.Type() {
-	case model.ValScalar:
-		v := value.(*model.Scalar)
-		res = decodeScalar(v)
-	case model.ValVector:
-		v, _ := value.(model.Vector)
-		res = decodeVector(v)
-	case model.ValMatrix:
-		mv := value.(model.Matrix)
-		res = decodeMatrix(mv)
-	default:
-		fmt.Printf("Unknow Type")
+// convertValue converts the value of a query to its ResultValues.
+func convertValue(value model.Value) (ResultValues, error) {
+	if value == nil {
+		return nil, errors.New("query returned no result")
 	}
-	return
+	switch v := value.(type) {
+	case *model.Scalar:
+		return decodeScalar(v), nil
+	case model.Vector:
+		return decodeVector(v), nil
+	case model.Matrix:
+		return decodeMatrix(v), nil
+	case *model.String:
+		return String{Timestamp: int64(v.Timestamp), Value: v.Value}, nil
+	}
+	return nil, fmt.Errorf("unsupported result type %v", value.Type())
 }
 
 func decodeMatrix(matrix model.Matrix) (res Matrix) {
@@ -133,6 +162,7 @@ func decodeMatrix(matrix model.Matrix) (res Matrix) {
 	for _, ss := range matrix {
 		mr := MatrixResult{
 			Metric: fmt.Sprint(ss.Metric),
+			Labels: decodeLabels(ss.Metric),
 			Values: nil,
 		}
 		for _, sp := range ss.Values {
@@ -142,6 +172,9 @@ func decodeMatrix(matrix model.Matrix) (res Matrix) {
 			}
 			mr.Values = append(mr.Values, v)
 		}
+		for _, hp := range ss.Histograms {
+			mr.Histograms = append(mr.Histograms, decodeHistogram(hp.Timestamp, hp.Histogram))
+		}
 		res = append(res, mr)
 	}
 	return
@@ -151,11 +184,16 @@ func decodeVector(vector model.Vector) (res Vector) {
 	for _, mv := range vector {
 		v := VectorResult{
 			Metric: fmt.Sprint(mv.Metric),
+			Labels: decodeLabels(mv.Metric),
 			Values: MetricValues{
 				Timestamp: int64(mv.Timestamp),
 				Value:     float64(mv.Value),
 			},
 		}
+		if mv.Histogram != nil {
+			h := decodeHistogram(mv.Timestamp, mv.Histogram)
+			v.Histogram = &h
+		}
 		res = append(res, v)
 	}
 	return
@@ -169,3 +207,20 @@ func decodeScalar(scalar *model.Scalar) Scalar {
 		},
 	}
 }
+
+func decodeHistogram(ts model.Time, h *model.SampleHistogram) HistogramValues {
+	res := HistogramValues{
+		Timestamp: int64(ts),
+		Count:     float64(h.Count),
+		Sum:       float64(h.Sum),
+	}
+	for _, b := range h.Buckets {
+		res.Buckets = append(res.Buckets, HistogramBucket{
+			Boundaries: int(b.Boundaries),
+			Lower:      float64(b.Lower),
+			Upper:      float64(b.Upper),
+			Count:      float64(b.Count),
+		})
+	}
+	return res
+}
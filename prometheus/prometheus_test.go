@@ -6,8 +6,12 @@ import (
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -189,3 +193,135 @@ func TestPrometheus_QueryRange(t *testing.T) {
 		})
 	}
 }
+
+func TestPushMetrics_Collector(t *testing.T) {
+	tests := []struct {
+		name    string
+		pm      PushMetrics
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "gauge",
+			pm: PushMetrics{
+				Name:    "queue_size",
+				Label:   []string{"queue"},
+				Metrics: []PromMetrics{{Values: []string{"mail"}, Data: 3}},
+			},
+			want: `
+# HELP queue_size The jobs of queue_size in dynatrace.
+# TYPE queue_size gauge
+queue_size{queue="mail"} 3
+`,
+		},
+		{
+			name: "counter",
+			pm: PushMetrics{
+				Name:        "jobs_total",
+				Label:       []string{"status"},
+				Metrics:     []PromMetrics{{Values: []string{"ok"}, Data: 7}, {Values: []string{"failed"}, Data: 1}},
+				Type:        Counter,
+				Help:        "Jobs processed.",
+				ConstLabels: map[string]string{"team": "ops"},
+			},
+			want: `
+# HELP jobs_total Jobs processed.
+# TYPE jobs_total counter
+jobs_total{status="failed",team="ops"} 1
+jobs_total{status="ok",team="ops"} 7
+`,
+		},
+		{
+			name: "histogram",
+			pm: PushMetrics{
+				Name:    "backup_seconds",
+				Label:   []string{"db"},
+				Metrics: []PromMetrics{{Values: []string{"users"}, Observations: []float64{4, 12, 30}}},
+				Type:    Histogram,
+				Help:    "Backup duration.",
+				Buckets: []float64{5, 15},
+			},
+			want: `
+# HELP backup_seconds Backup duration.
+# TYPE backup_seconds histogram
+backup_seconds_bucket{db="users",le="5"} 1
+backup_seconds_bucket{db="users",le="15"} 2
+backup_seconds_bucket{db="users",le="+Inf"} 3
+backup_seconds_sum{db="users"} 46
+backup_seconds_count{db="users"} 3
+`,
+		},
+		{
+			name: "summary",
+			pm: PushMetrics{
+				Name:    "request_seconds",
+				Metrics: []PromMetrics{{Data: 0.5}},
+				Type:    Summary,
+				Help:    "Request duration.",
+			},
+			want: `
+# HELP request_seconds Request duration.
+# TYPE request_seconds summary
+request_seconds_sum 0.5
+request_seconds_count 1
+`,
+		},
+		{
+			name:    "negative counter",
+			pm:      PushMetrics{Name: "jobs_total", Metrics: []PromMetrics{{Data: -1}}, Type: Counter},
+			wantErr: true,
+		},
+		{
+			name:    "label values",
+			pm:      PushMetrics{Name: "queue_size", Label: []string{"queue"}, Metrics: []PromMetrics{{Data: 1}}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			pm:      PushMetrics{Name: "queue_size", Type: MetricType(9)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.pm.Collector()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Collector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := testutil.CollectAndCompare(got, strings.NewReader(tt.want)); err != nil {
+				t.Errorf("Collector() %v", err)
+			}
+		})
+	}
+}
+
+func TestPrometheus_Push_types(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	p := &Prometheus{}
+	pm := PushMetrics{
+		Name:    "backup_seconds",
+		Metrics: []PromMetrics{{Observations: []float64{4, 12}}},
+		Type:    Histogram,
+		Buckets: []float64{5, 15},
+	}
+	if err := p.Push(context.Background(), pm, srv.URL); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if len(paths) == 0 || paths[len(paths)-1] != "PUT /metrics/job/backup_seconds" {
+		t.Errorf("Push() requests = %v", paths)
+	}
+	pm.Type = Counter
+	pm.Metrics[0].Data = -1
+	if err := p.Push(context.Background(), pm, srv.URL); err == nil {
+		t.Errorf("Push() of a decreasing counter succeeded")
+	}
+}
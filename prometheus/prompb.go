@@ -0,0 +1,302 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"strconv"
+)
+
+// This file implements the protobuf messages of the Prometheus
+// remote storage protocol, see prompb/types.proto and
+// prompb/remote.proto in the Prometheus repository.
+
+// Series is a time series with its samples.
+type Series struct {
+	Labels  Labels         // labels of the series, including __name__
+	Samples []MetricValues // samples sorted by Timestamp, in milliseconds
+}
+
+// appendTimeSeries appends the TimeSeries message of s to b.
+func appendTimeSeries(b []byte, s Series) []byte {
+	for _, l := range s.Labels.List() {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, l.Label)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, l.Value)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, label)
+	}
+	for _, v := range s.Samples {
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(v.Value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(v.Timestamp))
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, sample)
+	}
+	return b
+}
+
+// marshalWriteRequest returns the WriteRequest message of series.
+func marshalWriteRequest(series []Series) []byte {
+	var b []byte
+	for _, s := range series {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendTimeSeries(nil, s))
+	}
+	return b
+}
+
+var errProto = errors.New("invalid protobuf message")
+
+// fields calls fn with the number, type and value of each field of
+// the message b. Values of varint and fixed fields are in v, values
+// of bytes fields in data.
+func fields(b []byte, fn func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errProto
+		}
+		b = b[n:]
+		var v uint64
+		var data []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(b)
+			v = uint64(v32)
+		case protowire.BytesType:
+			data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errProto
+		}
+		b = b[n:]
+		if err := fn(num, typ, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalLabel decodes a Label message into labels.
+func unmarshalLabel(b []byte, labels Labels) error {
+	var name, value string
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			name = string(data)
+		case 2:
+			value = string(data)
+		}
+		return nil
+	})
+	labels[name] = value
+	return err
+}
+
+// unmarshalTimeSeries decodes a TimeSeries message.
+func unmarshalTimeSeries(b []byte) (Series, error) {
+	s := Series{Labels: Labels{}}
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			return unmarshalLabel(data, s.Labels)
+		case 2:
+			var sample MetricValues
+			err := fields(data, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+				switch num {
+				case 1:
+					sample.Value = math.Float64frombits(v)
+				case 2:
+					sample.Timestamp = int64(v)
+				}
+				return nil
+			})
+			s.Samples = append(s.Samples, sample)
+			return err
+		}
+		return nil
+	})
+	return s, err
+}
+
+// unmarshalWriteRequest decodes a WriteRequest message.
+func unmarshalWriteRequest(b []byte) ([]Series, error) {
+	var series []Series
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		if num != 1 {
+			return nil
+		}
+		s, err := unmarshalTimeSeries(data)
+		series = append(series, s)
+		return err
+	})
+	return series, err
+}
+
+// MatchType is the type of a label Matcher.
+type MatchType int
+
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+func (t MatchType) String() string {
+	switch t {
+	case MatchEqual:
+		return "="
+	case MatchNotEqual:
+		return "!="
+	case MatchRegexp:
+		return "=~"
+	case MatchNotRegexp:
+		return "!~"
+	}
+	return fmt.Sprintf("MatchType(%d)", int(t))
+}
+
+// Matcher matches the series with a label Name whose value matches Value.
+type Matcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+}
+
+// String returns m in PromQL, such as job=~"api|db".
+func (m Matcher) String() string {
+	return quoteName(m.Name) + m.Type.String() + strconv.Quote(m.Value)
+}
+
+// response types of remote read.
+const (
+	samplesResponse           = 0
+	streamedXORChunksResponse = 1
+)
+
+// chunk encodings of streamed remote read.
+const chunkXOR = 1
+
+// marshalReadRequest returns the ReadRequest message of a query of
+// the series matching matchers between start and end, in milliseconds,
+// accepting streamed chunks or samples.
+func marshalReadRequest(start, end int64, matchers []Matcher) []byte {
+	var query []byte
+	query = protowire.AppendTag(query, 1, protowire.VarintType)
+	query = protowire.AppendVarint(query, uint64(start))
+	query = protowire.AppendTag(query, 2, protowire.VarintType)
+	query = protowire.AppendVarint(query, uint64(end))
+	for _, m := range matchers {
+		var matcher []byte
+		matcher = protowire.AppendTag(matcher, 1, protowire.VarintType)
+		matcher = protowire.AppendVarint(matcher, uint64(m.Type))
+		matcher = protowire.AppendTag(matcher, 2, protowire.BytesType)
+		matcher = protowire.AppendString(matcher, m.Name)
+		matcher = protowire.AppendTag(matcher, 3, protowire.BytesType)
+		matcher = protowire.AppendString(matcher, m.Value)
+		query = protowire.AppendTag(query, 3, protowire.BytesType)
+		query = protowire.AppendBytes(query, matcher)
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, query)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, protowire.AppendVarint(protowire.AppendVarint(nil, streamedXORChunksResponse), samplesResponse))
+	return b
+}
+
+// unmarshalReadResponse decodes the series of the first
+// QueryResult of a ReadResponse message.
+func unmarshalReadResponse(b []byte) ([]Series, error) {
+	var (
+		series []Series
+		first  = true
+	)
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		if num != 1 || !first {
+			return nil
+		}
+		first = false
+		return fields(data, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+			if num != 1 {
+				return nil
+			}
+			s, err := unmarshalTimeSeries(data)
+			series = append(series, s)
+			return err
+		})
+	})
+	return series, err
+}
+
+// unmarshalChunkedReadResponse decodes the series of a
+// ChunkedReadResponse message, with the samples of their chunks.
+func unmarshalChunkedReadResponse(b []byte) ([]Series, error) {
+	var series []Series
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		if num != 1 {
+			return nil
+		}
+		s := Series{Labels: Labels{}}
+		err := fields(data, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+			switch num {
+			case 1:
+				return unmarshalLabel(data, s.Labels)
+			case 2:
+				var (
+					encoding uint64
+					chunk    []byte
+				)
+				if err := fields(data, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+					switch num {
+					case 3:
+						encoding = v
+					case 4:
+						chunk = data
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				if encoding != chunkXOR {
+					return fmt.Errorf("unsupported chunk encoding %d", encoding)
+				}
+				samples, err := decodeXORChunk(chunk)
+				s.Samples = append(s.Samples, samples...)
+				return err
+			}
+			return nil
+		})
+		series = append(series, s)
+		return err
+	})
+	return series, err
+}
@@ -0,0 +1,481 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/common/model"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a PromQL expression built by Metric, Number, Func,
+// the aggregations such as Sum and Binary. Its String is the
+// PromQL to pass to Query and QueryRange.
+//
+// Metric names, label names and label values are escaped, so they
+// may come from user input. Function names given to Func are not.
+type Expr interface {
+	String() string
+	expr()
+}
+
+// Eq matches the series whose label name is value.
+func Eq(name, value string) Matcher { return Matcher{Type: MatchEqual, Name: name, Value: value} }
+
+// Neq matches the series whose label name is not value.
+func Neq(name, value string) Matcher { return Matcher{Type: MatchNotEqual, Name: name, Value: value} }
+
+// Re matches the series whose label name matches the regexp re.
+func Re(name, re string) Matcher { return Matcher{Type: MatchRegexp, Name: name, Value: re} }
+
+// Nre matches the series whose label name does not match the regexp re.
+func Nre(name, re string) Matcher { return Matcher{Type: MatchNotRegexp, Name: name, Value: re} }
+
+// quoteName returns a label name, quoted if it is not a valid
+// PromQL identifier.
+func quoteName(name string) string {
+	if model.LabelName(name).IsValid() {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// formatDuration returns d as a PromQL duration, such as 1h30m.
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + model.Duration(-d).String()
+	}
+	return model.Duration(d).String()
+}
+
+// Selector is an instant vector selector.
+type Selector struct {
+	Name     string // metric name, may be empty
+	Matchers []Matcher
+	Offset   time.Duration
+	At       string // @ modifier, such as 1609746000, start() or end()
+}
+
+// Metric selects the series of the metric name matching all matchers.
+func Metric(name string, matchers ...Matcher) Selector {
+	return Selector{Name: name, Matchers: matchers}
+}
+
+// Where returns s with more matchers.
+func (s Selector) Where(matchers ...Matcher) Selector {
+	s.Matchers = append(append([]Matcher(nil), s.Matchers...), matchers...)
+	return s
+}
+
+// OffsetBy returns s shifted back in time by d.
+func (s Selector) OffsetBy(d time.Duration) Selector {
+	s.Offset = d
+	return s
+}
+
+// Range returns the range vector of d of s.
+func (s Selector) Range(d time.Duration) RangeSelector {
+	return RangeSelector{Selector: s, Range: d}
+}
+
+func (s Selector) String() string {
+	var b strings.Builder
+	matchers := s.Matchers
+	if model.IsValidMetricName(model.LabelValue(s.Name)) {
+		b.WriteString(s.Name)
+	} else if s.Name != "" {
+		matchers = append([]Matcher{Eq(model.MetricNameLabel, s.Name)}, matchers...)
+	}
+	if len(matchers) > 0 || s.Name == "" {
+		b.WriteByte('{')
+		for i, m := range matchers {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(m.String())
+		}
+		b.WriteByte('}')
+	}
+	b.WriteString(modifiers(s.Offset, s.At))
+	return b.String()
+}
+
+// modifiers returns the offset and @ modifiers of a selector or subquery.
+func modifiers(offset time.Duration, at string) string {
+	var res string
+	if offset != 0 {
+		res += " offset " + formatDuration(offset)
+	}
+	if at != "" {
+		res += " @ " + at
+	}
+	return res
+}
+
+func (Selector) expr() {}
+
+// RangeSelector is a range vector selector.
+type RangeSelector struct {
+	Selector Selector // its Offset and At are ignored
+	Range    time.Duration
+	Offset   time.Duration
+	At       string
+}
+
+// OffsetBy returns r shifted back in time by d.
+func (r RangeSelector) OffsetBy(d time.Duration) RangeSelector {
+	r.Offset = d
+	return r
+}
+
+func (r RangeSelector) String() string {
+	s := r.Selector
+	s.Offset, s.At = 0, ""
+	return s.String() + "[" + formatDuration(r.Range) + "]" + modifiers(r.Offset, r.At)
+}
+
+func (RangeSelector) expr() {}
+
+// Subquery is the range vector of an instant vector
+// expression evaluated at every Step of Range.
+type Subquery struct {
+	Expr   Expr
+	Range  time.Duration
+	Step   time.Duration // defaults to the evaluation interval
+	Offset time.Duration
+	At     string
+}
+
+// SubqueryOf returns the subquery of e over r, at every step.
+func SubqueryOf(e Expr, r, step time.Duration) Subquery {
+	return Subquery{Expr: e, Range: r, Step: step}
+}
+
+func (s Subquery) String() string {
+	var step string
+	if s.Step != 0 {
+		step = formatDuration(s.Step)
+	}
+	e := s.Expr.String()
+	switch s.Expr.(type) {
+	case Selector, Call, ParenExpr:
+	default:
+		e = "(" + e + ")"
+	}
+	return e + "[" + formatDuration(s.Range) + ":" + step + "]" + modifiers(s.Offset, s.At)
+}
+
+func (Subquery) expr() {}
+
+// ParenExpr is an expression in parentheses.
+type ParenExpr struct {
+	Expr Expr
+}
+
+func (p ParenExpr) String() string { return "(" + p.Expr.String() + ")" }
+
+func (ParenExpr) expr() {}
+
+// UnaryExpr is a negated, or unary plus, expression.
+type UnaryExpr struct {
+	Op   BinaryOp // OpSub or OpAdd
+	Expr Expr
+}
+
+func (u UnaryExpr) String() string { return string(u.Op) + operand(u.Expr) }
+
+func (UnaryExpr) expr() {}
+
+// Number is a number literal.
+type Number float64
+
+func (n Number) String() string {
+	switch {
+	case math.IsInf(float64(n), 1):
+		return "Inf"
+	case math.IsInf(float64(n), -1):
+		return "-Inf"
+	case math.IsNaN(float64(n)):
+		return "NaN"
+	}
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+func (Number) expr() {}
+
+// StringLiteral is a string literal.
+type StringLiteral string
+
+func (s StringLiteral) String() string { return strconv.Quote(string(s)) }
+
+func (StringLiteral) expr() {}
+
+// Call is a function call.
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// Func calls the PromQL function name with args.
+func Func(name string, args ...Expr) Call {
+	return Call{Func: name, Args: args}
+}
+
+func (c Call) String() string {
+	args := make([]string, 0, len(c.Args))
+	for _, a := range c.Args {
+		args = append(args, a.String())
+	}
+	return c.Func + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (Call) expr() {}
+
+// Rate is the per-second rate of increase of the counters of r.
+func Rate(r RangeSelector) Call { return Func("rate", r) }
+
+// IRate is the per-second rate of the last two samples of the counters of r.
+func IRate(r RangeSelector) Call { return Func("irate", r) }
+
+// Increase is the increase of the counters of r.
+func Increase(r RangeSelector) Call { return Func("increase", r) }
+
+// Delta is the difference of the first and last values of the gauges of r.
+func Delta(r RangeSelector) Call { return Func("delta", r) }
+
+// AvgOverTime is the average of the values of r.
+func AvgOverTime(r RangeSelector) Call { return Func("avg_over_time", r) }
+
+// MinOverTime is the minimum of the values of r.
+func MinOverTime(r RangeSelector) Call { return Func("min_over_time", r) }
+
+// MaxOverTime is the maximum of the values of r.
+func MaxOverTime(r RangeSelector) Call { return Func("max_over_time", r) }
+
+// SumOverTime is the sum of the values of r.
+func SumOverTime(r RangeSelector) Call { return Func("sum_over_time", r) }
+
+// CountOverTime is the number of the values of r.
+func CountOverTime(r RangeSelector) Call { return Func("count_over_time", r) }
+
+// QuantileOverTime is the q-quantile of the values of r.
+func QuantileOverTime(q float64, r RangeSelector) Call {
+	return Func("quantile_over_time", Number(q), r)
+}
+
+// HistogramQuantile is the q-quantile of the histogram buckets of e.
+func HistogramQuantile(q float64, e Expr) Call {
+	return Func("histogram_quantile", Number(q), e)
+}
+
+// Abs is the absolute value of e.
+func Abs(e Expr) Call { return Func("abs", e) }
+
+// Absent is 1 if e has no samples.
+func Absent(e Expr) Call { return Func("absent", e) }
+
+// Aggregation is an aggregation over the series of an expression.
+type Aggregation struct {
+	Op        string
+	Param     Expr // parameter of topk, bottomk, quantile and count_values
+	Expr      Expr
+	Grouping  []string // labels of By or Without
+	Excluding bool     // set by Without
+}
+
+func aggregate(op string, param Expr, e Expr) Aggregation {
+	return Aggregation{Op: op, Param: param, Expr: e}
+}
+
+// Sum sums the series of e.
+func Sum(e Expr) Aggregation { return aggregate("sum", nil, e) }
+
+// Avg averages the series of e.
+func Avg(e Expr) Aggregation { return aggregate("avg", nil, e) }
+
+// Min is the minimum of the series of e.
+func Min(e Expr) Aggregation { return aggregate("min", nil, e) }
+
+// Max is the maximum of the series of e.
+func Max(e Expr) Aggregation { return aggregate("max", nil, e) }
+
+// Count counts the series of e.
+func Count(e Expr) Aggregation { return aggregate("count", nil, e) }
+
+// Group is 1 for each group of the series of e.
+func Group(e Expr) Aggregation { return aggregate("group", nil, e) }
+
+// Stddev is the standard deviation of the series of e.
+func Stddev(e Expr) Aggregation { return aggregate("stddev", nil, e) }
+
+// TopK is the k largest series of e.
+func TopK(k int, e Expr) Aggregation { return aggregate("topk", Number(float64(k)), e) }
+
+// BottomK is the k smallest series of e.
+func BottomK(k int, e Expr) Aggregation { return aggregate("bottomk", Number(float64(k)), e) }
+
+// Quantile is the q-quantile of the series of e.
+func Quantile(q float64, e Expr) Aggregation { return aggregate("quantile", Number(q), e) }
+
+// CountValues counts the series of e with the same value, in label.
+func CountValues(label string, e Expr) Aggregation {
+	return aggregate("count_values", StringLiteral(label), e)
+}
+
+// By returns a, aggregating by labels.
+func (a Aggregation) By(labels ...string) Aggregation {
+	a.Grouping, a.Excluding = labels, false
+	return a
+}
+
+// Without returns a, aggregating by all labels but labels.
+func (a Aggregation) Without(labels ...string) Aggregation {
+	a.Grouping, a.Excluding = labels, true
+	return a
+}
+
+func (a Aggregation) String() string {
+	var b strings.Builder
+	b.WriteString(a.Op)
+	if a.Grouping != nil || a.Excluding {
+		if a.Excluding {
+			b.WriteString(" without ")
+		} else {
+			b.WriteString(" by ")
+		}
+		b.WriteString(labelList(a.Grouping))
+	}
+	b.WriteString(" (")
+	if a.Param != nil {
+		b.WriteString(a.Param.String() + ", ")
+	}
+	b.WriteString(a.Expr.String() + ")")
+	return b.String()
+}
+
+func (Aggregation) expr() {}
+
+func labelList(labels []string) string {
+	quoted := make([]string, 0, len(labels))
+	for _, l := range labels {
+		quoted = append(quoted, quoteName(l))
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// BinaryOp is a binary operator.
+type BinaryOp string
+
+const (
+	OpAdd    BinaryOp = "+"
+	OpSub    BinaryOp = "-"
+	OpMul    BinaryOp = "*"
+	OpDiv    BinaryOp = "/"
+	OpMod    BinaryOp = "%"
+	OpPow    BinaryOp = "^"
+	OpAtan2  BinaryOp = "atan2"
+	OpEq     BinaryOp = "=="
+	OpNe     BinaryOp = "!="
+	OpGt     BinaryOp = ">"
+	OpLt     BinaryOp = "<"
+	OpGte    BinaryOp = ">="
+	OpLte    BinaryOp = "<="
+	OpAnd    BinaryOp = "and"
+	OpOr     BinaryOp = "or"
+	OpUnless BinaryOp = "unless"
+)
+
+// BinaryExpr is a binary operation.
+type BinaryExpr struct {
+	Op          BinaryOp
+	LHS, RHS    Expr
+	ReturnBool  bool     // bool modifier of comparisons
+	Matching    []string // labels of On or Ignoring
+	Ignore      bool     // set by Ignoring
+	Group       string   // group_left or group_right, set by GroupLeft or GroupRight
+	GroupLabels []string // labels copied by Group
+}
+
+// Binary applies op to lhs and rhs. Operands that are binary
+// operations are put in parentheses.
+func Binary(lhs Expr, op BinaryOp, rhs Expr) BinaryExpr {
+	return BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+}
+
+// Bool returns e returning 0 or 1 instead of filtering.
+func (e BinaryExpr) Bool() BinaryExpr {
+	e.ReturnBool = true
+	return e
+}
+
+// On returns e matching series on labels only.
+func (e BinaryExpr) On(labels ...string) BinaryExpr {
+	e.Matching, e.Ignore = append([]string{}, labels...), false
+	return e
+}
+
+// Ignoring returns e matching series ignoring labels.
+func (e BinaryExpr) Ignoring(labels ...string) BinaryExpr {
+	e.Matching, e.Ignore = append([]string{}, labels...), true
+	return e
+}
+
+// GroupLeft returns e matching many series of lhs with one
+// of rhs, copying labels of rhs.
+func (e BinaryExpr) GroupLeft(labels ...string) BinaryExpr {
+	e.Group, e.GroupLabels = "group_left", labels
+	return e
+}
+
+// GroupRight returns e matching many series of rhs with one
+// of lhs, copying labels of lhs.
+func (e BinaryExpr) GroupRight(labels ...string) BinaryExpr {
+	e.Group, e.GroupLabels = "group_right", labels
+	return e
+}
+
+func (e BinaryExpr) String() string {
+	var b strings.Builder
+	b.WriteString(operand(e.LHS) + " " + string(e.Op))
+	if e.ReturnBool {
+		b.WriteString(" bool")
+	}
+	if e.Matching != nil {
+		if e.Ignore {
+			b.WriteString(" ignoring")
+		} else {
+			b.WriteString(" on")
+		}
+		b.WriteString(labelList(e.Matching))
+	}
+	if e.Group != "" {
+		b.WriteString(" " + e.Group)
+		if len(e.GroupLabels) > 0 {
+			b.WriteString(labelList(e.GroupLabels))
+		}
+	}
+	b.WriteString(" " + operand(e.RHS))
+	return b.String()
+}
+
+func (BinaryExpr) expr() {}
+
+func operand(e Expr) string {
+	if _, ok := e.(BinaryExpr); ok {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
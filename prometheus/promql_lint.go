@@ -0,0 +1,374 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"github.com/prometheus/common/model"
+	"regexp"
+	"strings"
+)
+
+// Severity is the severity of a Finding.
+type Severity string
+
+const (
+	// SeverityError is a query Prometheus rejects.
+	SeverityError Severity = "error"
+	// SeverityWarning is a valid query likely to be wrong or slow.
+	SeverityWarning Severity = "warning"
+)
+
+// Lint rules.
+const (
+	RuleInvalidType       = "invalid-type"       // operands of the wrong type
+	RuleMissingRange      = "missing-range"      // instant vector given to a range vector function
+	RuleRateOnGauge       = "rate-on-gauge"      // rate, irate, increase or resets of a gauge
+	RuleRegexCardinality  = "regex-cardinality"  // regexp matching many label values
+	RuleUnboundedSelector = "unbounded-selector" // selector matching too many series
+)
+
+// Finding is a problem of a query found by Lint.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Expr     string // the offending expression
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Rule, f.Message)
+}
+
+// LintOptions configures Lint.
+type LintOptions struct {
+	// Types are the metric types by metric name, such as those of
+	// Metadata. Metrics missing from it are counters if their name
+	// ends with _total, _count, _sum or _bucket, or else gauges.
+	Types map[string]string
+	// HighCardinalityLabels are labels with many values, such as pod
+	// or path, on which regexp matchers other than alternations of
+	// literal values are reported.
+	HighCardinalityLabels []string
+	// RequireMatchers reports selectors without label matchers,
+	// which select every series of their metric.
+	RequireMatchers bool
+}
+
+// ValidateQuery parses a PromQL query and lints it. It returns the
+// findings of Lint, and an error if the query does not parse, a
+// *ParseError, or has findings of SeverityError.
+func ValidateQuery(query string, opts LintOptions) ([]Finding, error) {
+	e, err := ParseExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	findings := Lint(e, opts)
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return findings, fmt.Errorf("invalid query %q: %s: %s", query, f.Rule, f.Message)
+		}
+	}
+	return findings, nil
+}
+
+type valueType string
+
+const (
+	typeScalar valueType = "scalar"
+	typeVector valueType = "instant vector"
+	typeMatrix valueType = "range vector"
+	typeString valueType = "string"
+)
+
+// funcSig is the signature of a function: the types of its arguments,
+// s scalar, v instant vector, m range vector or S string, and the
+// number of optional last arguments, or -1 if the last one repeats.
+type funcSig struct {
+	args     string
+	optional int
+	ret      valueType
+}
+
+var argTypes = map[byte]valueType{'s': typeScalar, 'v': typeVector, 'm': typeMatrix, 'S': typeString}
+
+var functions = map[string]funcSig{
+	"abs": {"v", 0, typeVector}, "absent": {"v", 0, typeVector}, "absent_over_time": {"m", 0, typeVector},
+	"acos": {"v", 0, typeVector}, "acosh": {"v", 0, typeVector}, "asin": {"v", 0, typeVector},
+	"asinh": {"v", 0, typeVector}, "atan": {"v", 0, typeVector}, "atanh": {"v", 0, typeVector},
+	"avg_over_time": {"m", 0, typeVector}, "ceil": {"v", 0, typeVector}, "changes": {"m", 0, typeVector},
+	"clamp": {"vss", 0, typeVector}, "clamp_max": {"vs", 0, typeVector}, "clamp_min": {"vs", 0, typeVector},
+	"cos": {"v", 0, typeVector}, "cosh": {"v", 0, typeVector}, "count_over_time": {"m", 0, typeVector},
+	"day_of_month": {"v", 1, typeVector}, "day_of_week": {"v", 1, typeVector}, "day_of_year": {"v", 1, typeVector},
+	"days_in_month": {"v", 1, typeVector}, "deg": {"v", 0, typeVector}, "delta": {"m", 0, typeVector},
+	"deriv": {"m", 0, typeVector}, "exp": {"v", 0, typeVector}, "floor": {"v", 0, typeVector},
+	"histogram_avg": {"v", 0, typeVector}, "histogram_count": {"v", 0, typeVector},
+	"histogram_fraction": {"ssv", 0, typeVector}, "histogram_quantile": {"sv", 0, typeVector},
+	"histogram_stddev": {"v", 0, typeVector}, "histogram_stdvar": {"v", 0, typeVector},
+	"histogram_sum": {"v", 0, typeVector}, "holt_winters": {"mss", 0, typeVector}, "hour": {"v", 1, typeVector},
+	"idelta": {"m", 0, typeVector}, "increase": {"m", 0, typeVector}, "irate": {"m", 0, typeVector},
+	"label_join": {"vSSS", -1, typeVector}, "label_replace": {"vSSSS", 0, typeVector},
+	"last_over_time": {"m", 0, typeVector}, "ln": {"v", 0, typeVector}, "log10": {"v", 0, typeVector},
+	"log2": {"v", 0, typeVector}, "mad_over_time": {"m", 0, typeVector}, "max_over_time": {"m", 0, typeVector},
+	"min_over_time": {"m", 0, typeVector}, "minute": {"v", 1, typeVector}, "month": {"v", 1, typeVector},
+	"pi": {"", 0, typeScalar}, "predict_linear": {"ms", 0, typeVector}, "present_over_time": {"m", 0, typeVector},
+	"quantile_over_time": {"sm", 0, typeVector}, "rad": {"v", 0, typeVector}, "rate": {"m", 0, typeVector},
+	"resets": {"m", 0, typeVector}, "round": {"vs", 1, typeVector}, "scalar": {"v", 0, typeScalar},
+	"sgn": {"v", 0, typeVector}, "sin": {"v", 0, typeVector}, "sinh": {"v", 0, typeVector},
+	"sort": {"v", 0, typeVector}, "sort_desc": {"v", 0, typeVector}, "sqrt": {"v", 0, typeVector},
+	"stddev_over_time": {"m", 0, typeVector}, "stdvar_over_time": {"m", 0, typeVector},
+	"sum_over_time": {"m", 0, typeVector}, "tan": {"v", 0, typeVector}, "tanh": {"v", 0, typeVector},
+	"time": {"", 0, typeScalar}, "timestamp": {"v", 0, typeVector}, "vector": {"s", 0, typeVector},
+	"year": {"v", 1, typeVector},
+}
+
+// counterFuncs are the functions of counters.
+var counterFuncs = map[string]bool{"rate": true, "irate": true, "increase": true, "resets": true}
+
+// typeOf returns the type of the value of e.
+func typeOf(e Expr) valueType {
+	switch v := e.(type) {
+	case Number:
+		return typeScalar
+	case StringLiteral:
+		return typeString
+	case RangeSelector, Subquery:
+		return typeMatrix
+	case ParenExpr:
+		return typeOf(v.Expr)
+	case UnaryExpr:
+		return typeOf(v.Expr)
+	case Call:
+		if sig, ok := functions[v.Func]; ok {
+			return sig.ret
+		}
+	case BinaryExpr:
+		if typeOf(v.LHS) == typeScalar && typeOf(v.RHS) == typeScalar {
+			return typeScalar
+		}
+	}
+	return typeVector
+}
+
+// Lint checks the types of e and reports the expressions likely to be
+// wrong or slow, in the order of the query.
+func Lint(e Expr, opts LintOptions) []Finding {
+	l := &linter{opts: opts, highCardinality: map[string]bool{}}
+	for _, name := range opts.HighCardinalityLabels {
+		l.highCardinality[name] = true
+	}
+	l.walk(e)
+	return l.findings
+}
+
+type linter struct {
+	opts            LintOptions
+	highCardinality map[string]bool
+	findings        []Finding
+}
+
+func (l *linter) report(rule string, severity Severity, e Expr, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		Expr:     e.String(),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// expect reports e if it is not of one of types.
+func (l *linter) expect(e Expr, context string, types ...valueType) {
+	t := typeOf(e)
+	for _, want := range types {
+		if t == want {
+			return
+		}
+	}
+	l.report(RuleInvalidType, SeverityError, e, "expected %s in %s, got %s", types[0], context, t)
+}
+
+func (l *linter) walk(e Expr) {
+	switch v := e.(type) {
+	case Selector:
+		l.selector(v)
+	case RangeSelector:
+		l.selector(v.Selector)
+	case Subquery:
+		l.expect(v.Expr, "subquery", typeVector)
+		l.walk(v.Expr)
+	case ParenExpr:
+		l.walk(v.Expr)
+	case UnaryExpr:
+		l.expect(v.Expr, "unary expression", typeScalar, typeVector)
+		l.walk(v.Expr)
+	case Call:
+		l.call(v)
+	case Aggregation:
+		if v.Param != nil {
+			if v.Op == "count_values" {
+				l.expect(v.Param, "aggregation parameter", typeString)
+			} else {
+				l.expect(v.Param, "aggregation parameter", typeScalar)
+			}
+			l.walk(v.Param)
+		}
+		l.expect(v.Expr, "aggregation", typeVector)
+		l.walk(v.Expr)
+	case BinaryExpr:
+		l.binary(v)
+	}
+}
+
+func (l *linter) binary(e BinaryExpr) {
+	context := fmt.Sprintf("%q operation", e.Op)
+	l.expect(e.LHS, context, typeScalar, typeVector)
+	l.expect(e.RHS, context, typeScalar, typeVector)
+	lhs, rhs := typeOf(e.LHS), typeOf(e.RHS)
+	switch {
+	case isSetOp(e.Op) && (lhs == typeScalar || rhs == typeScalar):
+		l.report(RuleInvalidType, SeverityError, e, "set operator %q not allowed in binary scalar expression", e.Op)
+	case isComparison(e.Op) && !e.ReturnBool && lhs == typeScalar && rhs == typeScalar:
+		l.report(RuleInvalidType, SeverityError, e, "comparisons between scalars must use the bool modifier")
+	case (e.Matching != nil || e.Group != "") && (lhs != typeVector || rhs != typeVector):
+		l.report(RuleInvalidType, SeverityError, e, "vector matching only allowed between instant vectors")
+	}
+	l.walk(e.LHS)
+	l.walk(e.RHS)
+}
+
+func (l *linter) call(c Call) {
+	sig, ok := functions[c.Func]
+	if !ok {
+		l.report(RuleInvalidType, SeverityError, c, "unknown function %q", c.Func)
+		return
+	}
+	for i, arg := range c.Args {
+		want := argTypes[sig.args[len(sig.args)-1]]
+		if i < len(sig.args) {
+			want = argTypes[sig.args[i]]
+		}
+		got := typeOf(arg)
+		switch {
+		case got == want:
+		case want == typeMatrix && got == typeVector:
+			l.report(RuleMissingRange, SeverityError, c,
+				"%s() expects a range vector, add a range such as %s[5m]", c.Func, arg)
+		default:
+			l.report(RuleInvalidType, SeverityError, arg,
+				"expected %s in argument %d of %s(), got %s", want, i+1, c.Func, got)
+		}
+		if counterFuncs[c.Func] && got == typeMatrix {
+			l.counter(c, arg)
+		}
+		l.walk(arg)
+	}
+}
+
+// counter reports the gauges of the range vector r of the counter function of c.
+func (l *linter) counter(c Call, r Expr) {
+	for {
+		p, ok := r.(ParenExpr)
+		if !ok {
+			break
+		}
+		r = p.Expr
+	}
+	rs, ok := r.(RangeSelector)
+	if !ok {
+		return
+	}
+	name := selectorName(rs.Selector)
+	if name == "" {
+		return
+	}
+	if typ, ok := l.opts.Types[name]; ok {
+		if typ != "counter" && typ != "unknown" && typ != "untyped" {
+			l.report(RuleRateOnGauge, SeverityWarning, c, "%s() of %s %s, use deriv() or delta() of gauges", c.Func, typ, name)
+		}
+		return
+	}
+	for _, suffix := range []string{"_total", "_count", "_sum", "_bucket"} {
+		if strings.HasSuffix(name, suffix) {
+			return
+		}
+	}
+	l.report(RuleRateOnGauge, SeverityWarning, c, "%s() of %s, which does not look like a counter", c.Func, name)
+}
+
+// selectorName returns the metric name of s, if any.
+func selectorName(s Selector) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	for _, m := range s.Matchers {
+		if m.Name == model.MetricNameLabel && m.Type == MatchEqual {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+func (l *linter) selector(s Selector) {
+	nonEmpty, named := s.Name != "", s.Name != ""
+	for _, m := range s.Matchers {
+		if !matchesEmpty(m) {
+			nonEmpty = true
+		}
+		if m.Name == model.MetricNameLabel && !matchesEmpty(m) {
+			named = true
+		}
+		if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+			l.regexp(s, m)
+		}
+	}
+	switch {
+	case !nonEmpty:
+		l.report(RuleUnboundedSelector, SeverityError, s, "vector selector must contain at least one non-empty matcher")
+	case !named:
+		l.report(RuleUnboundedSelector, SeverityWarning, s, "selector without metric name selects series of every metric")
+	case l.opts.RequireMatchers && len(s.Matchers) == 0:
+		l.report(RuleUnboundedSelector, SeverityWarning, s, "selector selects every series of %s, add label matchers", s.Name)
+	}
+}
+
+// matchesEmpty reports whether m matches series without its label.
+func matchesEmpty(m Matcher) bool {
+	switch m.Type {
+	case MatchEqual:
+		return m.Value == ""
+	case MatchNotEqual:
+		return m.Value != ""
+	}
+	re, err := regexp.Compile("^(?:" + m.Value + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString("") == (m.Type == MatchRegexp)
+}
+
+func (l *linter) regexp(s Selector, m Matcher) {
+	if m.Type == MatchNotRegexp {
+		return
+	}
+	switch {
+	case m.Value == ".*":
+		l.report(RuleRegexCardinality, SeverityWarning, s, "%s matches every series, remove it", m)
+	case m.Value == ".+":
+		l.report(RuleRegexCardinality, SeverityWarning, s, "%s matches every value of %s, use %s", m, m.Name, Neq(m.Name, ""))
+	case strings.HasPrefix(m.Value, ".*") || strings.HasPrefix(m.Value, ".+"):
+		l.report(RuleRegexCardinality, SeverityWarning, s, "%s has a leading wildcard, matching every value of %s", m, m.Name)
+	case l.highCardinality[m.Name] && strings.ContainsAny(m.Value, `.*+?()[]{}^$\`):
+		l.report(RuleRegexCardinality, SeverityWarning, s, "%s on high cardinality label %s, match literal values", m, m.Name)
+	}
+}
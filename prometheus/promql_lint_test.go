@@ -0,0 +1,164 @@
+package prometheus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "selector", query: `up{job="api",instance!~'a.*'}`, want: `up{job="api", instance!~"a.*"}`},
+		{name: "aggregation", query: `sum(rate(http_requests_total[5m])) BY (job)`, want: `sum by (job) (rate(http_requests_total[5m]))`},
+		{name: "precedence", query: `a + b * c ^ d ^ e`, want: `a + (b * (c ^ (d ^ e)))`},
+		{name: "unary", query: `-a ^ 2 - -1`, want: `-(a ^ 2) - -1`},
+		{name: "parens", query: `(a or b) and on(job) c`, want: `(a or b) and on(job) c`},
+		{name: "vector matching", query: `a / ignoring(code) group_left(team) b`, want: `a / ignoring(code) group_left(team) b`},
+		{name: "comparison bool", query: `up == bool 0`, want: `up == bool 0`},
+		{name: "subquery", query: `max_over_time(rate(x_total[1m])[1h:5m] offset 1d)`, want: `max_over_time(rate(x_total[1m])[1h:5m] offset 1d)`},
+		{name: "modifiers", query: `x[5m] @ 1609746000 offset -1m`, want: `x[5m] offset -1m @ 1609746000`},
+		{name: "numbers", query: `0x10 + 1.5e3 + .5 - inf`, want: `((16 + 1500) + 0.5) - Inf`},
+		{name: "strings", query: "label_replace(up, 'dst', `a\\b`, \"src\", \"\\\\d\")", want: `label_replace(up, "dst", "a\\b", "src", "\\d")`},
+		{name: "topk", query: `topk(3, sum by (pod) (x))`, want: `topk (3, sum by (pod) (x))`},
+		{name: "comment", query: "up # is up\n  == 1", want: `up == 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExpr(tt.query)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseExpr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpr_Error(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		line   int
+		column int
+		msg    string
+	}{
+		{name: "empty", query: ` `, line: 1, column: 1, msg: "no expression found in input"},
+		{name: "unclosed call", query: `sum(rate(x[5m])`, line: 1, column: 16, msg: "unexpected end of input, expected \",\" or \")\""},
+		{name: "unknown function", query: `sum(bogus(x))`, line: 1, column: 5, msg: `unknown function with name "bogus"`},
+		{name: "arguments", query: `histogram_quantile(x)`, line: 1, column: 1, msg: `expected 2 argument(s) in call to "histogram_quantile", got 1`},
+		{name: "range of call", query: "rate(x[5m])\n  [5m]", line: 2, column: 3, msg: "ranges only allowed for vector selectors"},
+		{name: "bad regexp", query: `x{a=~"("}`, line: 1, column: 6, msg: "invalid regular expression \"(\": error parsing regexp: missing closing ): `^(?:()$`"},
+		{name: "bad duration", query: `x[5x]`, line: 1, column: 3, msg: `bad number or duration syntax "5x"`},
+		{name: "missing operand", query: `a +`, line: 1, column: 3, msg: `missing right-hand side of "+"`},
+		{name: "bool", query: `a + bool b`, line: 1, column: 5, msg: "bool modifier can only be used on comparison operators"},
+		{name: "unterminated", query: `x{a="b}`, line: 1, column: 5, msg: "unterminated quoted string"},
+		{name: "offset twice", query: `x offset 1m offset 1m`, line: 1, column: 13, msg: "offset may not be set multiple times"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpr(tt.query)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("ParseExpr() error = %v, want *ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Msg != tt.msg {
+				t.Errorf("ParseExpr() error = %d:%d %s, want %d:%d %s", perr.Line, perr.Column, perr.Msg, tt.line, tt.column, tt.msg)
+			}
+		})
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	opts := LintOptions{
+		Types:                 map[string]string{"node_load1": "gauge", "legacy_requests": "counter"},
+		HighCardinalityLabels: []string{"pod"},
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    []Finding
+		wantErr bool
+	}{
+		{name: "valid", query: `sum by (job) (rate(http_requests_total{job="api"}[5m]))`},
+		{name: "counter from metadata", query: `rate(legacy_requests[5m])`},
+		{
+			name:  "rate on gauge",
+			query: `rate(node_load1[5m])`,
+			want:  []Finding{{Rule: RuleRateOnGauge, Severity: SeverityWarning, Expr: `rate(node_load1[5m])`, Message: "rate() of gauge node_load1, use deriv() or delta() of gauges"}},
+		},
+		{
+			name:  "rate on gauge name",
+			query: `increase(memory_bytes[1h])`,
+			want:  []Finding{{Rule: RuleRateOnGauge, Severity: SeverityWarning, Expr: `increase(memory_bytes[1h])`, Message: "increase() of memory_bytes, which does not look like a counter"}},
+		},
+		{
+			name:    "missing range",
+			query:   `rate(http_requests_total)`,
+			want:    []Finding{{Rule: RuleMissingRange, Severity: SeverityError, Expr: `rate(http_requests_total)`, Message: "rate() expects a range vector, add a range such as http_requests_total[5m]"}},
+			wantErr: true,
+		},
+		{
+			name:  "regexp",
+			query: `up{instance=~".*:9100", pod=~"api-.+", pod=~"a|b", job=~".+"}`,
+			want: []Finding{
+				{Rule: RuleRegexCardinality, Severity: SeverityWarning, Expr: `up{instance=~".*:9100", pod=~"api-.+", pod=~"a|b", job=~".+"}`, Message: `instance=~".*:9100" has a leading wildcard, matching every value of instance`},
+				{Rule: RuleRegexCardinality, Severity: SeverityWarning, Expr: `up{instance=~".*:9100", pod=~"api-.+", pod=~"a|b", job=~".+"}`, Message: `pod=~"api-.+" on high cardinality label pod, match literal values`},
+				{Rule: RuleRegexCardinality, Severity: SeverityWarning, Expr: `up{instance=~".*:9100", pod=~"api-.+", pod=~"a|b", job=~".+"}`, Message: `job=~".+" matches every value of job, use job!=""`},
+			},
+		},
+		{
+			name:  "without metric name",
+			query: `count({job="api"})`,
+			want:  []Finding{{Rule: RuleUnboundedSelector, Severity: SeverityWarning, Expr: `{job="api"}`, Message: "selector without metric name selects series of every metric"}},
+		},
+		{
+			name:  "empty matchers",
+			query: `{job=~".*"}`,
+			want: []Finding{
+				{Rule: RuleRegexCardinality, Severity: SeverityWarning, Expr: `{job=~".*"}`, Message: `job=~".*" matches every series, remove it`},
+				{Rule: RuleUnboundedSelector, Severity: SeverityError, Expr: `{job=~".*"}`, Message: "vector selector must contain at least one non-empty matcher"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			query:   `sum(x[5m])`,
+			want:    []Finding{{Rule: RuleInvalidType, Severity: SeverityError, Expr: `x[5m]`, Message: "expected instant vector in aggregation, got range vector"}},
+			wantErr: true,
+		},
+		{
+			name:    "scalar comparison",
+			query:   `1 > 2`,
+			want:    []Finding{{Rule: RuleInvalidType, Severity: SeverityError, Expr: `1 > 2`, Message: "comparisons between scalars must use the bool modifier"}},
+			wantErr: true,
+		},
+		{name: "syntax error", query: `sum(`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateQuery(tt.query, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateQuery_RequireMatchers(t *testing.T) {
+	got, err := ValidateQuery(`sum(up) / count(up{job="api"})`, LintOptions{RequireMatchers: true})
+	if err != nil {
+		t.Fatalf("ValidateQuery() error = %v", err)
+	}
+	want := []Finding{{Rule: RuleUnboundedSelector, Severity: SeverityWarning, Expr: `up`, Message: "selector selects every series of up, add label matchers"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateQuery() = %v, want %v", got, want)
+	}
+}
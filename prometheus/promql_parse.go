@@ -0,0 +1,627 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"github.com/prometheus/common/model"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError is a syntax error of a PromQL expression.
+type ParseError struct {
+	Query  string
+	Offset int // byte offset of the error in Query
+	Line   int // line of the error, from 1
+	Column int // byte column of the error in its line, from 1
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: parse error: %s", e.Line, e.Column, e.Msg)
+}
+
+// ParseExpr parses a PromQL expression into the Expr types of the
+// builder. It checks the syntax and the number of arguments of
+// functions and aggregations, Lint checks the types.
+func ParseExpr(query string) (e Expr, err error) {
+	p := &parser{query: query}
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(*ParseError)
+			if !ok {
+				panic(r)
+			}
+			e, err = nil, perr
+		}
+	}()
+	p.lex()
+	if p.peek().kind == tokEOF {
+		p.fail(0, "no expression found in input")
+	}
+	e = p.parseExpr(1)
+	if t := p.peek(); t.kind != tokEOF {
+		p.fail(t.pos, "unexpected %s", t)
+	}
+	return e, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokDuration
+	tokString
+	tokOp // operators and punctuation
+)
+
+type token struct {
+	kind tokenKind
+	val  string // unquoted value of strings
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return "string " + strconv.Quote(t.val)
+	case tokDuration:
+		return "duration " + t.val
+	case tokNumber:
+		return "number " + t.val
+	}
+	return strconv.Quote(t.val)
+}
+
+var (
+	durationRe = regexp.MustCompile(`^(?:[0-9]+(?:ms|[smhdwy]))+`)
+	numberRe   = regexp.MustCompile(`^(?:0[xX][0-9a-fA-F]+|(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?)`)
+	operators  = []string{"==", "!=", ">=", "<=", "=~", "!~", "+", "-", "*", "/", "%", "^",
+		"=", ">", "<", "(", ")", "{", "}", "[", "]", ",", "@"}
+)
+
+type parser struct {
+	query string
+	toks  []token
+	i     int
+}
+
+// fail panics with a ParseError at pos, recovered by ParseExpr.
+func (p *parser) fail(pos int, format string, args ...interface{}) {
+	line := 1 + strings.Count(p.query[:pos], "\n")
+	col := pos - strings.LastIndex(p.query[:pos], "\n")
+	panic(&ParseError{Query: p.query, Offset: pos, Line: line, Column: col, Msg: fmt.Sprintf(format, args...)})
+}
+
+// lex splits the query into tokens.
+func (p *parser) lex() {
+	q, brackets := p.query, 0
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(q) && q[i] != '\n' {
+				i++
+			}
+		case c == ':' && brackets > 0:
+			p.toks = append(p.toks, token{kind: tokOp, val: ":", pos: i})
+			i++
+		case isNameChar(c, true, true):
+			start := i
+			for i < len(q) && isNameChar(q[i], false, true) {
+				i++
+			}
+			p.toks = append(p.toks, token{kind: tokIdent, val: q[start:i], pos: start})
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(q) && q[i+1] >= '0' && q[i+1] <= '9':
+			kind, m := tokDuration, durationRe.FindString(q[i:])
+			if m == "" || i+len(m) < len(q) && isNameChar(q[i+len(m)], false, false) {
+				kind, m = tokNumber, numberRe.FindString(q[i:])
+			}
+			if end := i + len(m); end < len(q) && (isNameChar(q[end], false, false) || q[end] == '.') {
+				p.fail(i, "bad number or duration syntax %q", q[i:end+1])
+			}
+			p.toks = append(p.toks, token{kind: kind, val: m, pos: i})
+			i += len(m)
+		case c == '"' || c == '\'' || c == '`':
+			s, n := p.lexString(i)
+			p.toks = append(p.toks, token{kind: tokString, val: s, pos: i})
+			i += n
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(q[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				p.fail(i, "unexpected character %q", c)
+			}
+			switch op {
+			case "[":
+				brackets++
+			case "]":
+				brackets--
+			}
+			p.toks = append(p.toks, token{kind: tokOp, val: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.toks = append(p.toks, token{kind: tokEOF, pos: len(q)})
+}
+
+// lexString returns the unquoted string at pos and its length in the query.
+// Backquoted strings are raw, others are unescaped as in Go.
+func (p *parser) lexString(pos int) (string, int) {
+	quote := p.query[pos]
+	if quote == '`' {
+		end := strings.IndexByte(p.query[pos+1:], '`')
+		if end < 0 {
+			p.fail(pos, "unterminated raw string")
+		}
+		return p.query[pos+1 : pos+1+end], end + 2
+	}
+	var b strings.Builder
+	s := p.query[pos+1:]
+	for len(s) > 0 && s[0] != quote {
+		if s[0] == '\n' {
+			break
+		}
+		r, _, tail, err := strconv.UnquoteChar(s, quote)
+		if err != nil {
+			p.fail(len(p.query)-len(s), "invalid escape sequence in string")
+		}
+		b.WriteRune(r)
+		s = tail
+	}
+	if len(s) == 0 || s[0] != quote {
+		p.fail(pos, "unterminated quoted string")
+	}
+	return b.String(), len(p.query) - len(s) - pos + 1
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) expect(op string) token {
+	t := p.next()
+	if !isOp(t, op) {
+		p.fail(t.pos, "unexpected %s, expected %q", t, op)
+	}
+	return t
+}
+
+func isOp(t token, op string) bool { return t.kind == tokOp && t.val == op }
+
+func isKeyword(t token, keyword string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.val, keyword)
+}
+
+// keywords may not be used as metric names.
+var keywords = map[string]bool{
+	"and": true, "or": true, "unless": true, "atan2": true, "bool": true, "by": true, "without": true,
+	"on": true, "ignoring": true, "group_left": true, "group_right": true, "offset": true,
+}
+
+// binaryOp returns the binary operator of t and its precedence,
+// from 1 for or to 6 for ^, or 0 if t is not a binary operator.
+func binaryOp(t token) (BinaryOp, int) {
+	switch {
+	case t.kind == tokOp:
+		switch op := BinaryOp(t.val); op {
+		case OpPow:
+			return op, 6
+		case OpMul, OpDiv, OpMod:
+			return op, 5
+		case OpAdd, OpSub:
+			return op, 4
+		case OpEq, OpNe, OpGt, OpLt, OpGte, OpLte:
+			return op, 3
+		}
+	case t.kind == tokIdent:
+		switch op := BinaryOp(strings.ToLower(t.val)); op {
+		case OpAtan2:
+			return op, 5
+		case OpAnd, OpUnless:
+			return op, 2
+		case OpOr:
+			return op, 1
+		}
+	}
+	return "", 0
+}
+
+func isComparison(op BinaryOp) bool {
+	switch op {
+	case OpEq, OpNe, OpGt, OpLt, OpGte, OpLte:
+		return true
+	}
+	return false
+}
+
+func isSetOp(op BinaryOp) bool { return op == OpAnd || op == OpOr || op == OpUnless }
+
+// parseExpr parses binary operations of operators of precedence minPrec
+// or higher. ^ is right associative, the others left associative.
+func (p *parser) parseExpr(minPrec int) Expr {
+	lhs := p.parseUnary()
+	for {
+		op, prec := binaryOp(p.peek())
+		if prec == 0 || prec < minPrec {
+			return lhs
+		}
+		opTok := p.next()
+		e := BinaryExpr{Op: op, LHS: lhs}
+		if t := p.peek(); isKeyword(t, "bool") {
+			if !isComparison(op) {
+				p.fail(t.pos, "bool modifier can only be used on comparison operators")
+			}
+			p.next()
+			e.ReturnBool = true
+		}
+		if t := p.peek(); isKeyword(t, "on") || isKeyword(t, "ignoring") {
+			p.next()
+			e.Ignore = isKeyword(t, "ignoring")
+			e.Matching = p.labels()
+			if t := p.peek(); isKeyword(t, "group_left") || isKeyword(t, "group_right") {
+				if isSetOp(op) {
+					p.fail(t.pos, "no grouping allowed for %q operation", op)
+				}
+				p.next()
+				e.Group = strings.ToLower(t.val)
+				if isOp(p.peek(), "(") {
+					e.GroupLabels = p.labels()
+				}
+			}
+		}
+		if p.peek().kind == tokEOF {
+			p.fail(opTok.pos, "missing right-hand side of %q", op)
+		}
+		if op == OpPow {
+			e.RHS = p.parseExpr(prec)
+		} else {
+			e.RHS = p.parseExpr(prec + 1)
+		}
+		lhs = e
+	}
+}
+
+// parseUnary parses a unary expression, binding looser than ^.
+func (p *parser) parseUnary() Expr {
+	if t := p.peek(); isOp(t, "-") || isOp(t, "+") {
+		p.next()
+		e := p.parseExpr(6)
+		if n, ok := e.(Number); ok {
+			if t.val == "-" {
+				return -n
+			}
+			return n
+		}
+		return UnaryExpr{Op: BinaryOp(t.val), Expr: e}
+	}
+	return p.parsePostfix(p.parsePrimary())
+}
+
+// parsePostfix parses the ranges, subqueries and modifiers following e.
+func (p *parser) parsePostfix(e Expr) Expr {
+	for {
+		t := p.peek()
+		switch {
+		case isOp(t, "["):
+			p.next()
+			r := p.duration(true)
+			if isOp(p.peek(), ":") {
+				p.next()
+				var step time.Duration
+				if !isOp(p.peek(), "]") {
+					step = p.duration(true)
+				}
+				p.expect("]")
+				e = Subquery{Expr: e, Range: r, Step: step}
+				continue
+			}
+			p.expect("]")
+			s, ok := e.(Selector)
+			if !ok || s.Offset != 0 || s.At != "" {
+				p.fail(t.pos, "ranges only allowed for vector selectors")
+			}
+			e = RangeSelector{Selector: s, Range: r}
+		case isKeyword(t, "offset"):
+			p.next()
+			neg := false
+			if t := p.peek(); isOp(t, "-") || isOp(t, "+") {
+				p.next()
+				neg = t.val == "-"
+			}
+			d := p.duration(false)
+			if neg {
+				d = -d
+			}
+			e = p.modify(e, t.pos, &d, nil)
+		case isOp(t, "@"):
+			p.next()
+			at := p.next()
+			switch {
+			case at.kind == tokNumber:
+			case isOp(at, "-") && p.peek().kind == tokNumber:
+				at.val = "-" + p.next().val
+			case isKeyword(at, "start") || isKeyword(at, "end"):
+				p.expect("(")
+				p.expect(")")
+				at.val = strings.ToLower(at.val) + "()"
+			default:
+				p.fail(at.pos, "unexpected %s, expected a timestamp, start() or end()", at)
+			}
+			e = p.modify(e, t.pos, nil, &at.val)
+		default:
+			return e
+		}
+	}
+}
+
+// modify sets the offset or @ modifier of a selector or subquery.
+func (p *parser) modify(e Expr, pos int, offset *time.Duration, at *string) Expr {
+	set := func(o *time.Duration, a *string) {
+		if offset != nil {
+			if *o != 0 {
+				p.fail(pos, "offset may not be set multiple times")
+			}
+			*o = *offset
+		}
+		if at != nil {
+			if *a != "" {
+				p.fail(pos, "@ may not be set multiple times")
+			}
+			*a = *at
+		}
+	}
+	switch v := e.(type) {
+	case Selector:
+		set(&v.Offset, &v.At)
+		return v
+	case RangeSelector:
+		set(&v.Offset, &v.At)
+		return v
+	case Subquery:
+		set(&v.Offset, &v.At)
+		return v
+	}
+	p.fail(pos, "offset and @ modifiers must follow a selector or a subquery")
+	return nil
+}
+
+// duration parses a duration, which must be positive if positive is set.
+func (p *parser) duration(positive bool) time.Duration {
+	t := p.next()
+	if t.kind != tokDuration {
+		p.fail(t.pos, "unexpected %s, expected a duration", t)
+	}
+	d, err := model.ParseDuration(t.val)
+	if err != nil {
+		p.fail(t.pos, "invalid duration %q: %v", t.val, err)
+	}
+	if positive && d == 0 {
+		p.fail(t.pos, "duration must be greater than 0")
+	}
+	return time.Duration(d)
+}
+
+func (p *parser) parsePrimary() Expr {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		if strings.HasPrefix(t.val, "0x") || strings.HasPrefix(t.val, "0X") {
+			n, err := strconv.ParseInt(t.val, 0, 64)
+			if err != nil {
+				p.fail(t.pos, "invalid number %q", t.val)
+			}
+			return Number(n)
+		}
+		n, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			p.fail(t.pos, "invalid number %q", t.val)
+		}
+		return Number(n)
+	case tokString:
+		return StringLiteral(t.val)
+	case tokOp:
+		switch t.val {
+		case "(":
+			e := p.parseExpr(1)
+			p.expect(")")
+			return ParenExpr{Expr: e}
+		case "{":
+			p.i--
+			return p.selector("", t.pos)
+		}
+	case tokIdent:
+		lower := strings.ToLower(t.val)
+		switch {
+		case lower == "inf":
+			return Number(math.Inf(1))
+		case lower == "nan":
+			return Number(math.NaN())
+		case aggregations[lower] != 0:
+			if next := p.peek(); isOp(next, "(") || isKeyword(next, "by") || isKeyword(next, "without") {
+				return p.aggregation(lower, t.pos)
+			}
+		case keywords[lower]:
+			p.fail(t.pos, "unexpected %s", t)
+		}
+		if isOp(p.peek(), "(") {
+			return p.call(t)
+		}
+		return p.selector(t.val, t.pos)
+	}
+	p.fail(t.pos, "unexpected %s", t)
+	return nil
+}
+
+// aggregations are the number of arguments of the aggregation operators.
+var aggregations = map[string]int{
+	"sum": 1, "avg": 1, "count": 1, "min": 1, "max": 1, "group": 1, "stddev": 1, "stdvar": 1,
+	"topk": 2, "bottomk": 2, "quantile": 2, "count_values": 2, "limitk": 2, "limit_ratio": 2,
+}
+
+func (p *parser) aggregation(op string, pos int) Expr {
+	a := Aggregation{Op: op}
+	grouped := p.grouping(&a)
+	p.expect("(")
+	args := p.args()
+	if want := aggregations[op]; len(args) != want {
+		p.fail(pos, "wrong number of arguments for aggregation %q, expected %d, got %d", op, want, len(args))
+	}
+	if len(args) == 2 {
+		a.Param = args[0]
+	}
+	a.Expr = args[len(args)-1]
+	if t := p.peek(); grouped && (isKeyword(t, "by") || isKeyword(t, "without")) {
+		p.fail(t.pos, "aggregation %q may only be grouped once", op)
+	}
+	p.grouping(&a)
+	return a
+}
+
+// grouping parses an optional by or without clause into a.
+func (p *parser) grouping(a *Aggregation) bool {
+	t := p.peek()
+	if !isKeyword(t, "by") && !isKeyword(t, "without") {
+		return false
+	}
+	p.next()
+	a.Excluding = isKeyword(t, "without")
+	a.Grouping = p.labels()
+	return true
+}
+
+// labels parses a list of label names in parentheses.
+func (p *parser) labels() []string {
+	p.expect("(")
+	labels := []string{}
+	for !isOp(p.peek(), ")") {
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokString || t.kind == tokIdent && strings.Contains(t.val, ":") {
+			p.fail(t.pos, "unexpected %s, expected a label name", t)
+		}
+		labels = append(labels, t.val)
+		if !isOp(p.peek(), ")") {
+			p.expect(",")
+		}
+	}
+	p.next()
+	return labels
+}
+
+// args parses the arguments of a call or aggregation, after "(".
+func (p *parser) args() []Expr {
+	var args []Expr
+	for !isOp(p.peek(), ")") {
+		args = append(args, p.parseExpr(1))
+		if t := p.peek(); !isOp(t, ")") && !isOp(t, ",") {
+			p.fail(t.pos, "unexpected %s, expected \",\" or \")\"", t)
+		}
+		if isOp(p.peek(), ",") {
+			p.next()
+		}
+	}
+	p.next()
+	return args
+}
+
+func (p *parser) call(name token) Expr {
+	sig, ok := functions[name.val]
+	if !ok {
+		p.fail(name.pos, "unknown function with name %q", name.val)
+	}
+	p.expect("(")
+	args := p.args()
+	min, max := len(sig.args)-sig.optional, len(sig.args)
+	if sig.optional < 0 {
+		min, max = len(sig.args), math.MaxInt32
+	}
+	if len(args) < min || len(args) > max {
+		want := strconv.Itoa(min)
+		if max == math.MaxInt32 {
+			want = "at least " + want
+		} else if max != min {
+			want += " to " + strconv.Itoa(max)
+		}
+		p.fail(name.pos, "expected %s argument(s) in call to %q, got %d", want, name.val, len(args))
+	}
+	return Call{Func: name.val, Args: args}
+}
+
+// selector parses the label matchers, if any, of the selector of name.
+func (p *parser) selector(name string, pos int) Expr {
+	s := Selector{Name: name}
+	if !isOp(p.peek(), "{") {
+		return s
+	}
+	p.next()
+	for !isOp(p.peek(), "}") {
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokString {
+			p.fail(t.pos, "unexpected %s, expected a label matcher", t)
+		}
+		m := Matcher{Name: t.val}
+		switch op := p.next(); {
+		case isOp(op, "="):
+			m.Type = MatchEqual
+		case isOp(op, "!="):
+			m.Type = MatchNotEqual
+		case isOp(op, "=~"):
+			m.Type = MatchRegexp
+		case isOp(op, "!~"):
+			m.Type = MatchNotRegexp
+		default:
+			p.fail(op.pos, "unexpected %s, expected a label matching operator", op)
+		}
+		v := p.next()
+		if v.kind != tokString {
+			p.fail(v.pos, "unexpected %s, expected a label value", v)
+		}
+		m.Value = v.val
+		if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+			if _, err := regexp.Compile("^(?:" + m.Value + ")$"); err != nil {
+				p.fail(v.pos, "invalid regular expression %q: %v", m.Value, err)
+			}
+		}
+		if name != "" && m.Name == model.MetricNameLabel {
+			p.fail(t.pos, "metric name must not be set twice: %q", name)
+		}
+		s.Matchers = append(s.Matchers, m)
+		if !isOp(p.peek(), "}") {
+			p.expect(",")
+		}
+	}
+	p.next()
+	return s
+}
@@ -0,0 +1,64 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestExpr_String(t *testing.T) {
+	up := Metric("up", Eq("job", "api"))
+	requests := Metric("http_requests_total", Re("code", "5.."), Neq("path", "/health"))
+	tests := []struct {
+		name string
+		expr Expr
+		want string
+	}{
+		{name: "metric", expr: Metric("up"), want: `up`},
+		{name: "matchers", expr: requests, want: `http_requests_total{code=~"5..", path!="/health"}`},
+		{name: "escaped value", expr: up.Where(Nre("instance", `a"} or vector(1) #`)), want: `up{job="api", instance!~"a\"} or vector(1) #"}`},
+		{name: "escaped names", expr: Metric("up} or vector(1", Eq("my.label", "x")), want: `{__name__="up} or vector(1", "my.label"="x"}`},
+		{name: "matchers only", expr: Metric("", Eq("job", "api")), want: `{job="api"}`},
+		{name: "offset", expr: up.OffsetBy(time.Hour), want: `up{job="api"} offset 1h`},
+		{name: "negative offset", expr: up.OffsetBy(-90 * time.Second), want: `up{job="api"} offset -1m30s`},
+		{name: "range", expr: requests.Range(5 * time.Minute).OffsetBy(24 * time.Hour), want: `http_requests_total{code=~"5..", path!="/health"}[5m] offset 1d`},
+		{name: "rate", expr: Rate(Metric("http_requests_total").Range(5 * time.Minute)), want: `rate(http_requests_total[5m])`},
+		{name: "histogram quantile", expr: HistogramQuantile(0.99, Sum(Rate(Metric("latency_seconds_bucket").Range(time.Minute))).By("le", "job")), want: `histogram_quantile(0.99, sum by (le, job) (rate(latency_seconds_bucket[1m])))`},
+		{name: "without", expr: Max(up).Without("instance"), want: `max without (instance) (up{job="api"})`},
+		{name: "topk", expr: TopK(5, Metric("node_load1")).By("instance"), want: `topk by (instance) (5, node_load1)`},
+		{name: "count values", expr: CountValues("version", Metric("build_info")), want: `count_values ("version", build_info)`},
+		{name: "function", expr: Func("clamp_min", Metric("free"), Number(0)), want: `clamp_min(free, 0)`},
+		{name: "numbers", expr: Binary(Number(math.Inf(1)), OpSub, Number(-1.5)), want: `Inf - -1.5`},
+		{
+			name: "binary precedence",
+			expr: Binary(Binary(Metric("a"), OpAdd, Metric("b")), OpMul, Number(2)),
+			want: `(a + b) * 2`,
+		},
+		{
+			name: "comparison bool",
+			expr: Binary(Sum(Metric("up")).By("job"), OpLt, Number(1)).Bool(),
+			want: `sum by (job) (up) < bool 1`,
+		},
+		{
+			name: "vector matching",
+			expr: Binary(Rate(Metric("errors_total").Range(5*time.Minute)), OpDiv, Metric("build_info")).Ignoring("version").GroupLeft("version"),
+			want: `rate(errors_total[5m]) / ignoring(version) group_left(version) build_info`,
+		},
+		{
+			name: "on group right",
+			expr: Binary(Metric("a"), OpAnd, Metric("b")).On("instance").GroupRight(),
+			want: `a and on(instance) group_right b`,
+		},
+		{name: "subquery", expr: SubqueryOf(Sum(Metric("up")), time.Hour, time.Minute), want: `(sum (up))[1h:1m]`},
+		{name: "subquery default step", expr: Subquery{Expr: Metric("up"), Range: time.Hour, At: "end()"}, want: `up[1h:] @ end()`},
+		{name: "unary", expr: UnaryExpr{Op: OpSub, Expr: Binary(Metric("a"), OpPow, Number(2))}, want: `-(a ^ 2)`},
+		{name: "paren", expr: Binary(ParenExpr{Expr: Metric("a")}, OpAtan2, Metric("b")), want: `(a) atan2 b`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.expr.String(); got != tt.want {
+				t.Errorf("String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"net/http"
+	"sort"
+)
+
+// Pushgateway pushes metrics to a group of a Prometheus Pushgateway.
+type Pushgateway struct {
+	URL string // address of the Pushgateway, http:// is added when missing
+	// Job is the job label of the group, defaults to the Job
+	// of the metrics, or else the Name of the first metrics.
+	Job string
+	// Grouping are the other labels of the group, merged
+	// over the Grouping of the metrics.
+	Grouping map[string]string
+	// Username and Password set basic auth,
+	// BearerToken sets token auth.
+	Username    string
+	Password    string
+	BearerToken string
+	// Client sends the requests, defaults to http.DefaultClient.
+	Client *http.Client
+	// MaxSeries limits the series of each metrics,
+	// defaults to DefaultMaxSeries.
+	MaxSeries int
+}
+
+// Push replaces all metrics of the group with metrics, using PUT.
+// All metrics are validated by ValidateMetrics and sent in one request,
+// so either all or none of them are pushed. Metrics of different Job
+// or Grouping are an error, as they belong to different groups.
+func (g *Pushgateway) Push(ctx context.Context, metrics ...PushMetrics) error {
+	p, err := g.pusher(ctx, metrics)
+	if err != nil {
+		return err
+	}
+	return p.PushContext(ctx)
+}
+
+// PushAdd replaces only the metrics of the group with the same names
+// as metrics, using POST.
+func (g *Pushgateway) PushAdd(ctx context.Context, metrics ...PushMetrics) error {
+	p, err := g.pusher(ctx, metrics)
+	if err != nil {
+		return err
+	}
+	return p.AddContext(ctx)
+}
+
+// Delete deletes all metrics of the group.
+func (g *Pushgateway) Delete(ctx context.Context) error {
+	p, err := g.pusher(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return p.Delete()
+}
+
+// pusher implements a new push.Pusher of the group holding metrics.
+func (g *Pushgateway) pusher(ctx context.Context, metrics []PushMetrics) (*push.Pusher, error) {
+	if err := ValidateMetrics(metrics, g.MaxSeries); err != nil {
+		return nil, err
+	}
+	job, grouping := g.Job, map[string]string{}
+	if len(metrics) > 0 {
+		first, err := sameGroup(metrics)
+		if err != nil {
+			return nil, err
+		}
+		if job == "" {
+			job = first.Job
+		}
+		if job == "" {
+			job = metrics[0].Name
+		}
+		for k, v := range first.Grouping {
+			grouping[k] = v
+		}
+	}
+	if job == "" {
+		return nil, errors.New("pushgateway job is required")
+	}
+	for k, v := range g.Grouping {
+		grouping[k] = v
+	}
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	p := push.New(g.URL, job).Client(&authDoer{ctx: ctx, client: client, token: g.BearerToken})
+	if g.Username != "" {
+		p.BasicAuth(g.Username, g.Password)
+	}
+	names := make([]string, 0, len(grouping))
+	for k := range grouping {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		p.Grouping(k, grouping[k])
+	}
+	for _, pm := range metrics {
+		c, err := pm.Collector()
+		if err != nil {
+			return nil, err
+		}
+		p.Collector(c)
+	}
+	return p, p.Error()
+}
+
+// sameGroup returns the metrics setting the Job, or else the first
+// ones, and an error if metrics set different Job or Grouping.
+func sameGroup(metrics []PushMetrics) (PushMetrics, error) {
+	first := metrics[0]
+	for _, pm := range metrics[1:] {
+		if first.Job == "" {
+			first.Job = pm.Job
+		}
+		if pm.Job != "" && pm.Job != first.Job {
+			return PushMetrics{}, fmt.Errorf("metrics %s of job %s pushed with job %s", pm.Name, pm.Job, first.Job)
+		}
+		if !sameLabels(pm.Grouping, metrics[0].Grouping) {
+			return PushMetrics{}, fmt.Errorf("metrics %s of grouping %v pushed with grouping %v", pm.Name, pm.Grouping, metrics[0].Grouping)
+		}
+	}
+	return first, nil
+}
+
+// sameLabels reports whether a and b hold the same labels.
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// authDoer sends the requests of a push.Pusher with ctx
+// and the bearer token, if any.
+type authDoer struct {
+	ctx    context.Context
+	client *http.Client
+	token  string
+}
+
+func (d *authDoer) Do(req *http.Request) (*http.Response, error) {
+	if d.ctx != nil {
+		req = req.WithContext(d.ctx)
+	}
+	if d.token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.token)
+	}
+	return d.client.Do(req)
+}
@@ -0,0 +1,125 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushgateway(t *testing.T) {
+	var method, path, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		if strings.HasPrefix(r.URL.Path, "/metrics/job/broken/") {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	metrics := PushMetrics{
+		Name:     "backup_success",
+		Metrics:  []PromMetrics{{Data: 1}},
+		Job:      "backup",
+		Grouping: map[string]string{"instance": "db1"},
+	}
+	tests := []struct {
+		name       string
+		g          Pushgateway
+		op         func(g *Pushgateway) error
+		wantMethod string
+		wantPath   string
+		wantAuth   string
+		wantErr    bool
+	}{
+		{
+			name:       "push",
+			g:          Pushgateway{URL: srv.URL},
+			op:         func(g *Pushgateway) error { return g.Push(context.Background(), metrics) },
+			wantMethod: http.MethodPut,
+			wantPath:   "/metrics/job/backup/instance/db1",
+		},
+		{
+			name:       "push add with grouping",
+			g:          Pushgateway{URL: srv.URL, Job: "nightly", Grouping: map[string]string{"instance": "db2"}},
+			op:         func(g *Pushgateway) error { return g.PushAdd(context.Background(), metrics) },
+			wantMethod: http.MethodPost,
+			wantPath:   "/metrics/job/nightly/instance/db2",
+		},
+		{
+			name: "job defaults to name",
+			g:    Pushgateway{URL: srv.URL, Username: "ops", Password: "secret"},
+			op: func(g *Pushgateway) error {
+				return g.Push(context.Background(), PushMetrics{Name: "up", Metrics: []PromMetrics{{Data: 1}}})
+			},
+			wantMethod: http.MethodPut,
+			wantPath:   "/metrics/job/up",
+			wantAuth:   "Basic b3BzOnNlY3JldA==",
+		},
+		{
+			name:       "delete",
+			g:          Pushgateway{URL: srv.URL, Job: "backup", BearerToken: "t0ken"},
+			op:         func(g *Pushgateway) error { return g.Delete(context.Background()) },
+			wantMethod: http.MethodDelete,
+			wantPath:   "/metrics/job/backup",
+			wantAuth:   "Bearer t0ken",
+		},
+		{
+			name:    "delete without job",
+			g:       Pushgateway{URL: srv.URL},
+			op:      func(g *Pushgateway) error { return g.Delete(context.Background()) },
+			wantErr: true,
+		},
+		{
+			name: "job of later metrics",
+			g:    Pushgateway{URL: srv.URL},
+			op: func(g *Pushgateway) error {
+				return g.Push(context.Background(), PushMetrics{Name: "up", Metrics: []PromMetrics{{Data: 1}}, Grouping: metrics.Grouping}, metrics)
+			},
+			wantMethod: http.MethodPut,
+			wantPath:   "/metrics/job/backup/instance/db1",
+		},
+		{
+			name: "different jobs",
+			g:    Pushgateway{URL: srv.URL},
+			op: func(g *Pushgateway) error {
+				other := metrics
+				other.Name, other.Job = "restore_success", "restore"
+				return g.Push(context.Background(), metrics, other)
+			},
+			wantErr: true,
+		},
+		{
+			name: "different groupings",
+			g:    Pushgateway{URL: srv.URL, Job: "nightly"},
+			op: func(g *Pushgateway) error {
+				other := metrics
+				other.Name, other.Grouping = "backup_size_bytes", map[string]string{"instance": "db2"}
+				return g.PushAdd(context.Background(), metrics, other)
+			},
+			wantErr: true,
+		},
+		{
+			name:       "server error",
+			g:          Pushgateway{URL: srv.URL, Job: "broken"},
+			op:         func(g *Pushgateway) error { return g.Push(context.Background(), metrics) },
+			wantMethod: http.MethodPut,
+			wantPath:   "/metrics/job/broken/instance/db1",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, path, auth = "", "", ""
+			if err := tt.op(&tt.g); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if method != tt.wantMethod || path != tt.wantPath || auth != tt.wantAuth {
+				t.Errorf("request = %s %s %q, want %s %s %q", method, path, auth, tt.wantMethod, tt.wantPath, tt.wantAuth)
+			}
+		})
+	}
+}
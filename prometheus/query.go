@@ -0,0 +1,208 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryOptions are the options of a query, unset options
+// use the defaults of the Prometheus server.
+type QueryOptions struct {
+	Timeout       time.Duration // evaluation timeout
+	Stats         bool          // return the execution stats of the query
+	LookbackDelta time.Duration // how far back to look for samples of instant vectors
+	Limit         int           // maximum number of series returned
+}
+
+// QueryResult is the result of a query with its warnings and stats.
+type QueryResult struct {
+	Values   ResultValues
+	Warnings []string    // set when the result may be partial
+	Stats    *QueryStats // set when QueryOptions.Stats is set
+}
+
+// QueryStats are the execution stats of a query.
+type QueryStats struct {
+	Timings QueryTimings
+	Samples QuerySamples
+}
+
+// QueryTimings are the time spent executing a query.
+type QueryTimings struct {
+	EvalTotal        time.Duration
+	ResultSort       time.Duration
+	QueryPreparation time.Duration
+	InnerEval        time.Duration
+	ExecQueue        time.Duration
+	ExecTotal        time.Duration
+}
+
+// QuerySamples are the samples loaded executing a query.
+type QuerySamples struct {
+	TotalQueryableSamples int64 `json:"totalQueryableSamples"`
+	PeakSamples           int64 `json:"peakSamples"`
+}
+
+// QueryWithOptions implements prometheus instant query at ts, or
+// at the time of the server if ts is zero.
+func (p *Prometheus) QueryWithOptions(ctx context.Context, client api.Client, query string, ts time.Time, opts QueryOptions) (*QueryResult, error) {
+	args := opts.values(query)
+	if !ts.IsZero() {
+		args.Set("time", formatTime(ts))
+	}
+	return doQuery(ctx, client, "/api/v1/query", args)
+}
+
+// QueryRangeWithOptions implements prometheus range query.
+func (p *Prometheus) QueryRangeWithOptions(ctx context.Context, client api.Client, query string, r v1.Range, opts QueryOptions) (*QueryResult, error) {
+	args := opts.values(query)
+	args.Set("start", formatTime(r.Start))
+	args.Set("end", formatTime(r.End))
+	args.Set("step", formatSeconds(r.Step))
+	return doQuery(ctx, client, "/api/v1/query_range", args)
+}
+
+func (o QueryOptions) values(query string) url.Values {
+	args := url.Values{"query": {query}}
+	if o.Timeout > 0 {
+		args.Set("timeout", formatSeconds(o.Timeout))
+	}
+	if o.Stats {
+		args.Set("stats", "all")
+	}
+	if o.LookbackDelta > 0 {
+		args.Set("lookback_delta", formatSeconds(o.LookbackDelta))
+	}
+	if o.Limit > 0 {
+		args.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return args
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
+
+// formatSeconds formats d in seconds, which every Prometheus version
+// accepts, unlike fractional or compound durations such as 1m30s.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// queryResponse is the response of the query APIs.
+type queryResponse struct {
+	Status    string   `json:"status"`
+	ErrorType string   `json:"errorType"`
+	Error     string   `json:"error"`
+	Warnings  []string `json:"warnings"`
+	Data      struct {
+		ResultType model.ValueType `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+		Stats      *struct {
+			Timings map[string]float64 `json:"timings"`
+			Samples QuerySamples       `json:"samples"`
+		} `json:"stats"`
+	} `json:"data"`
+}
+
+// doQuery posts args to the query endpoint ep, falling back to GET
+// if the server does not allow POST, and decodes the response.
+func doQuery(ctx context.Context, client api.Client, ep string, args url.Values) (*QueryResult, error) {
+	u := client.URL(ep, nil)
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(args.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, body, err := client.Do(ctx, req)
+	if resp != nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		u.RawQuery = args.Encode()
+		if req, err = http.NewRequest(http.MethodGet, u.String(), nil); err != nil {
+			return nil, err
+		}
+		resp, body, err = client.Do(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var qr queryResponse
+	if err := json.Unmarshal(body, &qr); err != nil {
+		return nil, fmt.Errorf("bad response %s: %w", resp.Status, err)
+	}
+	if qr.Status != "success" {
+		return nil, &v1.Error{Type: v1.ErrorType(qr.ErrorType), Msg: qr.Error}
+	}
+	value, err := decodeValue(qr.Data.ResultType, qr.Data.Result)
+	if err != nil {
+		return nil, err
+	}
+	values, err := convertValue(value)
+	if err != nil {
+		return nil, err
+	}
+	res := &QueryResult{Values: values, Warnings: qr.Warnings}
+	if s := qr.Data.Stats; s != nil {
+		res.Stats = &QueryStats{
+			Timings: QueryTimings{
+				EvalTotal:        seconds(s.Timings["evalTotalTime"]),
+				ResultSort:       seconds(s.Timings["resultSortTime"]),
+				QueryPreparation: seconds(s.Timings["queryPreparationTime"]),
+				InnerEval:        seconds(s.Timings["innerEvalTime"]),
+				ExecQueue:        seconds(s.Timings["execQueueTime"]),
+				ExecTotal:        seconds(s.Timings["execTotalTime"]),
+			},
+			Samples: s.Samples,
+		}
+	}
+	return res, nil
+}
+
+// decodeValue decodes the result of a query of type t.
+func decodeValue(t model.ValueType, result json.RawMessage) (model.Value, error) {
+	var v model.Value
+	switch t {
+	case model.ValScalar:
+		v = &model.Scalar{}
+	case model.ValVector:
+		v = &model.Vector{}
+	case model.ValMatrix:
+		v = &model.Matrix{}
+	case model.ValString:
+		v = &model.String{}
+	default:
+		return nil, fmt.Errorf("unexpected result type %q", t)
+	}
+	if err := json.Unmarshal(result, v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case *model.Vector:
+		return *v, nil
+	case *model.Matrix:
+		return *v, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,84 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPrometheus_QueryWithOptions(t *testing.T) {
+	var method, args string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("get") != "" && r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		_ = r.ParseForm()
+		method, args = r.Method, r.Form.Encode()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Form.Get("query") {
+		case "bad(":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"unexpected end of input"}`))
+		case "up[5m]":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"api"},"values":[[1,"1"],[2,"0"]]}]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","warnings":["partial response"],"data":{"resultType":"vector","result":[{"metric":{"job":"api"},"value":[1,"1"]}],` +
+				`"stats":{"timings":{"evalTotalTime":0.5,"execTotalTime":1.5},"samples":{"totalQueryableSamples":10,"peakSamples":4}}}}`))
+		}
+	}))
+	defer srv.Close()
+	client, err := api.NewClient(api.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get, err := api.NewClient(api.Config{Address: srv.URL + "/?get=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Prometheus{}
+	ctx := context.Background()
+
+	res, err := p.QueryWithOptions(ctx, client, "up", time.Unix(2, 0), QueryOptions{Timeout: 1500 * time.Millisecond, Stats: true, LookbackDelta: 90 * time.Second, Limit: 5})
+	if err != nil {
+		t.Fatalf("QueryWithOptions() error = %v", err)
+	}
+	if want := "limit=5&lookback_delta=90&query=up&stats=all&time=2&timeout=1.5"; method != http.MethodPost || args != want {
+		t.Errorf("request = %s %s, want POST %s", method, args, want)
+	}
+	want := &QueryResult{
+		Values:   Vector{{Metric: `{job="api"}`, Labels: Labels{"job": "api"}, Values: MetricValues{Timestamp: 1000, Value: 1}}},
+		Warnings: []string{"partial response"},
+		Stats: &QueryStats{
+			Timings: QueryTimings{EvalTotal: 500 * time.Millisecond, ExecTotal: 1500 * time.Millisecond},
+			Samples: QuerySamples{TotalQueryableSamples: 10, PeakSamples: 4},
+		},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("QueryWithOptions() = %+v, want %+v", res, want)
+	}
+
+	r := v1.Range{Start: time.Unix(1, 0), End: time.Unix(2, 0), Step: time.Second}
+	res, err = p.QueryRangeWithOptions(ctx, get, "up[5m]", r, QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryRangeWithOptions() error = %v", err)
+	}
+	if want := "end=2&query=up%5B5m%5D&start=1&step=1"; method != http.MethodGet || args != want {
+		t.Errorf("request = %s %s, want GET %s", method, args, want)
+	}
+	if m, ok := res.Values.(Matrix); !ok || len(m) != 1 || len(m[0].Values) != 2 || res.Stats != nil {
+		t.Errorf("QueryRangeWithOptions() = %+v", res)
+	}
+
+	_, err = p.QueryWithOptions(ctx, client, "bad(", time.Time{}, QueryOptions{})
+	var apiErr *v1.Error
+	if !errors.As(err, &apiErr) || apiErr.Type != v1.ErrBadData {
+		t.Errorf("QueryWithOptions() error = %v, want bad_data", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxFrameSize is the maximum size of a frame of a streamed response.
+const maxFrameSize = 50 << 20
+
+// RemoteReader reads raw samples from a Prometheus remote_read
+// endpoint, such as /api/v1/read of Prometheus.
+type RemoteReader struct {
+	URL string // URL of the remote_read endpoint
+	// Headers are added to the requests, such as X-Scope-OrgID of Mimir.
+	Headers map[string]string
+	// Username and Password set basic auth,
+	// BearerToken sets token auth.
+	Username    string
+	Password    string
+	BearerToken string
+	// Client sends the requests, defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Read returns the raw samples between start and end of the series
+// matching all matchers, sorted by labels. Streamed chunked responses
+// are preferred and decoded as they are read, servers not supporting
+// them return all samples at once.
+func (r *RemoteReader) Read(ctx context.Context, start, end time.Time, matchers ...Matcher) (Matrix, error) {
+	if len(matchers) == 0 {
+		return nil, errors.New("remote read requires at least one matcher")
+	}
+	startMs, endMs := start.UnixNano()/int64(time.Millisecond), end.UnixNano()/int64(time.Millisecond)
+	body := snappy.Encode(nil, marshalReadRequest(startMs, endMs, matchers))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "go-devops")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	if r.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.BearerToken)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote read from %s: %w", r.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("remote read from %s: server returned %s: %s", r.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	m := &matrixBuilder{start: startMs, end: endMs, series: map[string]int{}}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-streamed-protobuf") {
+		err = readFrames(resp.Body, func(frame []byte) error {
+			series, err := unmarshalChunkedReadResponse(frame)
+			m.add(series)
+			return err
+		})
+	} else {
+		var b []byte
+		if b, err = io.ReadAll(resp.Body); err == nil {
+			if b, err = snappy.Decode(nil, b); err == nil {
+				var series []Series
+				series, err = unmarshalReadResponse(b)
+				m.add(series)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("remote read from %s: %w", r.URL, err)
+	}
+	sort.Slice(m.matrix, func(i, j int) bool { return m.matrix[i].Metric < m.matrix[j].Metric })
+	return m.matrix, nil
+}
+
+// readFrames calls fn with the data of each frame of a streamed
+// response: its size as an uvarint, its CRC32 Castagnoli checksum
+// as a big endian uint32, then the data.
+func readFrames(r io.Reader, fn func(frame []byte) error) error {
+	br := bufio.NewReader(r)
+	table := crc32.MakeTable(crc32.Castagnoli)
+	var buf []byte
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if size > maxFrameSize {
+			return fmt.Errorf("frame of %d bytes exceeds %d bytes", size, maxFrameSize)
+		}
+		var crc [4]byte
+		if _, err := io.ReadFull(br, crc[:]); err != nil {
+			return err
+		}
+		if uint64(cap(buf)) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return err
+		}
+		if crc32.Checksum(buf, table) != binary.BigEndian.Uint32(crc[:]) {
+			return errors.New("frame checksum mismatch")
+		}
+		if err := fn(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// matrixBuilder merges series into a Matrix, keeping
+// the samples between start and end.
+type matrixBuilder struct {
+	start, end int64
+	matrix     Matrix
+	series     map[string]int // index in matrix by labels
+}
+
+func (m *matrixBuilder) add(series []Series) {
+	for _, s := range series {
+		key := s.Labels.String()
+		i, ok := m.series[key]
+		if !ok {
+			i = len(m.matrix)
+			m.series[key] = i
+			m.matrix = append(m.matrix, MatrixResult{Metric: key, Labels: s.Labels})
+		}
+		for _, v := range s.Samples {
+			if v.Timestamp >= m.start && v.Timestamp <= m.end {
+				m.matrix[i].Values = append(m.matrix[i].Values, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// bitWriter writes a stream of bits, most significant bit first.
+type bitWriter struct {
+	b     []byte
+	count uint8 // free bits in the last byte
+}
+
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.count == 0 {
+			w.b = append(w.b, 0)
+			w.count = 8
+		}
+		w.count--
+		w.b[len(w.b)-1] |= byte(v>>uint(i)&1) << w.count
+	}
+}
+
+func (w *bitWriter) writeBytes(b []byte) {
+	for _, c := range b {
+		w.writeBits(uint64(c), 8)
+	}
+}
+
+// encodeXORChunk encodes samples like tsdb/chunkenc.XORChunk.
+func encodeXORChunk(samples []MetricValues) []byte {
+	w := &bitWriter{}
+	var (
+		t, tDelta         int64
+		value             uint64
+		leading, trailing uint8 = 0xff, 0
+	)
+	for i, s := range samples {
+		v := math.Float64bits(s.Value)
+		switch i {
+		case 0:
+			buf := make([]byte, binary.MaxVarintLen64)
+			w.writeBytes(buf[:binary.PutVarint(buf, s.Timestamp)])
+			w.writeBits(v, 64)
+			t, value = s.Timestamp, v
+			continue
+		case 1:
+			tDelta = s.Timestamp - t
+			buf := make([]byte, binary.MaxVarintLen64)
+			w.writeBytes(buf[:binary.PutUvarint(buf, uint64(tDelta))])
+		default:
+			d := s.Timestamp - t
+			dod := d - tDelta
+			inRange := func(nbits uint) bool { return -(1<<(nbits-1))+1 <= dod && dod <= 1<<(nbits-1) }
+			switch {
+			case dod == 0:
+				w.writeBits(0, 1)
+			case inRange(14):
+				w.writeBits(0b10, 2)
+				w.writeBits(uint64(dod), 14)
+			case inRange(17):
+				w.writeBits(0b110, 3)
+				w.writeBits(uint64(dod), 17)
+			case inRange(20):
+				w.writeBits(0b1110, 4)
+				w.writeBits(uint64(dod), 20)
+			default:
+				w.writeBits(0b1111, 4)
+				w.writeBits(uint64(dod), 64)
+			}
+			tDelta = d
+		}
+		t = s.Timestamp
+
+		delta := v ^ value
+		value = v
+		if delta == 0 {
+			w.writeBits(0, 1)
+			continue
+		}
+		w.writeBits(1, 1)
+		newLeading, newTrailing := uint8(bits.LeadingZeros64(delta)), uint8(bits.TrailingZeros64(delta))
+		if newLeading >= 32 {
+			newLeading = 31
+		}
+		if leading != 0xff && newLeading >= leading && newTrailing >= trailing {
+			w.writeBits(0, 1)
+			w.writeBits(delta>>trailing, 64-int(leading)-int(trailing))
+			continue
+		}
+		leading, trailing = newLeading, newTrailing
+		w.writeBits(1, 1)
+		w.writeBits(uint64(leading), 5)
+		sigbits := 64 - leading - trailing
+		w.writeBits(uint64(sigbits), 6)
+		w.writeBits(delta>>trailing, int(sigbits))
+	}
+	chunk := make([]byte, 2, 2+len(w.b))
+	binary.BigEndian.PutUint16(chunk, uint16(len(samples)))
+	return append(chunk, w.b...)
+}
+
+func TestDecodeXORChunk(t *testing.T) {
+	samples := []MetricValues{
+		{Timestamp: -5, Value: 1},
+		{Timestamp: 10000, Value: 1},
+		{Timestamp: 20000, Value: 1.5},
+		{Timestamp: 30001, Value: -2},
+		{Timestamp: 30002, Value: math.Inf(1)},
+		{Timestamp: 130002, Value: 1e300},
+		{Timestamp: 1130002, Value: 1e300},
+		{Timestamp: 1 << 40, Value: 0.1},
+		{Timestamp: 1<<40 + 15000, Value: 0.2},
+	}
+	for n := 0; n <= len(samples); n++ {
+		got, err := decodeXORChunk(encodeXORChunk(samples[:n]))
+		if err != nil {
+			t.Fatalf("decodeXORChunk(%d samples) error = %v", n, err)
+		}
+		if !reflect.DeepEqual(got, samples[:n]) {
+			t.Errorf("decodeXORChunk() = %v, want %v", got, samples[:n])
+		}
+	}
+	if _, err := decodeXORChunk(encodeXORChunk(samples)[:10]); err == nil {
+		t.Errorf("decodeXORChunk(truncated) error = nil")
+	}
+}
+
+// chunkedSeries returns a ChunkedSeries message of s with one XOR chunk.
+func chunkedSeries(s Series) []byte {
+	var b []byte
+	for _, l := range s.Labels.List() {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, l.Label)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, l.Value)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, label)
+	}
+	var chunk []byte
+	chunk = protowire.AppendTag(chunk, 3, protowire.VarintType)
+	chunk = protowire.AppendVarint(chunk, chunkXOR)
+	chunk = protowire.AppendTag(chunk, 4, protowire.BytesType)
+	chunk = protowire.AppendBytes(chunk, encodeXORChunk(s.Samples))
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	return protowire.AppendBytes(b, chunk)
+}
+
+func TestRemoteReader_Read(t *testing.T) {
+	api := Labels{"__name__": "up", "job": "api"}
+	db := Labels{"__name__": "up", "job": "db"}
+	// the chunks of api span two frames, samples before start are dropped
+	frames := [][]Series{
+		{{Labels: db, Samples: []MetricValues{{Timestamp: 1000, Value: 1}}}, {Labels: api, Samples: []MetricValues{{Timestamp: 500, Value: 0}, {Timestamp: 1000, Value: 1}}}},
+		{{Labels: api, Samples: []MetricValues{{Timestamp: 2000, Value: 0}, {Timestamp: 3000, Value: 1}}}},
+	}
+	want := Matrix{
+		{Metric: api.String(), Labels: api, Values: []MetricValues{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 0}, {Timestamp: 3000, Value: 1}}},
+		{Metric: db.String(), Labels: db, Values: []MetricValues{{Timestamp: 1000, Value: 1}}},
+	}
+
+	tests := []struct {
+		name     string
+		streamed bool
+		corrupt  bool
+		status   int
+		wantErr  bool
+	}{
+		{name: "streamed", streamed: true},
+		{name: "samples"},
+		{name: "bad checksum", streamed: true, corrupt: true, wantErr: true},
+		{name: "server error", status: http.StatusBadRequest, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				req, err := snappy.Decode(nil, body)
+				if err != nil || !reflect.DeepEqual(req, marshalReadRequest(1000, 3000, []Matcher{{Name: "__name__", Value: "up"}, {Type: MatchRegexp, Name: "job", Value: "api|db"}})) {
+					t.Errorf("request = %x, error %v", req, err)
+				}
+				if tt.status != 0 {
+					http.Error(w, "bad request", tt.status)
+					return
+				}
+				if !tt.streamed {
+					var res []byte
+					for _, frame := range frames {
+						for _, s := range frame {
+							res = protowire.AppendTag(res, 1, protowire.BytesType)
+							res = protowire.AppendBytes(res, appendTimeSeries(nil, s))
+						}
+					}
+					w.Header().Set("Content-Type", "application/x-protobuf")
+					_, _ = w.Write(snappy.Encode(nil, protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), res)))
+					return
+				}
+				w.Header().Set("Content-Type", "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse")
+				for _, frame := range frames {
+					var msg []byte
+					for _, s := range frame {
+						msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+						msg = protowire.AppendBytes(msg, chunkedSeries(s))
+					}
+					crc := crc32.Checksum(msg, crc32.MakeTable(crc32.Castagnoli))
+					if tt.corrupt {
+						crc++
+					}
+					header := make([]byte, binary.MaxVarintLen64+4)
+					n := binary.PutUvarint(header, uint64(len(msg)))
+					binary.BigEndian.PutUint32(header[n:], crc)
+					_, _ = w.Write(header[:n+4])
+					_, _ = w.Write(msg)
+				}
+			}))
+			defer srv.Close()
+
+			r := &RemoteReader{URL: srv.URL}
+			got, err := r.Read(context.Background(), time.Unix(1, 0), time.Unix(3, 0),
+				Matcher{Name: "__name__", Value: "up"}, Matcher{Type: MatchRegexp, Name: "job", Value: "api|db"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, want) {
+				t.Errorf("Read() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults of RemoteWriter.
+const (
+	DefaultShards            = 4
+	DefaultMaxSamplesPerSend = 2000
+	DefaultMaxRetries        = 3
+	DefaultMinBackoff        = 30 * time.Millisecond
+	DefaultMaxBackoff        = 5 * time.Second
+)
+
+// RemoteWriter writes series to a Prometheus remote_write endpoint,
+// such as /api/v1/write of Prometheus, /api/v1/push of Mimir, /api/v1/receive
+// of Thanos Receive or /api/v1/write of VictoriaMetrics.
+type RemoteWriter struct {
+	URL string // URL of the remote_write endpoint
+	// Headers are added to the requests, such as X-Scope-OrgID
+	// of Mimir or THANOS-TENANT of Thanos Receive.
+	Headers map[string]string
+	// Username and Password set basic auth,
+	// BearerToken sets token auth.
+	Username    string
+	Password    string
+	BearerToken string
+	// Client sends the requests, defaults to http.DefaultClient.
+	Client *http.Client
+	// Shards is the number of concurrent requests, defaults to DefaultShards.
+	// All samples of a series are sent in order by the same shard.
+	Shards int
+	// MaxSamplesPerSend is the maximum number of samples of a request,
+	// defaults to DefaultMaxSamplesPerSend.
+	MaxSamplesPerSend int
+	// MaxRetries is the number of retries of requests failing with
+	// a network error, 429 or 5xx, defaults to DefaultMaxRetries.
+	// A negative MaxRetries disables retries.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the wait between retries, doubled
+	// after each retry, default to DefaultMinBackoff and DefaultMaxBackoff.
+	// A Retry-After header of the response overrides it.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Write writes series, with samples sorted by timestamp, to the
+// remote_write endpoint, in batches of MaxSamplesPerSend samples
+// sent by Shards concurrent requests. It returns the first error
+// and cancels the other requests.
+func (w *RemoteWriter) Write(ctx context.Context, series ...Series) error {
+	for _, s := range series {
+		if s.Labels.Name() == "" {
+			return fmt.Errorf("remote write series %v has no metric name", s.Labels)
+		}
+		for name := range s.Labels {
+			if !model.LabelName(name).IsValid() {
+				return fmt.Errorf("remote write series %v has invalid label name %q", s.Labels, name)
+			}
+		}
+	}
+
+	shards := w.Shards
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	sharded := make([][]Series, shards)
+	for _, s := range series {
+		h := fnv.New64a()
+		for _, l := range s.Labels.List() {
+			_, _ = h.Write([]byte(l.Label + "\xff" + l.Value + "\xff"))
+		}
+		i := h.Sum64() % uint64(shards)
+		sharded[i] = append(sharded[i], s)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, shard := range sharded {
+		if len(shard) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(shard []Series) {
+			defer wg.Done()
+			for _, batch := range w.batches(shard) {
+				if err := w.send(ctx, batch); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}(shard)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// batches splits series into batches of at most MaxSamplesPerSend samples.
+func (w *RemoteWriter) batches(series []Series) [][]Series {
+	max := w.MaxSamplesPerSend
+	if max <= 0 {
+		max = DefaultMaxSamplesPerSend
+	}
+	var (
+		batches [][]Series
+		batch   []Series
+		n       int
+	)
+	for _, s := range series {
+		samples := s.Samples
+		for len(samples) > 0 {
+			size := max - n
+			if size > len(samples) {
+				size = len(samples)
+			}
+			batch = append(batch, Series{Labels: s.Labels, Samples: samples[:size]})
+			samples = samples[size:]
+			if n += size; n == max {
+				batches = append(batches, batch)
+				batch, n = nil, 0
+			}
+		}
+	}
+	if n > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// send sends a batch, retrying recoverable errors with backoff.
+func (w *RemoteWriter) send(ctx context.Context, batch []Series) error {
+	body := snappy.Encode(nil, marshalWriteRequest(batch))
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	retries := w.MaxRetries
+	if retries == 0 {
+		retries = DefaultMaxRetries
+	}
+	backoff, maxBackoff := w.MinBackoff, w.MaxBackoff
+	if backoff <= 0 {
+		backoff = DefaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		wait, err := w.post(ctx, client, body)
+		if err == nil {
+			return nil
+		}
+		if wait < 0 || attempt >= retries || ctx.Err() != nil {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// post posts body once. If it fails, wait is negative when the error
+// is not recoverable, or else the Retry-After of the response, if any.
+func (w *RemoteWriter) post(ctx context.Context, client *http.Client, body []byte) (wait time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "go-devops")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.Username != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+	if w.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.BearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("remote write to %s: %w", w.URL, err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 == 2 {
+		return 0, nil
+	}
+	err = fmt.Errorf("remote write to %s: server returned %s: %s", w.URL, resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode/100 != 5 {
+		return -1, err
+	}
+	if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s >= 0 {
+		wait = time.Duration(s) * time.Second
+	}
+	return wait, err
+}
+
+// DecodeWriteRequest decodes the series of the snappy-compressed
+// body of a remote_write request, to implement test receivers.
+func DecodeWriteRequest(body []byte) ([]Series, error) {
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalWriteRequest(b)
+}
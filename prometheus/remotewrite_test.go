@@ -0,0 +1,110 @@
+package prometheus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRemoteWriter_Write(t *testing.T) {
+	series := []Series{
+		{Labels: Labels{"__name__": "up", "job": "api"}, Samples: []MetricValues{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 0}, {Timestamp: 3000, Value: 1}}},
+		{Labels: Labels{"__name__": "up", "job": "db"}, Samples: []MetricValues{{Timestamp: 1000, Value: 1}}},
+	}
+	tests := []struct {
+		name         string
+		w            RemoteWriter
+		statuses     []int // statuses of the first responses, then 204
+		series       []Series
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "one request", w: RemoteWriter{Shards: 1}, series: series, wantRequests: 1},
+		{name: "batches", w: RemoteWriter{Shards: 1, MaxSamplesPerSend: 2}, series: series, wantRequests: 2},
+		{name: "shards", w: RemoteWriter{Shards: 16}, series: series, wantRequests: 2},
+		{name: "retry", w: RemoteWriter{Shards: 1, MinBackoff: time.Millisecond}, statuses: []int{503, 429}, series: series, wantRequests: 3},
+		{name: "retries exhausted", w: RemoteWriter{Shards: 1, MaxRetries: 1, MinBackoff: time.Millisecond}, statuses: []int{500, 500}, series: series, wantRequests: 2, wantErr: true},
+		{name: "no retry on bad request", w: RemoteWriter{Shards: 1}, statuses: []int{400}, series: series, wantRequests: 1, wantErr: true},
+		{name: "no metric name", w: RemoteWriter{}, series: []Series{{Labels: Labels{"job": "api"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				requests int
+				got      = map[string][]MetricValues{}
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests++
+				if requests <= len(tt.statuses) {
+					w.Header().Set("Retry-After", "0")
+					http.Error(w, "try again", tt.statuses[requests-1])
+					return
+				}
+				if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Scope-OrgID") != "ops" {
+					t.Errorf("headers = %v", r.Header)
+				}
+				body, _ := io.ReadAll(r.Body)
+				batch, err := DecodeWriteRequest(body)
+				if err != nil {
+					t.Errorf("DecodeWriteRequest() error = %v", err)
+				}
+				for _, s := range batch {
+					got[s.Labels.String()] = append(got[s.Labels.String()], s.Samples...)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			tt.w.URL = srv.URL
+			tt.w.Headers = map[string]string{"X-Scope-OrgID": "ops"}
+			if err := tt.w.Write(context.Background(), tt.series...); (err != nil) != tt.wantErr {
+				t.Fatalf("Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("Write() sent %d requests, want %d", requests, tt.wantRequests)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := map[string][]MetricValues{}
+			for _, s := range tt.series {
+				want[s.Labels.String()] = s.Samples
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("received %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRemoteWriter_batches(t *testing.T) {
+	w := &RemoteWriter{MaxSamplesPerSend: 2}
+	s := func(name string, n int) Series {
+		res := Series{Labels: Labels{"__name__": name}}
+		for i := 0; i < n; i++ {
+			res.Samples = append(res.Samples, MetricValues{Timestamp: int64(i)})
+		}
+		return res
+	}
+	var got [][]int
+	for _, batch := range w.batches([]Series{s("a", 3), s("b", 1), s("c", 1)}) {
+		var sizes []int
+		for _, bs := range batch {
+			sizes = append(sizes, len(bs.Samples))
+		}
+		sort.Ints(sizes)
+		got = append(got, sizes)
+	}
+	if want := [][]int{{2}, {1, 1}, {1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("batches() = %v, want %v", got, want)
+	}
+}
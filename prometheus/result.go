@@ -27,17 +27,38 @@ type MetricValues struct {
 	Value     float64
 }
 
+// HistogramBucket is a bucket of a native histogram.
+type HistogramBucket struct {
+	// Boundaries tells which bounds are inclusive: 0 the upper one,
+	// 1 the lower one, 2 neither and 3 both.
+	Boundaries int     `json:"boundaries"`
+	Lower      float64 `json:"lower"`
+	Upper      float64 `json:"upper"`
+	Count      float64 `json:"count"`
+}
+
+// HistogramValues is a native histogram sample.
+type HistogramValues struct {
+	Timestamp int64             `json:"timestamp"`
+	Count     float64           `json:"count"`
+	Sum       float64           `json:"sum"`
+	Buckets   []HistogramBucket `json:"buckets"`
+}
+
 // MatrixResult obtains the matrix result from the prometheus query.
 type MatrixResult struct {
-	Metric string `json:"metric"`
-	//Labels []MetricLabel  `json:"labels"`
-	Values []MetricValues `json:"values"`
+	Metric     string            `json:"metric"` // labels as printed by Prometheus, kept for compatibility
+	Labels     Labels            `json:"labels"`
+	Values     []MetricValues    `json:"values"`
+	Histograms []HistogramValues `json:"histograms,omitempty"` // native histogram samples
 }
 
 // VectorResult obtains the vector result from the prometheus query.
 type VectorResult struct {
-	Metric string       `json:"metric"`
-	Values MetricValues `json:"values"`
+	Metric    string           `json:"metric"` // labels as printed by Prometheus, kept for compatibility
+	Labels    Labels           `json:"labels"`
+	Values    MetricValues     `json:"values"`              // Value is 0 for native histogram samples
+	Histogram *HistogramValues `json:"histogram,omitempty"` // native histogram sample
 }
 
 // ScalarResult obtains the scalar result from the prometheus query.
@@ -45,6 +66,12 @@ type ScalarResult struct {
 	Value MetricValues `json:"value"`
 }
 
+// StringResult obtains the string result from the prometheus query.
+type StringResult struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
 // Matrix is a list of time series.
 type Matrix []MatrixResult
 
@@ -54,6 +81,9 @@ type Vector []VectorResult
 // Scalar is scalar result
 type Scalar ScalarResult
 
+// String is string result
+type String StringResult
+
 type ResultValues interface {
 	ConvertByte() ([]byte, error)
 }
@@ -69,3 +99,7 @@ func (v Vector) ConvertByte() ([]byte, error) {
 func (s Scalar) ConvertByte() ([]byte, error) {
 	return json.Marshal(s)
 }
+
+func (s String) ConvertByte() ([]byte, error) {
+	return json.Marshal(s)
+}
@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"github.com/prometheus/common/model"
+	"reflect"
+	"testing"
+)
+
+func TestConvertValue(t *testing.T) {
+	hist := `{"count":"3","sum":"4.5","buckets":[[0,"0.5","1","1"],[3,"1","2","2"]]}`
+	want := HistogramValues{Timestamp: 1000, Count: 3, Sum: 4.5, Buckets: []HistogramBucket{
+		{Boundaries: 0, Lower: 0.5, Upper: 1, Count: 1},
+		{Boundaries: 3, Lower: 1, Upper: 2, Count: 2},
+	}}
+	tests := []struct {
+		name    string
+		typ     model.ValueType
+		result  string
+		want    ResultValues
+		wantErr bool
+	}{
+		{
+			name:   "string",
+			typ:    model.ValString,
+			result: `[1,"hello"]`,
+			want:   String{Timestamp: 1000, Value: "hello"},
+		},
+		{
+			name:   "vector histogram",
+			typ:    model.ValVector,
+			result: `[{"metric":{"job":"api"},"histogram":[1,` + hist + `]}]`,
+			want:   Vector{{Metric: `{job="api"}`, Labels: Labels{"job": "api"}, Values: MetricValues{Timestamp: 1000}, Histogram: &want}},
+		},
+		{
+			name:   "matrix histograms",
+			typ:    model.ValMatrix,
+			result: `[{"metric":{"job":"api"},"histograms":[[1,` + hist + `]]}]`,
+			want:   Matrix{{Metric: `{job="api"}`, Labels: Labels{"job": "api"}, Histograms: []HistogramValues{want}}},
+		},
+		{
+			name:    "unsupported",
+			typ:     model.ValNone,
+			result:  `null`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := decodeValue(tt.typ, []byte(tt.result))
+			var got ResultValues
+			if err == nil {
+				got, err = convertValue(value)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertValue() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if _, err := convertValue(nil); err == nil {
+		t.Errorf("convertValue(nil) error = nil, want error")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"net/http"
+	"time"
+)
+
+// acceptHeader prefers OpenMetrics, like Prometheus does.
+const acceptHeader = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+
+// Scraper scrapes metrics endpoints.
+type Scraper struct {
+	// Client gets the endpoints, defaults to a
+	// common.NewClient with a timeout of 10 seconds.
+	Client common.DevopsHttpClient
+	// Headers are added to the requests, such as Authorization.
+	Headers map[string]string
+}
+
+// Scrape gets the metrics endpoint addr, such as http://localhost:9100/metrics,
+// and parses its metric families with ParseMetrics. Samples without
+// timestamp get the time of the scrape. Responses of a status other
+// than 2xx are errors.
+func (s *Scraper) Scrape(ctx context.Context, addr string) ([]MetricFamily, error) {
+	client := s.Client
+	if client == nil {
+		client = common.NewClient(http.Client{Timeout: 10 * time.Second})
+	}
+	headers := map[string]string{"Accept": acceptHeader}
+	for k, v := range s.Headers {
+		headers[k] = v
+	}
+	now := time.Now()
+	body, err := client.Get(ctx, addr, headers)
+	if err != nil {
+		return nil, fmt.Errorf("scrape %s: %w", addr, err)
+	}
+	families, err := ParseMetrics(body, now)
+	if err != nil {
+		return nil, fmt.Errorf("scrape %s: %w", addr, err)
+	}
+	return families, nil
+}
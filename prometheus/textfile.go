@@ -0,0 +1,85 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteMetrics writes metrics to w in the Prometheus text format,
+// sorted by name. Metrics are validated by ValidateMetrics first.
+func WriteMetrics(w io.Writer, metrics ...PushMetrics) error {
+	if err := ValidateMetrics(metrics, 0); err != nil {
+		return err
+	}
+	reg := prometheus.NewRegistry()
+	for _, pm := range metrics {
+		c, err := pm.Collector()
+		if err != nil {
+			return err
+		}
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		return err
+	}
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTextfile writes metrics to the file path, such as
+// /var/lib/node_exporter/textfile/backup.prom, for the textfile
+// collector of node_exporter. The file is written to a hidden
+// temporary file first and renamed, so the collector never
+// reads a partial file.
+func WriteTextfile(path string, metrics ...PushMetrics) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err = WriteMetrics(f, metrics...); err != nil {
+		return err
+	}
+	if err = f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
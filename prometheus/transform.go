@@ -0,0 +1,317 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// The transformations of this file return new results and leave their
+// receivers unchanged. They work on the float samples, histogram samples
+// are dropped, and expect the samples of series sorted by timestamp, as
+// returned by Prometheus. Missing values are NaN.
+
+// Fill is how Resample fills the steps without samples.
+type Fill int
+
+const (
+	FillNull     Fill = iota // NaN
+	FillPrevious             // the previous value, or NaN before the first sample
+	FillZero                 // 0
+)
+
+// Resample returns the series with a sample at every step from start to
+// end: the last sample of the step before it, or else a value of fill.
+// It returns nil if step is not positive or end is before start.
+func (m Matrix) Resample(start, end time.Time, step time.Duration, fill Fill) Matrix {
+	startMs, endMs := start.UnixNano()/int64(time.Millisecond), end.UnixNano()/int64(time.Millisecond)
+	stepMs := int64(step / time.Millisecond)
+	if stepMs <= 0 || endMs < startMs {
+		return nil
+	}
+	res := make(Matrix, 0, len(m))
+	for _, r := range m {
+		values := make([]MetricValues, 0, (endMs-startMs)/stepMs+1)
+		prev, j := math.NaN(), 0
+		for t := startMs; t <= endMs; t += stepMs {
+			v, found := math.NaN(), false
+			for ; j < len(r.Values) && r.Values[j].Timestamp <= t; j++ {
+				if r.Values[j].Timestamp > t-stepMs {
+					v, found = r.Values[j].Value, true
+				}
+				prev = r.Values[j].Value
+			}
+			if !found {
+				switch fill {
+				case FillPrevious:
+					v = prev
+				case FillZero:
+					v = 0
+				}
+			}
+			values = append(values, MetricValues{Timestamp: t, Value: v})
+		}
+		res = append(res, MatrixResult{Metric: r.Metric, Labels: r.Labels, Values: values})
+	}
+	return res
+}
+
+// Rate returns the per-second rate of increase of counter series between
+// consecutive samples, at the timestamp of the second one. A decrease is
+// a counter reset, counted as an increase from 0.
+func (m Matrix) Rate() Matrix {
+	res := make(Matrix, 0, len(m))
+	for _, r := range m {
+		var values []MetricValues
+		for i := 1; i < len(r.Values); i++ {
+			prev, cur := r.Values[i-1], r.Values[i]
+			if cur.Timestamp <= prev.Timestamp || math.IsNaN(prev.Value) || math.IsNaN(cur.Value) {
+				continue
+			}
+			increase := cur.Value - prev.Value
+			if increase < 0 {
+				increase = cur.Value
+			}
+			seconds := float64(cur.Timestamp-prev.Timestamp) / 1000
+			values = append(values, MetricValues{Timestamp: cur.Timestamp, Value: increase / seconds})
+		}
+		res = append(res, MatrixResult{Metric: r.Metric, Labels: r.Labels, Values: values})
+	}
+	return res
+}
+
+// Reducer reduces the values of a series, without NaN, to a value.
+type Reducer func(values []float64) float64
+
+// Reducers of the values of a series.
+var (
+	ReduceMin Reducer = func(values []float64) float64 {
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min
+	}
+	ReduceMax Reducer = func(values []float64) float64 {
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max
+	}
+	ReduceSum Reducer = func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	}
+	ReduceAvg Reducer = func(values []float64) float64 {
+		return ReduceSum(values) / float64(len(values))
+	}
+	ReduceLast Reducer = func(values []float64) float64 {
+		return values[len(values)-1]
+	}
+)
+
+// ReduceQuantile returns the q-quantile, or percentile q*100, of the
+// values, interpolated like quantile_over_time of PromQL.
+func ReduceQuantile(q float64) Reducer {
+	return func(values []float64) float64 {
+		switch {
+		case math.IsNaN(q):
+			return math.NaN()
+		case q < 0:
+			return math.Inf(-1)
+		case q > 1:
+			return math.Inf(1)
+		}
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		rank := q * float64(len(sorted)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		weight := rank - float64(lower)
+		return sorted[lower]*(1-weight) + sorted[upper]*weight
+	}
+}
+
+// reduce returns the value of fn of the values of r but NaN, and
+// false if it has none.
+func (r MatrixResult) reduce(fn Reducer) (MetricValues, bool) {
+	values := make([]float64, 0, len(r.Values))
+	var ts int64
+	for _, v := range r.Values {
+		if !math.IsNaN(v.Value) {
+			values = append(values, v.Value)
+			ts = v.Timestamp
+		}
+	}
+	if len(values) == 0 {
+		return MetricValues{}, false
+	}
+	return MetricValues{Timestamp: ts, Value: fn(values)}, true
+}
+
+// Reduce returns a sample of the value of fn for each series, at the
+// timestamp of its last sample. Series without values are dropped.
+func (m Matrix) Reduce(fn Reducer) Vector {
+	res := make(Vector, 0, len(m))
+	for _, r := range m {
+		if v, ok := r.reduce(fn); ok {
+			res = append(res, VectorResult{Metric: r.Metric, Labels: r.Labels, Values: v})
+		}
+	}
+	return res
+}
+
+// Min returns the minimum of each series.
+func (m Matrix) Min() Vector { return m.Reduce(ReduceMin) }
+
+// Max returns the maximum of each series.
+func (m Matrix) Max() Vector { return m.Reduce(ReduceMax) }
+
+// Avg returns the average of each series.
+func (m Matrix) Avg() Vector { return m.Reduce(ReduceAvg) }
+
+// Quantile returns the q-quantile of each series, such as
+// Quantile(0.95) for the 95th percentile.
+func (m Matrix) Quantile(q float64) Vector { return m.Reduce(ReduceQuantile(q)) }
+
+// TopK returns the k series with the largest value of fn, largest first.
+func (m Matrix) TopK(k int, fn Reducer) Matrix { return m.rank(k, fn, true) }
+
+// BottomK returns the k series with the smallest value of fn, smallest first.
+func (m Matrix) BottomK(k int, fn Reducer) Matrix { return m.rank(k, fn, false) }
+
+func (m Matrix) rank(k int, fn Reducer, top bool) Matrix {
+	ranked := m.Reduce(fn).rank(k, top)
+	index := make(map[string]int, len(m))
+	for i, r := range m {
+		index[r.Labels.String()] = i
+	}
+	res := make(Matrix, 0, len(ranked))
+	for _, r := range ranked {
+		res = append(res, m[index[r.Labels.String()]])
+	}
+	return res
+}
+
+// TopK returns the k samples with the largest values, largest first.
+func (v Vector) TopK(k int) Vector { return v.rank(k, true) }
+
+// BottomK returns the k samples with the smallest values, smallest first.
+func (v Vector) BottomK(k int) Vector { return v.rank(k, false) }
+
+// rank returns the k first samples of v sorted by value,
+// NaN last and equal values in the order of v.
+func (v Vector) rank(k int, top bool) Vector {
+	res := make(Vector, 0, len(v))
+	for _, r := range v {
+		if r.Histogram == nil {
+			res = append(res, r)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i].Values.Value, res[j].Values.Value
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return !math.IsNaN(a)
+		}
+		if top {
+			return a > b
+		}
+		return a < b
+	})
+	if k < 0 {
+		k = 0
+	}
+	if k < len(res) {
+		res = res[:k]
+	}
+	return res
+}
+
+// joined returns the labels of a and those of b it does not have.
+func joined(a, b Labels) Labels {
+	l := make(Labels, len(a)+len(b))
+	for k, v := range b {
+		l[k] = v
+	}
+	for k, v := range a {
+		l[k] = v
+	}
+	return l
+}
+
+// Join joins the samples of v and other with the same values of the
+// labels on, with the value of fn of their values. The result has the
+// labels of v and those of other v does not have, such as the labels
+// of an info metric. Samples of v without match are dropped, samples
+// matching several of other use the first one.
+func (v Vector) Join(other Vector, on []string, fn func(a, b float64) float64) Vector {
+	index := make(map[string]int, len(other))
+	for i := len(other) - 1; i >= 0; i-- {
+		_, key := other[i].Labels.only(on)
+		index[key] = i
+	}
+	var res Vector
+	for _, r := range v {
+		_, key := r.Labels.only(on)
+		i, ok := index[key]
+		if !ok {
+			continue
+		}
+		labels := joined(r.Labels, other[i].Labels)
+		res = append(res, VectorResult{
+			Metric: labels.String(),
+			Labels: labels,
+			Values: MetricValues{Timestamp: r.Values.Timestamp, Value: fn(r.Values.Value, other[i].Values.Value)},
+		})
+	}
+	return res
+}
+
+// Join joins the series of m and other with the same values of the
+// labels on, like Vector.Join, at the timestamps of both series.
+// Resample aligns the timestamps of series.
+func (m Matrix) Join(other Matrix, on []string, fn func(a, b float64) float64) Matrix {
+	index := make(map[string]int, len(other))
+	for i := len(other) - 1; i >= 0; i-- {
+		_, key := other[i].Labels.only(on)
+		index[key] = i
+	}
+	var res Matrix
+	for _, r := range m {
+		_, key := r.Labels.only(on)
+		i, ok := index[key]
+		if !ok {
+			continue
+		}
+		values := make(map[int64]float64, len(other[i].Values))
+		for _, v := range other[i].Values {
+			values[v.Timestamp] = v.Value
+		}
+		labels := joined(r.Labels, other[i].Labels)
+		joinedValues := make([]MetricValues, 0, len(r.Values))
+		for _, v := range r.Values {
+			if b, ok := values[v.Timestamp]; ok {
+				joinedValues = append(joinedValues, MetricValues{Timestamp: v.Timestamp, Value: fn(v.Value, b)})
+			}
+		}
+		res = append(res, MatrixResult{Metric: labels.String(), Labels: labels, Values: joinedValues})
+	}
+	return res
+}
@@ -0,0 +1,167 @@
+package prometheus
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// series returns a series of labels with values at ts, ts+step...
+func series(labels Labels, ts, step int64, values ...float64) MatrixResult {
+	r := MatrixResult{Metric: labels.String(), Labels: labels}
+	for i, v := range values {
+		r.Values = append(r.Values, MetricValues{Timestamp: ts + int64(i)*step, Value: v})
+	}
+	return r
+}
+
+func TestMatrix_Resample(t *testing.T) {
+	labels := Labels{"__name__": "up"}
+	m := Matrix{{Metric: labels.String(), Labels: labels, Values: []MetricValues{
+		{Timestamp: 6000, Value: 1}, {Timestamp: 9000, Value: 2}, {Timestamp: 9500, Value: 3}, {Timestamp: 32000, Value: 4},
+	}}}
+	nan := math.NaN()
+	tests := []struct {
+		name string
+		fill Fill
+		want []float64
+	}{
+		{name: "null", fill: FillNull, want: []float64{nan, 3, nan, 4}},
+		{name: "previous", fill: FillPrevious, want: []float64{nan, 3, 3, 4}},
+		{name: "zero", fill: FillZero, want: []float64{0, 3, 0, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := m.Resample(time.Unix(5, 0), time.Unix(35, 0), 10*time.Second, tt.fill)
+			if len(got) != 1 || len(got[0].Values) != len(tt.want) {
+				t.Fatalf("Resample() = %v, want %d samples", got, len(tt.want))
+			}
+			for i, v := range got[0].Values {
+				if ts := int64(5000 + i*10000); v.Timestamp != ts {
+					t.Errorf("Resample() timestamp %d = %d, want %d", i, v.Timestamp, ts)
+				}
+				if v.Value != tt.want[i] && !(math.IsNaN(v.Value) && math.IsNaN(tt.want[i])) {
+					t.Errorf("Resample() value %d = %v, want %v", i, v.Value, tt.want[i])
+				}
+			}
+		})
+	}
+	t.Run("end before start", func(t *testing.T) {
+		start := time.Unix(600, 0)
+		if got := m.Resample(start, start.Add(-5*time.Minute), time.Minute, FillNull); got != nil {
+			t.Errorf("Resample() = %v, want nil", got)
+		}
+	})
+}
+
+func TestMatrix_Rate(t *testing.T) {
+	labels := Labels{"__name__": "requests_total"}
+	m := Matrix{series(labels, 0, 10000, 10, 30, 5, 25)}
+	want := Matrix{series(labels, 10000, 10000, 2, 0.5, 2)}
+	if got := m.Rate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Rate() = %v, want %v", got, want)
+	}
+}
+
+func TestMatrix_Reduce(t *testing.T) {
+	a := series(Labels{"pod": "a"}, 0, 1000, 4, 1, math.NaN(), 3, 2)
+	b := series(Labels{"pod": "b"}, 0, 1000, 10)
+	empty := series(Labels{"pod": "c"}, 0, 1000, math.NaN())
+	m := Matrix{a, b, empty}
+	tests := []struct {
+		name string
+		got  Vector
+		want []float64
+	}{
+		{name: "min", got: m.Min(), want: []float64{1, 10}},
+		{name: "max", got: m.Max(), want: []float64{4, 10}},
+		{name: "avg", got: m.Avg(), want: []float64{2.5, 10}},
+		{name: "sum", got: m.Reduce(ReduceSum), want: []float64{10, 10}},
+		{name: "last", got: m.Reduce(ReduceLast), want: []float64{2, 10}},
+		{name: "median", got: m.Quantile(0.5), want: []float64{2.5, 10}},
+		{name: "p90", got: m.Quantile(0.9), want: []float64{3.7, 10}},
+		{name: "quantile above 1", got: m.Quantile(2), want: []float64{math.Inf(1), math.Inf(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.got) != len(tt.want) {
+				t.Fatalf("Reduce() = %v, want %v", tt.got, tt.want)
+			}
+			for i, r := range tt.got {
+				if math.Abs(r.Values.Value-tt.want[i]) > 1e-9 && r.Values.Value != tt.want[i] {
+					t.Errorf("Reduce() value %d = %v, want %v", i, r.Values.Value, tt.want[i])
+				}
+			}
+			if tt.got[0].Values.Timestamp != 4000 || tt.got[0].Label("pod") != "a" {
+				t.Errorf("Reduce() = %v, want the last timestamp and labels of the series", tt.got[0])
+			}
+		})
+	}
+}
+
+func TestTopK(t *testing.T) {
+	m := Matrix{
+		series(Labels{"pod": "a"}, 0, 1000, 1, 2),
+		series(Labels{"pod": "b"}, 0, 1000, 5, 6),
+		series(Labels{"pod": "c"}, 0, 1000, 3, 4),
+	}
+	if got := m.TopK(2, ReduceAvg); !reflect.DeepEqual(got, Matrix{m[1], m[2]}) {
+		t.Errorf("Matrix.TopK() = %v, want b and c", got)
+	}
+	if got := m.BottomK(1, ReduceMax); !reflect.DeepEqual(got, Matrix{m[0]}) {
+		t.Errorf("Matrix.BottomK() = %v, want a", got)
+	}
+
+	v := Vector{
+		{Labels: Labels{"pod": "a"}, Values: MetricValues{Value: math.NaN()}},
+		{Labels: Labels{"pod": "b"}, Values: MetricValues{Value: 1}},
+		{Labels: Labels{"pod": "c"}, Values: MetricValues{Value: 3}},
+		{Labels: Labels{"pod": "d"}, Values: MetricValues{Value: 1}},
+	}
+	tests := []struct {
+		name string
+		got  Vector
+		want []string
+	}{
+		{name: "top", got: v.TopK(3), want: []string{"c", "b", "d"}},
+		{name: "bottom", got: v.BottomK(2), want: []string{"b", "d"}},
+		{name: "nan last", got: v.BottomK(10), want: []string{"b", "d", "c", "a"}},
+		{name: "zero", got: v.TopK(0), want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range tt.got {
+				got = append(got, r.Label("pod"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopK() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	div := func(a, b float64) float64 { return a / b }
+	usage := Vector{
+		{Labels: Labels{"__name__": "usage", "pod": "a"}, Values: MetricValues{Timestamp: 1000, Value: 1}},
+		{Labels: Labels{"__name__": "usage", "pod": "b"}, Values: MetricValues{Timestamp: 1000, Value: 3}},
+	}
+	limits := Vector{
+		{Labels: Labels{"__name__": "limit", "pod": "a", "team": "x"}, Values: MetricValues{Timestamp: 2000, Value: 4}},
+	}
+	labels := Labels{"__name__": "usage", "pod": "a", "team": "x"}
+	want := Vector{{Metric: labels.String(), Labels: labels, Values: MetricValues{Timestamp: 1000, Value: 0.25}}}
+	if got := usage.Join(limits, []string{"pod"}, div); !reflect.DeepEqual(got, want) {
+		t.Errorf("Vector.Join() = %v, want %v", got, want)
+	}
+
+	m := Matrix{series(Labels{"pod": "a"}, 0, 1000, 1, 2, 3)}
+	other := Matrix{series(Labels{"pod": "a", "node": "n"}, 1000, 1000, 4, 8, 16)}
+	labels = Labels{"pod": "a", "node": "n"}
+	wantMatrix := Matrix{series(labels, 1000, 1000, 0.5, 0.375)}
+	if got := m.Join(other, []string{"pod"}, div); !reflect.DeepEqual(got, wantMatrix) {
+		t.Errorf("Matrix.Join() = %v, want %v", got, wantMatrix)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"github.com/prometheus/common/model"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxSeries is the default limit of series per metrics.
+const DefaultMaxSeries = 10000
+
+// ValidationError lists the problems found in metrics before pushing.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid metrics: " + strings.Join(e, "; ")
+}
+
+// ValidateMetrics checks metrics before they are pushed together:
+// metric and label names must be valid and unique, every series must
+// have a value for each label and differ from the others, histogram
+// buckets must increase, summary objectives must be quantiles, counters
+// must not decrease, and no metrics may have more than maxSeries series.
+// maxSeries defaults to DefaultMaxSeries when not positive.
+// It returns a ValidationError listing all problems found.
+func ValidateMetrics(metrics []PushMetrics, maxSeries int) error {
+	if maxSeries <= 0 {
+		maxSeries = DefaultMaxSeries
+	}
+	var errs ValidationError
+	names := make(map[string]bool)
+	for _, pm := range metrics {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Sprintf("%q: ", pm.Name)+fmt.Sprintf(format, args...))
+		}
+		if !model.IsValidMetricName(model.LabelValue(pm.Name)) {
+			fail("invalid metric name")
+		}
+		if names[pm.Name] {
+			fail("pushed more than once")
+		}
+		names[pm.Name] = true
+		if pm.Type < Gauge || pm.Type > Summary {
+			fail("unsupported metric type %v", pm.Type)
+		}
+
+		labels := make(map[string]bool)
+		checkLabel := func(name, kind string) {
+			switch {
+			case !model.LabelName(name).IsValid():
+				fail("invalid %s name %q", kind, name)
+			case strings.HasPrefix(name, "__"):
+				fail("%s name %q is reserved", kind, name)
+			case pm.Type == Histogram && name == model.BucketLabel, pm.Type == Summary && name == model.QuantileLabel:
+				fail("%s name %q is reserved for %s", kind, name, pm.Type)
+			case labels[name]:
+				fail("duplicate label name %q", name)
+			}
+			labels[name] = true
+		}
+		for _, l := range pm.Label {
+			checkLabel(l, "label")
+		}
+		constLabels := make([]string, 0, len(pm.ConstLabels))
+		for l := range pm.ConstLabels {
+			constLabels = append(constLabels, l)
+		}
+		sort.Strings(constLabels)
+		for _, l := range constLabels {
+			checkLabel(l, "const label")
+		}
+
+		for i := 1; i < len(pm.Buckets); i++ {
+			if pm.Buckets[i] <= pm.Buckets[i-1] {
+				fail("histogram buckets must be in increasing order")
+				break
+			}
+		}
+		for q, e := range pm.Objectives {
+			if q < 0 || q > 1 || e < 0 {
+				fail("invalid summary objective %v with error %v", q, e)
+			}
+		}
+
+		if len(pm.Metrics) > maxSeries {
+			fail("%d series exceed the limit of %d", len(pm.Metrics), maxSeries)
+			continue
+		}
+		series := make(map[string]bool)
+		for _, m := range pm.Metrics {
+			if len(m.Values) != len(pm.Label) {
+				fail("%d label values %q for %d labels %q", len(m.Values), m.Values, len(pm.Label), pm.Label)
+				continue
+			}
+			if pm.Type == Counter && m.Data < 0 {
+				fail("counter cannot decrease by %v", m.Data)
+			}
+			key := strings.Join(m.Values, "\xff")
+			if series[key] {
+				fail("duplicate series %q", m.Values)
+			}
+			series[key] = true
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateMetrics(t *testing.T) {
+	ok := PushMetrics{Name: "backup_success", Label: []string{"db"}, Metrics: []PromMetrics{{Values: []string{"users"}, Data: 1}}}
+	tests := []struct {
+		name      string
+		metrics   []PushMetrics
+		maxSeries int
+		want      []string
+	}{
+		{name: "valid", metrics: []PushMetrics{ok, {Name: "backup_bytes", Metrics: []PromMetrics{{Data: 42}}}}},
+		{name: "invalid metric name", metrics: []PushMetrics{{Name: "backup-success"}}, want: []string{`"backup-success": invalid metric name`}},
+		{name: "duplicate name", metrics: []PushMetrics{ok, ok}, want: []string{`"backup_success": pushed more than once`}},
+		{
+			name:    "label names",
+			metrics: []PushMetrics{{Name: "up", Label: []string{"db", "1db", "__name", "db"}, ConstLabels: map[string]string{"db": "x"}}},
+			want: []string{
+				`"up": invalid label name "1db"`,
+				`"up": label name "__name" is reserved`,
+				`"up": duplicate label name "db"`,
+				`"up": duplicate label name "db"`,
+			},
+		},
+		{
+			name:    "reserved histogram label",
+			metrics: []PushMetrics{{Name: "latency", Type: Histogram, Label: []string{"le"}, Buckets: []float64{1, 1}}},
+			want: []string{
+				`"latency": label name "le" is reserved for histogram`,
+				`"latency": histogram buckets must be in increasing order`,
+			},
+		},
+		{
+			name:    "label cardinality",
+			metrics: []PushMetrics{{Name: "up", Label: []string{"db"}, Metrics: []PromMetrics{{Values: []string{"a", "b"}}, {Values: []string{"c"}}, {Values: []string{"c"}}}}},
+			want: []string{
+				`"up": 2 label values ["a" "b"] for 1 labels ["db"]`,
+				`"up": duplicate series ["c"]`,
+			},
+		},
+		{
+			name:      "too many series",
+			metrics:   []PushMetrics{{Name: "up", Label: []string{"db"}, Metrics: []PromMetrics{{Values: []string{"a"}}, {Values: []string{"b"}}}}},
+			maxSeries: 1,
+			want:      []string{`"up": 2 series exceed the limit of 1`},
+		},
+		{name: "negative counter", metrics: []PushMetrics{{Name: "runs_total", Type: Counter, Metrics: []PromMetrics{{Data: -1}}}}, want: []string{`"runs_total": counter cannot decrease by -1`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMetrics(tt.metrics, tt.maxSeries)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateMetrics() error = %v, want nil", err)
+				}
+				return
+			}
+			var verr ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("ValidateMetrics() error = %v, want ValidationError", err)
+			}
+			if strings.Join(verr, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ValidateMetrics() = %q, want %q", verr, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushgateway_Push_batch(t *testing.T) {
+	var requests int
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		b := new(strings.Builder)
+		_, _ = io.Copy(b, r.Body)
+		body = b.String()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	g := &Pushgateway{URL: srv.URL, Job: "exporter"}
+	metrics := []PushMetrics{
+		{Name: "exporter_up", Metrics: []PromMetrics{{Data: 1}}},
+		{Name: "exporter_runs_total", Type: Counter, Metrics: []PromMetrics{{Data: 3}}},
+	}
+	if err := g.Push(context.Background(), metrics...); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if requests != 1 || !strings.Contains(body, "exporter_up") || !strings.Contains(body, "exporter_runs_total") {
+		t.Errorf("Push() sent %d requests, body %q", requests, body)
+	}
+
+	requests = 0
+	metrics = append(metrics, PushMetrics{Name: "exporter up"})
+	if err := g.Push(context.Background(), metrics...); err == nil || requests != 0 {
+		t.Errorf("Push() error = %v after %d requests, want validation error before sending", err, requests)
+	}
+}
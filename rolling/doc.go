@@ -0,0 +1,26 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package rolling implements rolling execution across hosts
+
+A Runner runs a task on hosts batch by batch over SSH, gates each batch
+on a health check such as a command or a Prometheus query, halts once
+failures exceed a threshold and records its progress in a state file so
+that an interrupted or halted rollout can be resumed.
+
+References:
+
+	[Ansible rolling updates]: https://docs.ansible.com/ansible/latest/playbook_guide/playbooks_strategies.html#setting-the-batch-size-with-serial
+*/
+package rolling // import "github.com/mo-silent/go-devops/rolling"
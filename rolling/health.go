@@ -0,0 +1,122 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rolling
+
+import (
+	"context"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"github.com/mo-silent/go-devops/prometheus"
+	"github.com/prometheus/client_golang/api"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Host is a host of the batch being checked.
+type Host struct {
+	Name     string
+	Executor common.Executor
+}
+
+// HealthCheck tells whether a batch is healthy after the task ran on it.
+type HealthCheck interface {
+	Check(ctx context.Context, batch []Host) error
+}
+
+// CommandCheck is a HealthCheck running a command on every host of
+// the batch, healthy when it exits with status zero everywhere.
+type CommandCheck struct {
+	Command string
+}
+
+// Check runs the command on the hosts of batch concurrently.
+func (c *CommandCheck) Check(ctx context.Context, batch []Host) error {
+	var mu sync.Mutex
+	var failed []string
+	var wg sync.WaitGroup
+	for _, h := range batch {
+		h := h
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := h.Executor.Run(ctx, c.Command, common.ExecOptions{})
+			if err == nil && res.ExitCode != 0 {
+				err = fmt.Errorf("exit status %d", res.ExitCode)
+			}
+			if err != nil {
+				mu.Lock()
+				failed = append(failed, fmt.Sprintf("%s: %v", h.Name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed on %s", c.Command, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// PrometheusCheck is a HealthCheck running a Prometheus instant query,
+// healthy when it returns at least one sample and no sample is zero
+// or NaN, such as a ratio of zero requests. Native histogram samples
+// are ignored.
+// Comparisons such as `min(up{job="web"}) == 1` or their bool form
+// both fit.
+type PrometheusCheck struct {
+	Client api.Client
+	Query  string
+	// Metrics runs the query, defaults to prometheus.Prometheus.
+	Metrics prometheus.MetricsInterface
+}
+
+// Check runs the query at the current time.
+func (c *PrometheusCheck) Check(ctx context.Context, _ []Host) error {
+	m := c.Metrics
+	if m == nil {
+		m = &prometheus.Prometheus{}
+	}
+	res, err := m.Query(ctx, c.Client, c.Query, time.Now().UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return err
+	}
+	var values []float64
+	switch v := res.(type) {
+	case prometheus.Vector:
+		for _, s := range v {
+			if s.Histogram == nil {
+				values = append(values, s.Values.Value)
+			}
+		}
+	case prometheus.Scalar:
+		values = append(values, v.Value.Value)
+	case prometheus.Matrix:
+		for _, s := range v {
+			for _, p := range s.Values {
+				values = append(values, p.Value)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("query %s returned no samples", c.Query)
+	}
+	for _, v := range values {
+		if v == 0 || math.IsNaN(v) {
+			return fmt.Errorf("query %s returned %v", c.Query, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package rolling
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCommandCheck_Check(t *testing.T) {
+	batch := []Host{
+		{Name: "web1", Executor: &fakeExecutor{}},
+		{Name: "web2", Executor: &fakeExecutor{codes: map[string]int{"systemctl is-active nginx": 3}}},
+	}
+	c := &CommandCheck{Command: "systemctl is-active nginx"}
+	if err := c.Check(context.Background(), batch[:1]); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if err := c.Check(context.Background(), batch); err == nil {
+		t.Errorf("Check() error = nil, want web2 failing")
+	}
+}
+
+func TestPrometheusCheck_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  string
+		wantErr bool
+	}{
+		{name: "healthy", result: `{"resultType":"vector","result":[{"metric":{"job":"web"},"value":[1700000000,"1"]}]}`},
+		{name: "scalar", result: `{"resultType":"scalar","result":[1700000000,"1"]}`},
+		{name: "zero", result: `{"resultType":"vector","result":[{"metric":{"job":"web"},"value":[1700000000,"0"]}]}`, wantErr: true},
+		{name: "NaN", result: `{"resultType":"vector","result":[{"metric":{"job":"web"},"value":[1700000000,"NaN"]}]}`, wantErr: true},
+		{name: "histogram", result: `{"resultType":"vector","result":[{"metric":{"job":"web"},"value":[1700000000,"1"]},{"metric":{"job":"web"},"histogram":[1700000000,{"count":"2","sum":"3","buckets":[[0,"0","4","2"]]}]}]}`},
+		{name: "histogram only", result: `{"resultType":"vector","result":[{"metric":{"job":"web"},"histogram":[1700000000,{"count":"2","sum":"3","buckets":[[0,"0","4","2"]]}]}]}`, wantErr: true},
+		{name: "empty", result: `{"resultType":"vector","result":[]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/query" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"status":"success","data":%s}`, tt.result)
+			}))
+			defer srv.Close()
+			client, err := api.NewClient(api.Config{Address: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &PrometheusCheck{Client: client, Query: `min(up{job="web"})`}
+			if err := c.Check(context.Background(), nil); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rolling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrHalted is returned when a rollout halts on failures
+// or on a failed health check.
+var ErrHalted = errors.New("rollout halted")
+
+// Task is the work done on a host.
+type Task func(ctx context.Context, host string, e common.Executor) error
+
+// CommandTask returns a Task running cmd, failing
+// when it exits with a non-zero status.
+func CommandTask(cmd string) Task {
+	return func(ctx context.Context, host string, e common.Executor) error {
+		res, err := e.Run(ctx, cmd, common.ExecOptions{})
+		if err != nil {
+			return err
+		}
+		if res.ExitCode != 0 {
+			return fmt.Errorf("%s: exit status %d", cmd, res.ExitCode)
+		}
+		return nil
+	}
+}
+
+// Runner runs a Task on hosts batch by batch.
+type Runner struct {
+	// Hosts are the hosts to run on, in order.
+	Hosts []string
+	// Executor returns the executor of a host, such as
+	// inventory.Inventory.Executor.
+	Executor func(host string) (common.Executor, error)
+	Task     Task
+
+	// BatchSize is the number of hosts per batch. BatchPercent sets it
+	// to a percentage of the hosts instead, rounded up. Hosts run one
+	// at a time when neither is set.
+	BatchSize    int
+	BatchPercent int
+
+	// HealthCheck gates each batch. It is retried every HealthInterval,
+	// 5s by default, until it passes or HealthTimeout, 1m by default,
+	// expires, which halts the rollout.
+	HealthCheck    HealthCheck
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+
+	// MaxFailures is the number of failed hosts tolerated, MaxFailPercent
+	// the percentage of hosts. The rollout halts after the batch in which
+	// failures exceed it, so any failure halts it by default.
+	MaxFailures    int
+	MaxFailPercent int
+
+	// StateFile records the progress after every batch. When it exists
+	// Run resumes from it, skipping the hosts already done and retrying
+	// the failed ones. A rollout resumed after a halt first checks the
+	// health of the batch it halted on.
+	StateFile string
+}
+
+// Run runs the rollout and returns its final state. It returns an error
+// wrapping ErrHalted when the rollout halts, and the error of ctx when
+// it is cancelled, with the state saved in both cases.
+func (r *Runner) Run(ctx context.Context) (*State, error) {
+	if r.Executor == nil || r.Task == nil {
+		return nil, errors.New("rolling: Executor and Task are required")
+	}
+	st, err := r.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if st.Halted != "" && r.HealthCheck != nil {
+		if err := r.recheckHealth(ctx, st); err != nil {
+			if ctx.Err() == nil {
+				st.Halted = fmt.Sprintf("batch %d health check: %v", st.Batches, err)
+			}
+			if err := r.saveState(st); err != nil {
+				return st, err
+			}
+			if ctx.Err() != nil {
+				return st, ctx.Err()
+			}
+			log.Warnf("rolling halted: %s", st.Halted)
+			return st, fmt.Errorf("%w: %s", ErrHalted, st.Halted)
+		}
+	}
+	var pending []string
+	for _, h := range r.Hosts {
+		hs, ok := st.Hosts[h]
+		if !ok {
+			hs = &HostState{Status: Pending}
+			st.Hosts[h] = hs
+		}
+		if hs.Status != Done {
+			hs.Status, hs.Error = Pending, ""
+			pending = append(pending, h)
+		}
+	}
+	st.Halted = ""
+
+	size, maxFailures := r.limits()
+	failures := 0
+	for len(pending) > 0 {
+		n := size
+		if n > len(pending) {
+			n = len(pending)
+		}
+		batch := pending[:n]
+		pending = pending[n:]
+		st.Batches++
+		log.Infof("rolling batch %d: %v", st.Batches, batch)
+
+		hosts, failed := r.runBatch(ctx, st, batch)
+		failures += failed
+		if ctx.Err() == nil && failures > maxFailures {
+			st.Halted = fmt.Sprintf("%d failed hosts exceed the %d tolerated", failures, maxFailures)
+		} else if ctx.Err() == nil && r.HealthCheck != nil && len(hosts) > 0 {
+			if err := r.checkHealth(ctx, hosts); err != nil && ctx.Err() == nil {
+				st.Halted = fmt.Sprintf("batch %d health check: %v", st.Batches, err)
+			}
+		}
+		for _, h := range hosts {
+			h.Executor.Close()
+		}
+
+		if err := r.saveState(st); err != nil {
+			return st, err
+		}
+		if ctx.Err() != nil {
+			return st, ctx.Err()
+		}
+		if st.Halted != "" {
+			log.Warnf("rolling halted: %s", st.Halted)
+			return st, fmt.Errorf("%w: %s", ErrHalted, st.Halted)
+		}
+	}
+	return st, nil
+}
+
+// runBatch runs the task on the hosts of batch concurrently and returns
+// the hosts it connected to and the number of failed hosts.
+func (r *Runner) runBatch(ctx context.Context, st *State, batch []string) ([]Host, int) {
+	hosts := make([]Host, len(batch))
+	errs := make([]error, len(batch))
+	var wg sync.WaitGroup
+	for i, name := range batch {
+		i, name := i, name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, err := r.Executor(name)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			hosts[i] = Host{Name: name, Executor: e}
+			errs[i] = r.Task(ctx, name, e)
+		}()
+	}
+	wg.Wait()
+
+	var connected []Host
+	failed := 0
+	for i, name := range batch {
+		if hosts[i].Executor != nil {
+			connected = append(connected, hosts[i])
+		}
+		if errs[i] != nil && ctx.Err() != nil {
+			// interrupted hosts stay pending
+			continue
+		}
+		hs := st.Hosts[name]
+		hs.Batch, hs.Finished = st.Batches, time.Now().UTC()
+		if errs[i] != nil {
+			hs.Status, hs.Error = Failed, errs[i].Error()
+			failed++
+			log.Errorf("rolling %s failed: %v", name, errs[i])
+			continue
+		}
+		hs.Status = Done
+	}
+	return connected, failed
+}
+
+// checkHealth runs the health check until it passes or times out.
+func (r *Runner) checkHealth(ctx context.Context, hosts []Host) error {
+	interval, timeout := r.HealthInterval, r.HealthTimeout
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := r.HealthCheck.Check(ctx, hosts)
+		if err == nil {
+			return nil
+		}
+		log.Debugf("rolling health check: %v", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
+
+// recheckHealth runs the health check on the hosts done in the last
+// batch of a halted rollout, whose health may never have passed.
+func (r *Runner) recheckHealth(ctx context.Context, st *State) error {
+	var hosts []Host
+	defer func() {
+		for _, h := range hosts {
+			h.Executor.Close()
+		}
+	}()
+	for _, name := range r.Hosts {
+		hs, ok := st.Hosts[name]
+		if !ok || hs.Status != Done || hs.Batch != st.Batches {
+			continue
+		}
+		e, err := r.Executor(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		hosts = append(hosts, Host{Name: name, Executor: e})
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	log.Infof("rolling checks the health of batch %d again", st.Batches)
+	return r.checkHealth(ctx, hosts)
+}
+
+// limits returns the batch size and the number of tolerated failures.
+func (r *Runner) limits() (int, int) {
+	total := len(r.Hosts)
+	size := r.BatchSize
+	if r.BatchPercent > 0 {
+		size = (total*r.BatchPercent + 99) / 100
+	}
+	if size <= 0 {
+		size = 1
+	}
+	maxFailures := r.MaxFailures
+	if r.MaxFailPercent > 0 {
+		maxFailures = total * r.MaxFailPercent / 100
+	}
+	return size, maxFailures
+}
+
+func (r *Runner) loadState() (*State, error) {
+	if r.StateFile != "" {
+		st, err := LoadState(r.StateFile)
+		if err == nil {
+			log.Infof("rolling resumes from %s", r.StateFile)
+			return st, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return &State{Started: time.Now().UTC(), Hosts: make(map[string]*HostState)}, nil
+}
+
+func (r *Runner) saveState(st *State) error {
+	st.Updated = time.Now().UTC()
+	if r.StateFile == "" {
+		return nil
+	}
+	return st.Save(r.StateFile)
+}
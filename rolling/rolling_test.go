@@ -0,0 +1,232 @@
+package rolling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mo-silent/go-devops/common"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeExecutor exits with the status set for each command.
+type fakeExecutor struct {
+	codes  map[string]int
+	closed bool
+}
+
+func (f *fakeExecutor) Run(_ context.Context, cmd string, _ common.ExecOptions) (*common.ExecResult, error) {
+	return &common.ExecResult{ExitCode: f.codes[cmd]}, nil
+}
+
+func (f *fakeExecutor) Close() error {
+	f.closed = true
+	return nil
+}
+
+// recorder is a Task recording the hosts it ran on, failing on some.
+type recorder struct {
+	mu   sync.Mutex
+	ran  []string
+	fail map[string]bool
+}
+
+func (r *recorder) task(_ context.Context, host string, _ common.Executor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ran = append(r.ran, host)
+	if r.fail[host] {
+		return fmt.Errorf("restart failed")
+	}
+	return nil
+}
+
+func (r *recorder) hosts() []string {
+	sort.Strings(r.ran)
+	return r.ran
+}
+
+func executors(codes map[string]int) func(string) (common.Executor, error) {
+	return func(host string) (common.Executor, error) {
+		if host == "unreachable" {
+			return nil, errors.New("connection refused")
+		}
+		return &fakeExecutor{codes: codes}, nil
+	}
+}
+
+func batches(st *State) map[string]int {
+	b := make(map[string]int)
+	for h, hs := range st.Hosts {
+		b[h] = hs.Batch
+	}
+	return b
+}
+
+var hosts = []string{"web1", "web2", "web3", "web4", "web5"}
+
+func TestRunner_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		runner      Runner
+		fail        map[string]bool
+		wantErr     error
+		wantBatches map[string]int
+		wantFailed  int
+		wantPending int
+	}{
+		{
+			name:        "batch size",
+			runner:      Runner{BatchSize: 2},
+			wantBatches: map[string]int{"web1": 1, "web2": 1, "web3": 2, "web4": 2, "web5": 3},
+		},
+		{
+			name:        "batch percent",
+			runner:      Runner{BatchPercent: 50},
+			wantBatches: map[string]int{"web1": 1, "web2": 1, "web3": 1, "web4": 2, "web5": 2},
+		},
+		{
+			name:        "halt on failure",
+			runner:      Runner{BatchSize: 2},
+			fail:        map[string]bool{"web3": true},
+			wantErr:     ErrHalted,
+			wantBatches: map[string]int{"web1": 1, "web2": 1, "web3": 2, "web4": 2, "web5": 0},
+			wantFailed:  1,
+			wantPending: 1,
+		},
+		{
+			name:        "tolerated failures",
+			runner:      Runner{BatchSize: 2, MaxFailPercent: 40},
+			fail:        map[string]bool{"web1": true, "web5": true},
+			wantBatches: map[string]int{"web1": 1, "web2": 1, "web3": 2, "web4": 2, "web5": 3},
+			wantFailed:  2,
+		},
+		{
+			name:        "health check",
+			runner:      Runner{BatchSize: 3, HealthCheck: &CommandCheck{Command: "curl -f localhost/healthz"}, HealthInterval: time.Millisecond, HealthTimeout: 10 * time.Millisecond},
+			wantErr:     ErrHalted,
+			wantBatches: map[string]int{"web1": 1, "web2": 1, "web3": 1, "web4": 0, "web5": 0},
+			wantPending: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{fail: tt.fail}
+			r := tt.runner
+			r.Hosts, r.Task = hosts, rec.task
+			r.Executor = executors(map[string]int{"curl -f localhost/healthz": 7})
+			st, err := r.Run(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := batches(st); !reflect.DeepEqual(got, tt.wantBatches) {
+				t.Errorf("Run() batches = %v, want %v", got, tt.wantBatches)
+			}
+			if st.Count(Failed) != tt.wantFailed || st.Count(Pending) != tt.wantPending {
+				t.Errorf("Run() failed = %d, pending = %d, want %d, %d", st.Count(Failed), st.Count(Pending), tt.wantFailed, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestRunner_Run_resume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rollout.json")
+	rec := &recorder{fail: map[string]bool{"web3": true}}
+	r := Runner{Hosts: hosts, Executor: executors(nil), Task: rec.task, BatchSize: 2, StateFile: file}
+	if _, err := r.Run(context.Background()); !errors.Is(err, ErrHalted) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrHalted)
+	}
+	st, err := LoadState(file)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if st.Hosts["web3"].Status != Failed || st.Hosts["web3"].Error != "restart failed" || st.Halted == "" {
+		t.Errorf("LoadState() = %+v, want web3 failed and halted", st)
+	}
+
+	rec = &recorder{}
+	r.Task = rec.task
+	st, err = r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := rec.hosts(); !reflect.DeepEqual(got, []string{"web3", "web5"}) {
+		t.Errorf("Run() resumed on %v, want [web3 web5]", got)
+	}
+	if st.Count(Done) != 5 || st.Batches != 3 || st.Halted != "" {
+		t.Errorf("Run() = %+v, want 5 hosts done in 3 batches", st)
+	}
+}
+
+func TestRunner_Run_resumeHealth(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rollout.json")
+	codes := map[string]int{"curl -f localhost/healthz": 7}
+	rec := &recorder{}
+	r := Runner{
+		Hosts:          hosts,
+		Executor:       executors(codes),
+		Task:           rec.task,
+		BatchSize:      3,
+		HealthCheck:    &CommandCheck{Command: "curl -f localhost/healthz"},
+		HealthInterval: time.Millisecond,
+		HealthTimeout:  10 * time.Millisecond,
+		StateFile:      file,
+	}
+	if _, err := r.Run(context.Background()); !errors.Is(err, ErrHalted) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrHalted)
+	}
+
+	// still unhealthy: the halted batch is checked again first
+	rec = &recorder{}
+	r.Task = rec.task
+	st, err := r.Run(context.Background())
+	if !errors.Is(err, ErrHalted) || len(rec.ran) != 0 || st.Batches != 1 {
+		t.Fatalf("Run() = %d batches, ran on %v, error %v, want halted before running", st.Batches, rec.ran, err)
+	}
+
+	codes["curl -f localhost/healthz"] = 0
+	st, err = r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := rec.hosts(); !reflect.DeepEqual(got, []string{"web4", "web5"}) {
+		t.Errorf("Run() resumed on %v, want [web4 web5]", got)
+	}
+	if st.Count(Done) != 5 || st.Batches != 2 || st.Halted != "" {
+		t.Errorf("Run() = %+v, want 5 hosts done in 2 batches", st)
+	}
+}
+
+func TestRunner_Run_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := Runner{
+		Hosts:    hosts,
+		Executor: executors(nil),
+		Task: func(ctx context.Context, host string, _ common.Executor) error {
+			if host == "web2" {
+				cancel()
+			}
+			return ctx.Err()
+		},
+	}
+	st, err := r.Run(ctx)
+	if err != context.Canceled {
+		t.Fatalf("Run() error = %v, want %v", err, context.Canceled)
+	}
+	if st.Count(Done) != 1 || st.Count(Pending) != 4 {
+		t.Errorf("Run() = %+v, want web1 done and the rest pending", st.Hosts)
+	}
+}
+
+func TestRunner_Run_unreachable(t *testing.T) {
+	rec := &recorder{}
+	r := Runner{Hosts: []string{"web1", "unreachable"}, Executor: executors(nil), Task: rec.task, BatchSize: 2}
+	st, err := r.Run(context.Background())
+	if !errors.Is(err, ErrHalted) || st.Hosts["unreachable"].Error != "connection refused" {
+		t.Errorf("Run() = %+v, %v", st.Hosts["unreachable"], err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2023  silent mo <1916393131@qq.com>.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rolling
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Status is the status of a host in a rollout.
+type Status string
+
+// The statuses of a host.
+const (
+	Pending Status = "pending"
+	Done    Status = "done"
+	Failed  Status = "failed"
+)
+
+// HostState is the progress of a host.
+type HostState struct {
+	Status Status `json:"status"`
+	// Batch is the number of the batch the host last ran in, from 1.
+	Batch    int       `json:"batch,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// State is the progress of a rollout, saved to the state file
+// after every batch.
+type State struct {
+	Started time.Time `json:"started"`
+	Updated time.Time `json:"updated"`
+	// Batches is the number of batches run so far.
+	Batches int                   `json:"batches"`
+	Hosts   map[string]*HostState `json:"hosts"`
+	// Halted is the reason the rollout halted, if it did.
+	Halted string `json:"halted,omitempty"`
+}
+
+// Count returns the number of hosts with status s.
+func (st *State) Count(s Status) int {
+	n := 0
+	for _, h := range st.Hosts {
+		if h.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// LoadState reads a state file.
+func LoadState(file string) (*State, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	st := &State{}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	if st.Hosts == nil {
+		st.Hosts = make(map[string]*HostState)
+	}
+	return st, nil
+}
+
+// Save writes the state to file atomically.
+func (st *State) Save(file string) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}